@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"nmap-rest-api/agent"
+	"nmap-rest-api/config"
+	"nmap-rest-api/worker"
+)
+
+// runAgent implements `nmap-rest-api agent [flags]`: a remote scanner that
+// takes jobs from the API over HTTP(S) instead of from Redis, for networks
+// the central workers cannot reach.
+func runAgent(args []string) {
+	cfg, _, err := config.Load("nmap-rest-api agent", args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.ValidateAgent(); err != nil {
+		log.Fatal(err)
+	}
+
+	a, err := agent.New(cfg.Agents)
+	if err != nil {
+		log.Fatalf("Failed to start agent: %v", err)
+	}
+	worker.Nmap = cfg.Nmap
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	a.Run(ctx)
+}
@@ -0,0 +1,292 @@
+// Package agent is the remote scanner: it registers with the API, long-polls
+// for jobs in its network zone, scans them with the local nmap and uploads the
+// results over HTTP(S). It needs neither Postgres nor Redis.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/worker"
+)
+
+// Version is reported to the server; set it at build time with
+// -ldflags "-X nmap-rest-api/agent.Version=1.2.3".
+var Version = "dev"
+
+// retryDelay is how long the agent backs off after a failed request.
+var retryDelay = 5 * time.Second
+
+var errUnauthorized = errors.New("agent token rejected")
+
+// Agent is one running agent process.
+type Agent struct {
+	cfg    config.Agents
+	base   string
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	running map[string]models.ScanJob // by ScanJob.RunKey
+}
+
+// New prepares an agent for cfg. The name defaults to the hostname.
+func New(cfg config.Agents) (*Agent, error) {
+	if cfg.Name == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Name = strings.ToLower(host)
+	}
+	if _, err := url.Parse(cfg.ServerURL); err != nil {
+		return nil, fmt.Errorf("agent server URL: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	}
+	return &Agent{
+		cfg:     cfg,
+		base:    strings.TrimRight(cfg.ServerURL, "/"),
+		client:  &http.Client{Transport: transport},
+		running: make(map[string]models.ScanJob),
+	}, nil
+}
+
+// Run registers and then takes jobs until ctx is done. Scans still running
+// then are abandoned; the server requeues them once their leases expire.
+func (a *Agent) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := a.register(ctx); err != nil {
+			log.Printf("Agent registration failed: %v", err)
+			sleep(ctx, retryDelay)
+			continue
+		}
+		break
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.heartbeats(ctx)
+	}()
+
+	slots := make(chan struct{}, a.cfg.Capacity)
+	for ctx.Err() == nil {
+		// Hold one slot while polling and ask for as many jobs as are free.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		jobs, err := a.poll(ctx, 1+cap(slots)-len(slots))
+		if err != nil {
+			<-slots
+			if ctx.Err() == nil {
+				log.Printf("Agent job poll failed: %v", err)
+				a.recover(ctx, err)
+			}
+			continue
+		}
+		if len(jobs) == 0 {
+			<-slots
+			continue
+		}
+		for i, job := range jobs {
+			if i > 0 {
+				slots <- struct{}{}
+			}
+			wg.Add(1)
+			go func(job models.ScanJob) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				a.scan(ctx, job)
+			}(job)
+		}
+	}
+	wg.Wait()
+	log.Println("Agent stopped")
+}
+
+// recover re-registers after the server rejected the token, e.g. because the
+// agent was deleted or registered again elsewhere, and otherwise backs off.
+func (a *Agent) recover(ctx context.Context, err error) {
+	if errors.Is(err, errUnauthorized) {
+		if err := a.register(ctx); err != nil {
+			log.Printf("Agent registration failed: %v", err)
+		} else {
+			return
+		}
+	}
+	sleep(ctx, retryDelay)
+}
+
+func (a *Agent) register(ctx context.Context) error {
+	reg := models.AgentRegistration{Name: a.cfg.Name, Zone: a.cfg.Zone, Version: Version, Capacity: a.cfg.Capacity}
+	var creds models.AgentCredentials
+	if _, err := a.do(ctx, http.MethodPost, "/agents/register", a.cfg.EnrollmentToken, reg, &creds); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.token = creds.Token
+	a.mu.Unlock()
+	log.Printf("Agent %s registered as #%d (zone %q, capacity %d)", a.cfg.Name, creds.ID, a.cfg.Zone, a.cfg.Capacity)
+	return nil
+}
+
+// poll long-polls for at most max jobs.
+func (a *Agent) poll(ctx context.Context, max int) ([]models.ScanJob, error) {
+	path := fmt.Sprintf("/agents/jobs?max=%d&wait=%s", max, a.cfg.PollWait)
+	var jobs []models.ScanJob
+	_, err := a.do(ctx, http.MethodGet, path, a.currentToken(), nil, &jobs)
+	return jobs, err
+}
+
+// scan runs one job and uploads its result. A scan cut short by shutdown is
+// not uploaded.
+func (a *Agent) scan(ctx context.Context, job models.ScanJob) {
+	key := job.RunKey()
+	a.mu.Lock()
+	a.running[key] = job
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.running, key)
+		a.mu.Unlock()
+	}()
+
+	res := worker.ScanHost(ctx, job.Host, worker.ScanOptions{Ports: job.Ports, MaxRate: job.MaxRate}, func(int) {})
+	if ctx.Err() != nil {
+		log.Printf("Scan of %s interrupted by shutdown; the server will requeue it", job.Host)
+		return
+	}
+	res.ScanID = job.ScanID
+	res.ScannedAt = time.Now()
+	a.upload(context.WithoutCancel(ctx), models.AgentResult{Job: job, Result: res})
+}
+
+// upload sends a result, retrying transient failures. The lease keeps being
+// renewed by heartbeats meanwhile, since the job is still listed as running.
+func (a *Agent) upload(ctx context.Context, up models.AgentResult) {
+	for attempt := 1; ; attempt++ {
+		status, err := a.do(ctx, http.MethodPost, "/agents/results", a.currentToken(), up, nil)
+		switch {
+		case err == nil:
+			log.Printf("Result for %s uploaded", up.Job.Host)
+			return
+		case status == http.StatusConflict:
+			log.Printf("Result for %s dropped: lease expired and the job was requeued", up.Job.Host)
+			return
+		case attempt == 3:
+			log.Printf("Giving up uploading result for %s: %v", up.Job.Host, err)
+			return
+		}
+		log.Printf("Uploading result for %s failed (%d/3): %v", up.Job.Host, attempt, err)
+		a.recover(ctx, err)
+	}
+}
+
+// heartbeats reports the agent's state every HeartbeatInterval, renewing the
+// leases of running jobs.
+func (a *Agent) heartbeats(ctx context.Context) {
+	t := time.NewTicker(a.cfg.HeartbeatInterval.D())
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		a.mu.Lock()
+		hb := models.AgentHeartbeat{Version: Version, Capacity: a.cfg.Capacity, Running: len(a.running)}
+		for _, job := range a.running {
+			hb.Jobs = append(hb.Jobs, job)
+		}
+		a.mu.Unlock()
+		if _, err := a.do(ctx, http.MethodPost, "/agents/heartbeat", a.currentToken(), hb, nil); err != nil && ctx.Err() == nil {
+			log.Printf("Agent heartbeat failed: %v", err)
+			if errors.Is(err, errUnauthorized) {
+				a.recover(ctx, err)
+			}
+		}
+	}
+}
+
+func (a *Agent) currentToken() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token
+}
+
+// do sends in as JSON with the bearer token and decodes a 2xx response into
+// out. It returns the HTTP status and, for non-2xx responses, an error;
+// 401 is errUnauthorized.
+func (a *Agent) do(ctx context.Context, method, path, token string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.base+path, body)
+	if err != nil {
+		return 0, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return resp.StatusCode, errUnauthorized
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	case out != nil && resp.StatusCode != http.StatusNoContent:
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode, nil
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
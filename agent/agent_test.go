@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_TracksShardsOfOneHostApart(t *testing.T) {
+	xml, err := filepath.Abs("../nmap/testdata/batch.xml")
+	require.NoError(t, err)
+	script := filepath.Join(t.TempDir(), "nmap")
+	// Each shard scans long enough for heartbeats to report it.
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nsleep 0.3\ncat "+xml+"\n"), 0o755))
+	saved := worker.Nmap
+	t.Cleanup(func() { worker.Nmap = saved })
+	worker.Nmap.Binary, worker.Nmap.Args, worker.Nmap.MaxRetries = script, nil, 1
+
+	var (
+		mu        sync.Mutex
+		heartbeat [][]string // running jobs of each heartbeat
+		uploaded  []int      // shards whose result arrived
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/agents/heartbeat":
+			var hb models.AgentHeartbeat
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&hb))
+			var keys []string
+			for _, job := range hb.Jobs {
+				keys = append(keys, job.RunKey())
+			}
+			sort.Strings(keys)
+			heartbeat = append(heartbeat, keys)
+		case "/agents/results":
+			var up models.AgentResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&up))
+			uploaded = append(uploaded, up.Job.Shard)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	a, err := New(config.Agents{Name: "a1", ServerURL: srv.URL, Capacity: 2, HeartbeatInterval: config.Duration(50 * time.Millisecond)})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.heartbeats(ctx)
+
+	var wg sync.WaitGroup
+	for shard := 0; shard < 2; shard++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			a.scan(ctx, models.ScanJob{ScanID: "s1", Host: "web.example.com", Ports: "1-65535", Shard: shard, Shards: 2})
+		}(shard)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Ints(uploaded)
+	assert.Equal(t, []int{0, 1}, uploaded)
+	assert.Contains(t, heartbeat, []string{"s1|web.example.com|0", "s1|web.example.com|1"}, "both shards' leases are renewed")
+	assert.Empty(t, a.running)
+}
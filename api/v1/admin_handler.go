@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"net/http"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+	"nmap-rest-api/worker"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListWorkers godoc
+// @Summary     List workers
+// @Description Shows what every worker in this process is doing: its state (idle, waiting, scanning, storing), current scan and host, when the job started and the PID of the nmap child. Workers stuck in scanning or storing stand out by their start time.
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} modelsv1.WorkerStatus
+// @Router      /admin/workers [get]
+func ListWorkers(c *gin.Context) {
+	var workers []modelsv1.WorkerStatus = worker.Workers()
+	c.JSON(http.StatusOK, workers)
+}
+
+// GetRetentionReport godoc
+// @Summary     Dry run of result retention
+// @Description Reports, per inventory owner, how many stored scan results the retention policy would delete now, without deleting anything. Past an owner's full detail period a host keeps only the results that differ from the one before and the first of each month.
+// @Tags        admin
+// @Produce     json
+// @Success     200 {object} modelsv1.RetentionReport
+// @Failure     500 {object} map[string]string
+// @Router      /admin/retention [get]
+func GetRetentionReport(c *gin.Context) {
+	report, err := businessv1.PruneResults(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate retention"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,201 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+const agentKey = "agent"
+
+// AgentAuth authenticates remote agents by their bearer token and stores the
+// agent in the context for the handlers below.
+func AgentAuth(c *gin.Context) {
+	a, err := businessv1.AuthenticateAgent(bearerToken(c))
+	if err != nil {
+		respondAgentError(c, err)
+		c.Abort()
+		return
+	}
+	c.Set(agentKey, a)
+	c.Next()
+}
+
+func bearerToken(c *gin.Context) string {
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+func currentAgent(c *gin.Context) modelsv1.Agent {
+	return c.MustGet(agentKey).(modelsv1.Agent)
+}
+
+// RegisterAgent godoc
+// @Summary     Register a remote agent
+// @Description Registers an agent with the shared enrollment token (Authorization: Bearer <enrollment token>) and returns the agent's own bearer token. Registering an existing name replaces its token.
+// @Tags        agents
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.AgentRegistration true "Agent name, zone, version and capacity"
+// @Success     201 {object} modelsv1.AgentCredentials
+// @Failure     400 {object} map[string]string
+// @Failure     401 {object} map[string]string
+// @Failure     403 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /agents/register [post]
+func RegisterAgent(c *gin.Context) {
+	var reg modelsv1.AgentRegistration
+	if err := c.BindJSON(&reg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	creds, err := businessv1.RegisterAgent(bearerToken(c), reg, c.ClientIP())
+	if err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, creds)
+}
+
+// PollAgentJobs godoc
+// @Summary     Long-poll for jobs
+// @Description Waits up to wait (default 25s, at most 30s) for jobs in the agent's zone and returns at most max of them (default and cap: the agent's capacity). Each job is leased to the agent; leases not renewed by a heartbeat are requeued. 204 means no job arrived in time.
+// @Tags        agents
+// @Produce     json
+// @Param       max  query int    false "Maximum number of jobs"
+// @Param       wait query string false "Maximum wait, e.g. 25s"
+// @Success     200 {array} modelsv1.ScanJob
+// @Success     204
+// @Failure     401 {object} map[string]string
+// @Router      /agents/jobs [get]
+func PollAgentJobs(c *gin.Context) {
+	max, _ := strconv.Atoi(c.Query("max"))
+	wait := 25 * time.Second
+	if s := c.Query("wait"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wait"})
+			return
+		}
+		wait = d
+	}
+	jobs, err := businessv1.LeaseAgentJobs(c.Request.Context(), currentAgent(c), max, wait, c.ClientIP())
+	if err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	if len(jobs) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// AgentHeartbeat godoc
+// @Summary     Report agent state
+// @Description Records the agent's version, capacity and running scans, marks it online and renews the leases of the jobs it lists.
+// @Tags        agents
+// @Accept      json
+// @Param       request body modelsv1.AgentHeartbeat true "Agent state"
+// @Success     204
+// @Failure     401 {object} map[string]string
+// @Router      /agents/heartbeat [post]
+func AgentHeartbeat(c *gin.Context) {
+	var hb modelsv1.AgentHeartbeat
+	if err := c.BindJSON(&hb); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := businessv1.AgentHeartbeat(c.Request.Context(), currentAgent(c), hb, c.ClientIP()); err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UploadAgentResult godoc
+// @Summary     Upload a scan result
+// @Description Stores the result of a job leased to the agent, exactly like a central worker's result. 409 means the lease expired and the job was requeued; the agent should drop the result.
+// @Tags        agents
+// @Accept      json
+// @Param       request body modelsv1.AgentResult true "Job and result"
+// @Success     204
+// @Failure     401 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /agents/results [post]
+func UploadAgentResult(c *gin.Context) {
+	var up modelsv1.AgentResult
+	if err := c.BindJSON(&up); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := businessv1.CompleteAgentJob(c.Request.Context(), currentAgent(c), up); err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAgents godoc
+// @Summary     List remote agents
+// @Description Lists registered agents with their zone, version, capacity, running scans, last contact and whether they are online.
+// @Tags        agents
+// @Produce     json
+// @Success     200 {array} modelsv1.Agent
+// @Failure     500 {object} map[string]string
+// @Router      /agents [get]
+func ListAgents(c *gin.Context) {
+	agents, err := businessv1.ListAgents()
+	if err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, agents)
+}
+
+// DeleteAgent godoc
+// @Summary     Remove a remote agent
+// @Description Revokes the agent's token. Jobs it holds are requeued when their leases expire.
+// @Tags        agents
+// @Param       id path int true "Agent ID"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /agents/{id} [delete]
+func DeleteAgent(c *gin.Context) {
+	if err := businessv1.DeleteAgent(c.Param("id")); err != nil {
+		respondAgentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondAgentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, businessv1.ErrInvalidAgent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrAgentUnauthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid agent token"})
+	case errors.Is(err, businessv1.ErrEnrollmentDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Agent registration is disabled"})
+	case errors.Is(err, businessv1.ErrZoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Zone not found"})
+	case errors.Is(err, businessv1.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+	case errors.Is(err, businessv1.ErrLeaseNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrNeedsPostgres):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Agent request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Agent operation failed"})
+	}
+}
@@ -0,0 +1,306 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAlertRule godoc
+// @Summary     Create an alert rule
+// @Description Conditions: new_port_opened, port_open (ports), host_down, new_ports_exceed (threshold), violation_opened.
+// @Tags        alerts
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.AlertRule true "Rule"
+// @Success     201 {object} modelsv1.AlertRule
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /alert-rules [post]
+func CreateAlertRule(c *gin.Context) {
+	var r modelsv1.AlertRule
+	if err := c.BindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	created, err := businessv1.CreateAlertRule(r)
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListAlertRules godoc
+// @Summary     List alert rules
+// @Tags        alerts
+// @Produce     json
+// @Success     200 {array} modelsv1.AlertRule
+// @Router      /alert-rules [get]
+func ListAlertRules(c *gin.Context) {
+	rules, err := businessv1.ListAlertRules()
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// GetAlertRule godoc
+// @Summary     Get an alert rule
+// @Tags        alerts
+// @Produce     json
+// @Param       id path int true "Rule ID"
+// @Success     200 {object} modelsv1.AlertRule
+// @Failure     404 {object} map[string]string
+// @Router      /alert-rules/{id} [get]
+func GetAlertRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	r, err := businessv1.GetAlertRule(id)
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// ReplaceAlertRule godoc
+// @Summary     Replace an alert rule
+// @Tags        alerts
+// @Accept      json
+// @Produce     json
+// @Param       id path int true "Rule ID"
+// @Param       request body modelsv1.AlertRule true "Rule"
+// @Success     200 {object} modelsv1.AlertRule
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /alert-rules/{id} [put]
+func ReplaceAlertRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var r modelsv1.AlertRule
+	if err := c.BindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	r.ID = id
+	updated, err := businessv1.ReplaceAlertRule(r)
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteAlertRule godoc
+// @Summary     Delete an alert rule and its alerts
+// @Tags        alerts
+// @Param       id path int true "Rule ID"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /alert-rules/{id} [delete]
+func DeleteAlertRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := businessv1.DeleteAlertRule(id); err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAlerts godoc
+// @Summary     List fired alerts
+// @Tags        alerts
+// @Produce     json
+// @Param       rule_id query int false "Only this rule"
+// @Param       host query string false "Only this host"
+// @Param       severity query string false "Only this severity"
+// @Param       since query string false "Fired within this window, e.g. 24h"
+// @Param       limit query int false "Page size (default 500)"
+// @Param       offset query int false "Page offset"
+// @Success     200 {array} modelsv1.Alert
+// @Failure     400 {object} map[string]string
+// @Router      /alerts [get]
+func ListAlerts(c *gin.Context) {
+	f := modelsv1.AlertFilter{
+		Host:     c.Query("host"),
+		Severity: c.Query("severity"),
+	}
+	var err error
+	if v := c.Query("rule_id"); v != "" {
+		if f.RuleID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule_id"})
+			return
+		}
+	}
+	if v := c.Query("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since duration"})
+			return
+		}
+		f.Since = time.Now().Add(-d)
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return
+			}
+		}
+	}
+
+	alerts, err := businessv1.ListAlerts(f)
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// CreateSilence godoc
+// @Summary     Silence alerts
+// @Description Suppresses notifications for a rule (rule_id 0 = all rules) and host glob until the given time.
+// @Tags        alerts
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.AlertSilence true "Silence"
+// @Success     201 {object} modelsv1.AlertSilence
+// @Failure     400 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /alert-silences [post]
+func CreateSilence(c *gin.Context) {
+	var s modelsv1.AlertSilence
+	if err := c.BindJSON(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	created, err := businessv1.CreateSilence(s)
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListSilences godoc
+// @Summary     List alert silences
+// @Tags        alerts
+// @Produce     json
+// @Param       all query bool false "Include expired silences"
+// @Success     200 {array} modelsv1.AlertSilence
+// @Router      /alert-silences [get]
+func ListSilences(c *gin.Context) {
+	silences, err := businessv1.ListSilences(c.Query("all") == "true")
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, silences)
+}
+
+// DeleteSilence godoc
+// @Summary     Delete an alert silence
+// @Tags        alerts
+// @Param       id path int true "Silence ID"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /alert-silences/{id} [delete]
+func DeleteSilence(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := businessv1.DeleteSilence(id); err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateChannel godoc
+// @Summary     Create a notification channel
+// @Description Types: webhook {url, headers}, smtp {addr, from, to, username, password}, syslog {network, addr, tag}.
+// @Tags        alerts
+// @Accept      json
+// @Param       request body modelsv1.AlertChannel true "Channel"
+// @Success     201
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /alert-channels [post]
+func CreateChannel(c *gin.Context) {
+	var ch modelsv1.AlertChannel
+	if err := c.BindJSON(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := businessv1.CreateChannel(ch); err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.Status(http.StatusCreated)
+}
+
+// ListChannels godoc
+// @Summary     List notification channels
+// @Description Secrets in channel configuration are redacted.
+// @Tags        alerts
+// @Produce     json
+// @Success     200 {array} modelsv1.AlertChannel
+// @Router      /alert-channels [get]
+func ListChannels(c *gin.Context) {
+	channels, err := businessv1.ListChannels()
+	if err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, channels)
+}
+
+// DeleteChannel godoc
+// @Summary     Delete a notification channel
+// @Tags        alerts
+// @Param       name path string true "Channel name"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /alert-channels/{name} [delete]
+func DeleteChannel(c *gin.Context) {
+	if err := businessv1.DeleteChannel(c.Param("name")); err != nil {
+		respondAlertError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondAlertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, businessv1.ErrInvalidRule), errors.Is(err, businessv1.ErrInvalidSilence),
+		errors.Is(err, businessv1.ErrInvalidChannel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrRuleExists), errors.Is(err, businessv1.ErrChannelExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrRuleNotFound), errors.Is(err, businessv1.ErrSilenceNotFound),
+		errors.Is(err, businessv1.ErrChannelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrNeedsPostgres):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alert operation failed"})
+	}
+}
@@ -0,0 +1,265 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBaseline godoc
+// @Summary     Create a baseline
+// @Description Defines the ports a host or every member of an asset group is allowed to expose.
+// @Tags        baselines
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.Baseline true "Baseline (scope is host or group)"
+// @Success     201 {object} modelsv1.Baseline
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /baselines [post]
+func CreateBaseline(c *gin.Context) {
+	var b modelsv1.Baseline
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	created, err := businessv1.CreateBaseline(b)
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// CreateBaselineFromHost godoc
+// @Summary     Create a baseline from a host's current ports
+// @Description Snapshots the host's latest open ports into a baseline for the host, or for the given group.
+// @Tags        baselines
+// @Produce     json
+// @Param       host path string true "Host or IP address"
+// @Param       group query string false "Create the baseline for this group instead of the host"
+// @Success     201 {object} modelsv1.Baseline
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /baselines/from-host/{host} [post]
+func CreateBaselineFromHost(c *gin.Context) {
+	created, err := businessv1.CreateBaselineFromHost(c.Param("host"), c.Query("group"))
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListBaselines godoc
+// @Summary     List baselines
+// @Tags        baselines
+// @Produce     json
+// @Success     200 {array} modelsv1.Baseline
+// @Failure     500 {object} map[string]string
+// @Router      /baselines [get]
+func ListBaselines(c *gin.Context) {
+	baselines, err := businessv1.ListBaselines()
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, baselines)
+}
+
+// GetBaseline godoc
+// @Summary     Get a baseline
+// @Tags        baselines
+// @Produce     json
+// @Param       id path int true "Baseline ID"
+// @Success     200 {object} modelsv1.Baseline
+// @Failure     404 {object} map[string]string
+// @Router      /baselines/{id} [get]
+func GetBaseline(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	b, err := businessv1.GetBaseline(id)
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// ReplaceBaseline godoc
+// @Summary     Replace a baseline's ports
+// @Description Replaces the allowed ports and description. Scope and target cannot change.
+// @Tags        baselines
+// @Accept      json
+// @Produce     json
+// @Param       id path int true "Baseline ID"
+// @Param       request body modelsv1.Baseline true "Baseline"
+// @Success     200 {object} modelsv1.Baseline
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /baselines/{id} [put]
+func ReplaceBaseline(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var b modelsv1.Baseline
+	if err := c.BindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	b.ID = id
+	updated, err := businessv1.ReplaceBaseline(b)
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteBaseline godoc
+// @Summary     Delete a baseline and its violations
+// @Tags        baselines
+// @Param       id path int true "Baseline ID"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /baselines/{id} [delete]
+func DeleteBaseline(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := businessv1.DeleteBaseline(id); err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListViolations godoc
+// @Summary     List baseline violations
+// @Tags        baselines
+// @Produce     json
+// @Param       host query string false "Only this host"
+// @Param       status query string false "open, acknowledged or resolved"
+// @Param       kind query string false "unexpected_open, expected_missing or unexpected_service"
+// @Param       baseline_id query int false "Only this baseline"
+// @Param       limit query int false "Page size (default 500)"
+// @Param       offset query int false "Page offset"
+// @Success     200 {array} modelsv1.Violation
+// @Failure     400 {object} map[string]string
+// @Router      /violations [get]
+func ListViolations(c *gin.Context) {
+	f := modelsv1.ViolationFilter{
+		Host:   c.Query("host"),
+		Status: c.Query("status"),
+		Kind:   c.Query("kind"),
+	}
+	var err error
+	if v := c.Query("baseline_id"); v != "" {
+		if f.BaselineID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid baseline_id"})
+			return
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return
+			}
+		}
+	}
+
+	violations, err := businessv1.ListViolations(f)
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, violations)
+}
+
+// AcknowledgeViolation godoc
+// @Summary     Acknowledge a violation
+// @Tags        baselines
+// @Accept      json
+// @Produce     json
+// @Param       id path int true "Violation ID"
+// @Param       request body modelsv1.ViolationAction true "Who acknowledges and why"
+// @Success     200 {object} modelsv1.Violation
+// @Failure     404 {object} map[string]string
+// @Router      /violations/{id}/ack [post]
+func AcknowledgeViolation(c *gin.Context) {
+	transitionViolation(c, businessv1.AcknowledgeViolation)
+}
+
+// ResolveViolation godoc
+// @Summary     Resolve a violation
+// @Tags        baselines
+// @Accept      json
+// @Produce     json
+// @Param       id path int true "Violation ID"
+// @Param       request body modelsv1.ViolationAction false "Resolution note"
+// @Success     200 {object} modelsv1.Violation
+// @Failure     404 {object} map[string]string
+// @Router      /violations/{id}/resolve [post]
+func ResolveViolation(c *gin.Context) {
+	transitionViolation(c, businessv1.ResolveViolation)
+}
+
+func transitionViolation(c *gin.Context, fn func(int64, modelsv1.ViolationAction) (modelsv1.Violation, error)) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var a modelsv1.ViolationAction
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&a); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	v, err := fn(id, a)
+	if err != nil {
+		respondBaselineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+func idParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func respondBaselineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, businessv1.ErrInvalidBaseline):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrInvalidFilter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter"})
+	case errors.Is(err, businessv1.ErrBaselineExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Baseline already exists"})
+	case errors.Is(err, businessv1.ErrBaselineNotFound), errors.Is(err, businessv1.ErrHostNotFound),
+		errors.Is(err, businessv1.ErrViolationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrNeedsPostgres):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Baseline operation failed"})
+	}
+}
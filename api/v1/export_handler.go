@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/export"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportResults godoc
+// @Summary     Export scan results
+// @Description Streams the results of a scan, a host's history or a port search as CSV, JSON Lines, nmap XML or SARIF.
+// @Tags        export
+// @Produce     plain
+// @Param       format query string false "csv, jsonl (default), xml or sarif"
+// @Param       scan_id query string false "Export every host of this scan"
+// @Param       host query string false "Export this host's history"
+// @Param       port query int false "Search: port number"
+// @Param       protocol query string false "Search: tcp, udp or sctp"
+// @Param       service query string false "Search: service name"
+// @Param       product query string false "Search: product substring"
+// @Param       seen_within query string false "Search: look back window, e.g. 24h"
+// @Param       limit query int false "Maximum number of results (scan/host exports)"
+// @Success     200 {string} string "Exported results"
+// @Failure     400 {object} map[string]string
+// @Router      /export [get]
+func ExportResults(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatJSONL)
+	q := modelsv1.ExportQuery{
+		ScanID: c.Query("scan_id"),
+		Host:   c.Query("host"),
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		q.Limit = n
+	}
+	if c.Query("port") != "" || c.Query("service") != "" || c.Query("product") != "" {
+		search, err := parseSearchQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if p := c.Query("port"); p != "" {
+			if search.Port, err = strconv.Atoi(p); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+				return
+			}
+		}
+		q.Search = &search
+	}
+
+	if err := businessv1.ValidateExport(q, format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="nmap-export.%s"`, export.Extension(format)))
+	c.Status(http.StatusOK)
+	if err := businessv1.ExportResults(q, format, c.Writer); err != nil {
+		// Headers are already sent; all we can do is cut the stream short.
+		log.Printf("Export failed: %v", err)
+		c.Abort()
+	}
+}
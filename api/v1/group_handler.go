@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateGroup godoc
+// @Summary     Create an asset group
+// @Description Creates a named group of static hosts and/or dynamic match criteria (tags, CIDRs, name patterns).
+// @Tags        groups
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.AssetGroup true "Group definition"
+// @Success     201 {object} modelsv1.AssetGroup
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /groups [post]
+func CreateGroup(c *gin.Context) {
+	var g modelsv1.AssetGroup
+	if err := c.BindJSON(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := businessv1.CreateGroup(g); err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	respondGroup(c, http.StatusCreated, g.Name)
+}
+
+// ReplaceGroup godoc
+// @Summary     Replace an asset group
+// @Description Replaces the description, hosts and match criteria of an existing group.
+// @Tags        groups
+// @Accept      json
+// @Produce     json
+// @Param       name path string true "Group name"
+// @Param       request body modelsv1.AssetGroup true "Group definition"
+// @Success     200 {object} modelsv1.AssetGroup
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /groups/{name} [put]
+func ReplaceGroup(c *gin.Context) {
+	var g modelsv1.AssetGroup
+	if err := c.BindJSON(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	g.Name = c.Param("name")
+	if err := businessv1.ReplaceGroup(g); err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	respondGroup(c, http.StatusOK, g.Name)
+}
+
+// ListGroups godoc
+// @Summary     List asset groups
+// @Tags        groups
+// @Produce     json
+// @Success     200 {array} modelsv1.AssetGroup
+// @Failure     500 {object} map[string]string
+// @Router      /groups [get]
+func ListGroups(c *gin.Context) {
+	groups, err := businessv1.ListGroups()
+	if err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetGroup godoc
+// @Summary     Get an asset group
+// @Tags        groups
+// @Produce     json
+// @Param       name path string true "Group name"
+// @Success     200 {object} modelsv1.AssetGroup
+// @Failure     404 {object} map[string]string
+// @Router      /groups/{name} [get]
+func GetGroup(c *gin.Context) {
+	respondGroup(c, http.StatusOK, c.Param("name"))
+}
+
+// DeleteGroup godoc
+// @Summary     Delete an asset group
+// @Tags        groups
+// @Param       name path string true "Group name"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /groups/{name} [delete]
+func DeleteGroup(c *gin.Context) {
+	if err := businessv1.DeleteGroup(c.Param("name")); err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResolveGroup godoc
+// @Summary     Preview group members
+// @Description Returns the hosts the group would expand to if a scan were submitted now.
+// @Tags        groups
+// @Produce     json
+// @Param       name path string true "Group name"
+// @Success     200 {object} map[string]interface{}
+// @Failure     404 {object} map[string]string
+// @Router      /groups/{name}/hosts [get]
+func ResolveGroup(c *gin.Context) {
+	name := c.Param("name")
+	hosts, err := businessv1.ResolveGroup(name)
+	if err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group": name, "hosts": hosts})
+}
+
+func respondGroup(c *gin.Context, status int, name string) {
+	g, err := businessv1.GetGroup(name)
+	if err != nil {
+		respondGroupError(c, err)
+		return
+	}
+	c.JSON(status, g)
+}
+
+func respondGroupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, businessv1.ErrInvalidGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, businessv1.ErrGroupExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Group already exists"})
+	case errors.Is(err, businessv1.ErrNeedsPostgres):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Group operation failed"})
+	}
+}
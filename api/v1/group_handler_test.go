@@ -0,0 +1,32 @@
+package v1_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "nmap-rest-api/api/v1"
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGroup_WithoutPostgresIsNotImplemented(t *testing.T) {
+	orig := businessv1.CreateGroup
+	defer func() { businessv1.CreateGroup = orig }()
+	businessv1.CreateGroup = func(modelsv1.AssetGroup) error { return businessv1.ErrNeedsPostgres }
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/groups", v1.CreateGroup)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/groups", bytes.NewBufferString(`{"name":"web","hosts":["example.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Contains(t, w.Body.String(), "not available without Postgres")
+}
@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"net/http"
+
+	"nmap-rest-api/health"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz godoc
+// @Summary     Liveness probe
+// @Description Answers as long as the process serves HTTP. Dependencies are not checked; see /readyz.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} map[string]string
+// @Router      /healthz [get]
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": modelsv1.HealthOK})
+}
+
+// Readyz godoc
+// @Summary     Readiness probe
+// @Description Checks Postgres, Redis, the OTLP collector, the nmap binary and the worker pool, with per-check latency. Returns 503 when a critical check fails or the process is draining for shutdown.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} modelsv1.HealthReport
+// @Failure     503 {object} modelsv1.HealthReport
+// @Router      /readyz [get]
+func Readyz(c *gin.Context) {
+	report := health.Run(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != modelsv1.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
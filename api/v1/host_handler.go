@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListHosts godoc
+// @Summary     List inventory hosts
+// @Description Returns the derived host inventory with first/last seen times, open ports and metadata.
+// @Tags        hosts
+// @Produce     json
+// @Param       tag query string false "Only hosts with this tag"
+// @Param       owner query string false "Only hosts with this owner"
+// @Param       lifecycle query string false "active or stale"
+// @Param       port query int false "Only hosts with this port currently open"
+// @Param       q query string false "Host name substring"
+// @Param       limit query int false "Page size (default 500)"
+// @Param       offset query int false "Page offset"
+// @Success     200 {array} modelsv1.Host
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /hosts [get]
+func ListHosts(c *gin.Context) {
+	f := modelsv1.HostFilter{
+		Tag:       c.Query("tag"),
+		Owner:     c.Query("owner"),
+		Lifecycle: c.Query("lifecycle"),
+		Search:    c.Query("q"),
+	}
+	for name, dst := range map[string]*int{"port": &f.Port, "limit": &f.Limit, "offset": &f.Offset} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return
+			}
+			*dst = n
+		}
+	}
+
+	hosts, err := businessv1.ListHosts(f)
+	if errors.Is(err, businessv1.ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list hosts"})
+		return
+	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+// GetHost godoc
+// @Summary     Get an inventory host
+// @Description Returns the inventory entry for a single host.
+// @Tags        hosts
+// @Produce     json
+// @Param       host path string true "Host or IP address"
+// @Success     200 {object} modelsv1.Host
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /hosts/{host} [get]
+func GetHost(c *gin.Context) {
+	h, err := businessv1.GetHost(c.Param("host"))
+	respondHost(c, h, err)
+}
+
+// UpdateHost godoc
+// @Summary     Update host metadata
+// @Description Sets tags, owner and free-form metadata on an inventory host. Omitted fields are unchanged.
+// @Tags        hosts
+// @Accept      json
+// @Produce     json
+// @Param       host path string true "Host or IP address"
+// @Param       request body modelsv1.HostUpdate true "Metadata to set"
+// @Success     200 {object} modelsv1.Host
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /hosts/{host} [patch]
+func UpdateHost(c *gin.Context) {
+	var upd modelsv1.HostUpdate
+	if err := c.BindJSON(&upd); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	h, err := businessv1.UpdateHost(c.Param("host"), upd)
+	respondHost(c, h, err)
+}
+
+func respondHost(c *gin.Context, h modelsv1.Host, err error) {
+	if errors.Is(err, businessv1.ErrHostNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load host"})
+		return
+	}
+	c.JSON(http.StatusOK, h)
+}
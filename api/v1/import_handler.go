@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportNmapXML godoc
+// @Summary     Import nmap XML
+// @Description Uploads one or more nmap XML files (-oX) from scans run outside the API. Each file becomes a scan with its original timestamps and its hosts feed results, diffs and the inventory like live scans.
+// @Tags        scan
+// @Accept      multipart/form-data
+// @Produce     json
+// @Param       file formData file true "nmap XML output; repeat the field for several files"
+// @Success     201 {object} map[string]interface{}
+// @Failure     400 {object} map[string]interface{}
+// @Router      /import [post]
+func ImportNmapXML(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected one or more nmap XML files in the \"file\" field"})
+		return
+	}
+
+	imports := []modelsv1.ImportSummary{}
+	failures := map[string]string{}
+	invalid := 0
+	for _, fh := range form.File["file"] {
+		f, err := fh.Open()
+		if err != nil {
+			failures[fh.Filename] = err.Error()
+			continue
+		}
+		sum, err := businessv1.ImportNmapXML(c.Request.Context(), f, fh.Filename)
+		f.Close()
+		if err != nil {
+			if errors.Is(err, businessv1.ErrInvalidImport) {
+				invalid++
+			}
+			failures[fh.Filename] = err.Error()
+			continue
+		}
+		imports = append(imports, sum)
+	}
+
+	switch {
+	case len(imports) > 0:
+		body := gin.H{"imports": imports}
+		if len(failures) > 0 {
+			body["errors"] = failures
+		}
+		c.JSON(http.StatusCreated, body)
+	case invalid == len(failures):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file could be imported", "errors": failures})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No file could be imported", "errors": failures})
+	}
+}
@@ -1,6 +1,10 @@
 package v1
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
 	"net"
 	"net/http"
 
@@ -12,19 +16,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Repos are the stores the handlers read from directly; main sets them for
+// the configured database driver.
+var Repos = database.Postgres()
+
 // HandleScanRequest godoc
 // @Summary     Initiate a scan
-// @Description Scans one or more IPs or hostnames in the background and returns a scan ID.
+// @Description Scans one or more IPs, hostnames or asset groups in the background and returns a scan ID. Priority (high, normal, low) decides which queue level the jobs wait in; scans of the same priority take turns. not_before delays the scan; hosts not started by not_after, or never inside their groups' maintenance windows, are skipped with a reason. ports overrides the ports scanned; with shard_size a large range is split into jobs run in parallel and merged into one result per host, marked partial when shards fail. With coalesce, hosts that already have an identical pending or running job attach to it. A retry carrying the same Idempotency-Key returns the original response, including a 500 with the scan_id when the scan failed after part of it was queued.
 // @Tags        scan
 // @Accept      json
 // @Produce     json
+// @Param       Idempotency-Key header string false "Replays the original response for retries of the same request"
 // @Param       request body modelsv1.ScanRequest true "Scan input"
 // @Success     202 {object} map[string]string
 // @Failure     400 {object} map[string]interface{}
+// @Failure     409 {object} map[string]string
+// @Failure     422 {object} map[string]string
+// @Failure     500 {object} map[string]string
 // @Router      /scan [post]
 func HandleScanRequest(c *gin.Context) {
 	var req modelsv1.ScanRequest
-	if err := c.BindJSON(&req); err != nil || len(req.Hosts) == 0 {
+	if err := c.BindJSON(&req); err != nil || (len(req.Hosts) == 0 && len(req.Groups) == 0) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
@@ -43,19 +55,78 @@ func HandleScanRequest(c *gin.Context) {
 		return
 	}
 
-	scanID, err := businessv1.QueueScan(c, req.Hosts)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Some Error Occourred At Backed",
-			"invalid": err.Error(),
-		})
+	ctx := c.Request.Context()
+	key, idempotent := c.Request.Header["Idempotency-Key"]
+	if idempotent {
+		stored, err := businessv1.BeginIdempotent(ctx, key[0], req)
+		switch {
+		case errors.Is(err, businessv1.ErrIdempotencyKeyInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, businessv1.ErrIdempotencyKeyInFlight):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, businessv1.ErrIdempotencyKeyReused):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		case stored != nil:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+			return
+		}
 	}
 
-	// for success
-	c.JSON(http.StatusAccepted, gin.H{
+	var stop func()
+	if idempotent {
+		stop = businessv1.HoldIdempotent(ctx, key[0], req)
+	}
+	scanID, err := businessv1.QueueScan(ctx, req)
+	if idempotent {
+		stop()
+	}
+	if err != nil && scanID == "" && idempotent {
+		// Nothing was queued: a retry may run anew.
+		if err := businessv1.AbandonIdempotent(context.WithoutCancel(ctx), key[0]); err != nil {
+			log.Printf("Failed to release Idempotency-Key: %v", err)
+		}
+	}
+	if errors.Is(err, businessv1.ErrGroupNotFound) || errors.Is(err, businessv1.ErrNoTargets) || errors.Is(err, businessv1.ErrInvalidPriority) ||
+		errors.Is(err, businessv1.ErrInvalidSchedule) || errors.Is(err, businessv1.ErrInvalidPorts) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusAccepted
+	body, _ := json.Marshal(gin.H{
 		"message": "Scan scheduled",
 		"scan_id": scanID,
 	})
+	if err != nil {
+		failure := gin.H{
+			"error":   "Some Error Occourred At Backed",
+			"invalid": err.Error(),
+		}
+		if scanID == "" {
+			c.JSON(http.StatusInternalServerError, failure)
+			return
+		}
+		// Part of the scan was queued under this ID.
+		failure["scan_id"] = scanID
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(failure)
+	}
+	if idempotent {
+		// Hosts are queued either way, so a retry replays this response
+		// rather than queueing them again; a lost record only costs a retry
+		// its replay.
+		if err := businessv1.FinishIdempotent(context.WithoutCancel(ctx), key[0], req, status, body); err != nil {
+			log.Printf("Failed to record Idempotency-Key response: %v", err)
+		}
+	}
+	c.Data(status, "application/json; charset=utf-8", body)
 }
 
 // GetScanResults godoc
@@ -98,7 +169,7 @@ func GetScanDiff(c *gin.Context) {
 
 // GetScanStatus godoc
 // @Summary     Get scan job status
-// @Description Returns progress status for a scan ID, including host-wise scan completion states.
+// @Description Returns progress status for a scan ID, including host-wise scan completion states, the recorded group expansion and, while hosts are pending, the scan's queue position and estimated start per queue.
 // @Tags        scan
 // @Produce     json
 // @Param       scan_id path string true "Scan ID"
@@ -107,14 +178,32 @@ func GetScanDiff(c *gin.Context) {
 // @Router      /scan/status/{scan_id} [get]
 func GetScanStatus(c *gin.Context) {
 	scanID := c.Param("scan_id")
-	statuses, err := database.GetScanStatuses(scanID)
+	statuses, err := Repos.Statuses.GetScanStatuses(scanID)
 	if err != nil || len(statuses) == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Scan ID not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"scan_id":  scanID,
 		"statuses": statuses,
-	})
+	}
+	if scan, err := Repos.Scans.GetScan(scanID); err == nil {
+		resp["scan"] = scan
+		if hasPending(statuses) {
+			if queue, err := businessv1.ScanQueue(c.Request.Context(), scan); err == nil {
+				resp["queue"] = queue
+			}
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func hasPending(statuses []map[string]string) bool {
+	for _, s := range statuses {
+		if s["status"] == "pending" {
+			return true
+		}
+	}
+	return false
 }
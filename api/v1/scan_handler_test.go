@@ -21,12 +21,12 @@ import (
 )
 
 // mockQueueScan replaces real QueueScan
-var mockQueueScanFunc func(context.Context, []string) (string, error)
+var mockQueueScanFunc func(context.Context, modelsv1.ScanRequest) (string, error)
 
 func init() {
 	// Override actual implementation
-	businessv1.QueueScan = func(c context.Context, hosts []string) (string, error) {
-		return mockQueueScanFunc(c, hosts)
+	businessv1.QueueScan = func(c context.Context, req modelsv1.ScanRequest) (string, error) {
+		return mockQueueScanFunc(c, req)
 	}
 }
 
@@ -85,7 +85,7 @@ func TestHandleScanRequest_InvalidHost(t *testing.T) {
 func TestHandleScanRequest_BackendError(t *testing.T) {
 	router := setupRouter()
 
-	mockQueueScanFunc = func(c context.Context, hosts []string) (string, error) {
+	mockQueueScanFunc = func(c context.Context, req modelsv1.ScanRequest) (string, error) {
 		return "", errors.New("backend failed")
 	}
 
@@ -103,7 +103,7 @@ func TestHandleScanRequest_BackendError(t *testing.T) {
 func TestHandleScanRequest_Success(t *testing.T) {
 	router := setupRouter()
 
-	mockQueueScanFunc = func(c context.Context, hosts []string) (string, error) {
+	mockQueueScanFunc = func(c context.Context, req modelsv1.ScanRequest) (string, error) {
 		return "12345", nil
 	}
 
@@ -117,3 +117,101 @@ func TestHandleScanRequest_Success(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusAccepted, w.Code)
 }
+
+func TestHandleScanRequest_IdempotencyKeyReplaysResponse(t *testing.T) {
+	router := setupRouter()
+	begin, finish := businessv1.BeginIdempotent, businessv1.FinishIdempotent
+	t.Cleanup(func() { businessv1.BeginIdempotent, businessv1.FinishIdempotent = begin, finish })
+
+	stored := map[string]*modelsv1.IdempotentResponse{}
+	businessv1.BeginIdempotent = func(c context.Context, key string, req modelsv1.ScanRequest) (*modelsv1.IdempotentResponse, error) {
+		r, ok := stored[key]
+		if !ok {
+			return nil, nil
+		}
+		if r.Fingerprint != req.Hosts[0] {
+			return nil, businessv1.ErrIdempotencyKeyReused
+		}
+		return r, nil
+	}
+	businessv1.FinishIdempotent = func(c context.Context, key string, req modelsv1.ScanRequest, status int, body []byte) error {
+		stored[key] = &modelsv1.IdempotentResponse{Fingerprint: req.Hosts[0], Status: status, Body: body}
+		return nil
+	}
+	calls := 0
+	mockQueueScanFunc = func(c context.Context, req modelsv1.ScanRequest) (string, error) {
+		calls++
+		return "first-scan", nil
+	}
+
+	post := func(host string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(modelsv1.ScanRequest{Hosts: []string{host}})
+		req := httptest.NewRequest(http.MethodPost, "/v1/scan", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "retry-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("example.com")
+	retry := post("example.com")
+	assert.Equal(t, http.StatusAccepted, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, post("10.0.0.1").Code)
+}
+
+func TestHandleScanRequest_IdempotencyKeyKeptWhenPartlyQueued(t *testing.T) {
+	router := setupRouter()
+	begin, hold, finish, abandon := businessv1.BeginIdempotent, businessv1.HoldIdempotent, businessv1.FinishIdempotent, businessv1.AbandonIdempotent
+	t.Cleanup(func() {
+		businessv1.BeginIdempotent, businessv1.HoldIdempotent = begin, hold
+		businessv1.FinishIdempotent, businessv1.AbandonIdempotent = finish, abandon
+	})
+
+	var recorded *modelsv1.IdempotentResponse
+	held, abandoned := false, false
+	businessv1.BeginIdempotent = func(c context.Context, key string, req modelsv1.ScanRequest) (*modelsv1.IdempotentResponse, error) {
+		return recorded, nil
+	}
+	businessv1.HoldIdempotent = func(c context.Context, key string, req modelsv1.ScanRequest) func() {
+		held = true
+		return func() {}
+	}
+	businessv1.FinishIdempotent = func(c context.Context, key string, req modelsv1.ScanRequest, status int, body []byte) error {
+		recorded = &modelsv1.IdempotentResponse{Status: status, Body: body}
+		return nil
+	}
+	businessv1.AbandonIdempotent = func(c context.Context, key string) error {
+		abandoned = true
+		return nil
+	}
+	calls := 0
+	mockQueueScanFunc = func(c context.Context, req modelsv1.ScanRequest) (string, error) {
+		calls++
+		return "partial-scan", errors.New("status store down")
+	}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/scan", bytes.NewBufferString(`{"hosts":["example.com"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "retry-2")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post()
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+	assert.Contains(t, first.Body.String(), `"scan_id":"partial-scan"`)
+	assert.True(t, held, "the reservation is renewed while the scan is queued")
+	assert.False(t, abandoned, "hosts already queued must not be queued again by a retry")
+
+	retry := post()
+	assert.Equal(t, http.StatusInternalServerError, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, calls)
+}
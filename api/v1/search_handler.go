@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHostsByPort godoc
+// @Summary     Hosts exposing a port
+// @Description Lists hosts whose latest scan (or any scan within seen_within) shows the port open.
+// @Tags        search
+// @Produce     json
+// @Param       port path int true "Port number"
+// @Param       protocol query string false "tcp, udp or sctp"
+// @Param       seen_within query string false "Look back window instead of latest scan, e.g. 24h"
+// @Param       limit query int false "Maximum number of hits (default 1000)"
+// @Success     200 {array} modelsv1.PortSearchHit
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /ports/{port}/hosts [get]
+func GetHostsByPort(c *gin.Context) {
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+		return
+	}
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.Port = port
+	respondSearch(c, q)
+}
+
+// SearchPorts godoc
+// @Summary     Search open ports across hosts
+// @Description Filters open ports by port, protocol, service name or product across all hosts. Products are only known for imported scans and scans run with -sV added to nmap.args.
+// @Tags        search
+// @Produce     json
+// @Param       port query int false "Port number"
+// @Param       protocol query string false "tcp, udp or sctp"
+// @Param       service query string false "Service name, e.g. ssh"
+// @Param       product query string false "Product substring, e.g. OpenSSH"
+// @Param       seen_within query string false "Look back window instead of latest scan, e.g. 24h"
+// @Param       limit query int false "Maximum number of hits (default 1000)"
+// @Success     200 {array} modelsv1.PortSearchHit
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /search [get]
+func SearchPorts(c *gin.Context) {
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if p := c.Query("port"); p != "" {
+		if q.Port, err = strconv.Atoi(p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+			return
+		}
+	}
+	respondSearch(c, q)
+}
+
+func parseSearchQuery(c *gin.Context) (modelsv1.PortSearchQuery, error) {
+	q := modelsv1.PortSearchQuery{
+		Protocol: c.Query("protocol"),
+		Service:  c.Query("service"),
+		Product:  c.Query("product"),
+	}
+	if w := c.Query("seen_within"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil || d <= 0 {
+			return q, errors.New("Invalid seen_within duration")
+		}
+		q.SeenWithin = d
+	}
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return q, errors.New("Invalid limit")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+func respondSearch(c *gin.Context, q modelsv1.PortSearchQuery) {
+	hits, err := businessv1.SearchPorts(q)
+	if errors.Is(err, businessv1.ErrInvalidSearch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+	c.JSON(http.StatusOK, hits)
+}
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	businessv1 "nmap-rest-api/business/v1"
+	modelsv1 "nmap-rest-api/models/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateZone godoc
+// @Summary     Create a network zone
+// @Description Creates a zone: targets inside its CIDRs (by IP, or by an inventory host's resolved IPs) are queued for the remote agents registered in it instead of the central workers.
+// @Tags        agents
+// @Accept      json
+// @Produce     json
+// @Param       request body modelsv1.Zone true "Zone definition"
+// @Success     201 {object} modelsv1.Zone
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     501 {object} map[string]string
+// @Router      /zones [post]
+func CreateZone(c *gin.Context) {
+	var z modelsv1.Zone
+	if err := c.BindJSON(&z); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := businessv1.CreateZone(z); err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	respondZone(c, http.StatusCreated, z.Name)
+}
+
+// ReplaceZone godoc
+// @Summary     Replace a network zone
+// @Description Replaces the CIDRs and description of a zone. Only scans queued afterwards are routed by the new CIDRs.
+// @Tags        agents
+// @Accept      json
+// @Produce     json
+// @Param       name path string true "Zone name"
+// @Param       request body modelsv1.Zone true "Zone definition"
+// @Success     200 {object} modelsv1.Zone
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /zones/{name} [put]
+func ReplaceZone(c *gin.Context) {
+	var z modelsv1.Zone
+	if err := c.BindJSON(&z); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	z.Name = c.Param("name")
+	if err := businessv1.ReplaceZone(z); err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	respondZone(c, http.StatusOK, z.Name)
+}
+
+// ListZones godoc
+// @Summary     List network zones
+// @Tags        agents
+// @Produce     json
+// @Success     200 {array} modelsv1.Zone
+// @Failure     500 {object} map[string]string
+// @Router      /zones [get]
+func ListZones(c *gin.Context) {
+	zones, err := businessv1.ListZones()
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, zones)
+}
+
+// GetZone godoc
+// @Summary     Get a network zone
+// @Tags        agents
+// @Produce     json
+// @Param       name path string true "Zone name"
+// @Success     200 {object} modelsv1.Zone
+// @Failure     404 {object} map[string]string
+// @Router      /zones/{name} [get]
+func GetZone(c *gin.Context) {
+	respondZone(c, http.StatusOK, c.Param("name"))
+}
+
+// DeleteZone godoc
+// @Summary     Delete a network zone
+// @Description Deletes the zone. Its targets go to the central workers from then on; jobs already queued for it wait for its agents.
+// @Tags        agents
+// @Param       name path string true "Zone name"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Router      /zones/{name} [delete]
+func DeleteZone(c *gin.Context) {
+	if err := businessv1.DeleteZone(c.Param("name")); err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondZone(c *gin.Context, status int, name string) {
+	z, err := businessv1.GetZone(name)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	c.JSON(status, z)
+}
+
+func respondZoneError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, businessv1.ErrInvalidZone):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, businessv1.ErrZoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Zone not found"})
+	case errors.Is(err, businessv1.ErrZoneExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Zone already exists"})
+	case errors.Is(err, businessv1.ErrNeedsPostgres):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Zone operation failed"})
+	}
+}
@@ -0,0 +1,345 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"nmap-rest-api/config"
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	RegisterAgent      = registerAgent
+	AuthenticateAgent  = authenticateAgent
+	AgentHeartbeat     = agentHeartbeat
+	LeaseAgentJobs     = leaseAgentJobs
+	CompleteAgentJob   = completeAgentJob
+	ListAgents         = listAgents
+	DeleteAgent        = deleteAgent
+	RequeueExpiredJobs = requeueExpiredJobs
+
+	ErrEnrollmentDisabled = errors.New("agent registration is disabled")
+	ErrAgentUnauthorized  = errors.New("invalid agent token")
+	ErrAgentNotFound      = errors.New("agent not found")
+	ErrInvalidAgent       = errors.New("invalid agent")
+	ErrLeaseNotFound      = errors.New("job is not leased to this agent")
+)
+
+// Agents configures agent registration and job leases; main sets it from the
+// config.
+var Agents = config.Default().Agents
+
+// leasesKey is the Redis hash of jobs handed to agents, keyed by
+// "<scan_id>|<host>". A lease the agent stops renewing is requeued.
+const leasesKey = "agent_leases"
+
+// MaxPollWait caps how long a job poll holds its request open.
+const MaxPollWait = 30 * time.Second
+
+type lease struct {
+	AgentID int64          `json:"agent_id"`
+	Job     models.ScanJob `json:"job"`
+	Expires int64          `json:"expires"` // Unix seconds
+}
+
+// leaseField identifies a job among the running ones; shards of one host are
+// told apart by their index. Agents track their jobs by the same key.
+func leaseField(job models.ScanJob) string {
+	return job.RunKey()
+}
+
+// registerAgent checks the enrollment token and issues the agent a fresh
+// bearer token. Only its hash is stored.
+func registerAgent(enrollmentToken string, reg models.AgentRegistration, remoteAddr string) (models.AgentCredentials, error) {
+	var creds models.AgentCredentials
+	if Agents.EnrollmentToken == "" {
+		return creds, ErrEnrollmentDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(enrollmentToken), []byte(Agents.EnrollmentToken)) != 1 {
+		return creds, ErrAgentUnauthorized
+	}
+	if !groupNameRegex.MatchString(reg.Name) {
+		return creds, fmt.Errorf("%w: name must be lowercase letters, digits, '.', '_' or '-'", ErrInvalidAgent)
+	}
+	if reg.Capacity < 1 {
+		reg.Capacity = 1
+	}
+	if reg.Zone != "" {
+		if _, err := getZone(reg.Zone); err != nil {
+			return creds, err
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return creds, err
+	}
+	creds.Token = hex.EncodeToString(raw)
+	id, err := database.RegisterAgent(reg, hashToken(creds.Token), remoteAddr)
+	if err != nil {
+		return models.AgentCredentials{}, err
+	}
+	creds.ID = id
+	log.Printf("Agent %s registered (zone %q, version %s, capacity %d)", reg.Name, reg.Zone, reg.Version, reg.Capacity)
+	return creds, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAgent resolves a bearer token to its agent.
+func authenticateAgent(token string) (models.Agent, error) {
+	if token == "" {
+		return models.Agent{}, ErrAgentUnauthorized
+	}
+	a, err := database.GetAgentByKey(hashToken(token))
+	return a, notFound(err, ErrAgentUnauthorized)
+}
+
+// agentHeartbeat records the agent's state and renews the leases of the jobs
+// it reports as still running.
+func agentHeartbeat(ctx context.Context, a models.Agent, hb models.AgentHeartbeat, remoteAddr string) error {
+	if err := database.TouchAgent(a.ID, &hb, remoteAddr); err != nil {
+		return err
+	}
+	expires := utils.Now().Add(Agents.LeaseTimeout.D())
+	for _, job := range hb.Jobs {
+		// A lease requeued meanwhile must stay gone, or the job would run
+		// twice; renewing only an existing lease of this agent is one step.
+		renewed, err := Queue.Leases.RenewLease(ctx, leaseField(job), a.ID, expires)
+		if err != nil {
+			return err
+		}
+		if !renewed {
+			continue
+		}
+		if err := RenewTarget(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaseAgentJobs waits up to wait for jobs in the agent's zone and hands out
+// at most max of them, each claimed and leased to the agent for
+// Agents.LeaseTimeout. Jobs whose target is busy are deferred. The pop never
+// uses the request context: a job taken from the queue is always leased or put
+// back, so a dropped connection only delays it until the lease expires.
+func leaseAgentJobs(ctx context.Context, a models.Agent, max int, wait time.Duration, remoteAddr string) ([]models.ScanJob, error) {
+	if err := database.TouchAgent(a.ID, nil, remoteAddr); err != nil {
+		return nil, err
+	}
+	if max < 1 || max > a.Capacity {
+		max = a.Capacity
+	}
+	if wait > MaxPollWait {
+		wait = MaxPollWait
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	popped, err := WaitJobs(ctx, a.Zone, max, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]models.ScanJob, 0, len(popped))
+	for _, job := range popped {
+		claimed, ok, err := ClaimTarget(ctx, job)
+		if err == nil && !ok {
+			continue
+		}
+		if err == nil {
+			job = claimed
+			if err = putLease(ctx, a.ID, job); err != nil {
+				ReleaseTarget(ctx, job)
+			}
+		}
+		if err != nil {
+			// Not leased means nobody would requeue it; put it back now.
+			log.Printf("Failed to lease %s to agent %s: %v", job.Host, a.Name, err)
+			if err := RequeueJob(ctx, job); err != nil {
+				log.Printf("Failed to requeue %s for scan %s: %v", job.Host, job.ScanID, err)
+			}
+			continue
+		}
+		if err := UpdateScanStatus(ctx, job.ScanID, job.Host, "in_progress"); err != nil {
+			log.Printf("Failed to mark %s in progress: %v", job.Host, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// completeAgentJob releases the job's lease and stores the uploaded result.
+// A result for a lease that expired (and was requeued) or belongs to another
+// agent is rejected with ErrLeaseNotFound.
+func completeAgentJob(ctx context.Context, a models.Agent, up models.AgentResult) error {
+	l, err := getLease(ctx, up.Job)
+	if errors.Is(err, ErrLeaseNotFound) || (err == nil && l.AgentID != a.ID) {
+		return ErrLeaseNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// Whoever deletes the lease owns the job, which settles a race with the
+	// expiry sweep.
+	deleted, err := Queue.Leases.DeleteLease(ctx, leaseField(up.Job))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrLeaseNotFound
+	}
+	if err := ReleaseTarget(ctx, l.Job); err != nil {
+		log.Printf("Failed to release claim on %s: %v", l.Job.Host, err)
+	}
+
+	res := up.Result
+	res.ScanID, res.Host = l.Job.ScanID, l.Job.Host
+	if res.ScannedAt.IsZero() {
+		res.ScannedAt = utils.Now()
+	}
+	return PersistJobResult(ctx, l.Job, res)
+}
+
+// requeueExpiredJobs hands the jobs of agents that stopped renewing their
+// leases back to their zone's queue and returns how many it requeued.
+func requeueExpiredJobs(ctx context.Context) (int, error) {
+	all, err := Queue.Leases.Leases(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := utils.Now().Unix()
+	requeued := 0
+	for field, v := range all {
+		var l lease
+		if err := json.Unmarshal(v, &l); err != nil || l.Expires > now {
+			continue
+		}
+		deleted, err := Queue.Leases.DeleteLease(ctx, field)
+		if err != nil {
+			return requeued, err
+		}
+		if !deleted {
+			continue // completed meanwhile
+		}
+		log.Printf("Lease of %s (scan %s) by agent %d expired, requeueing", l.Job.Host, l.Job.ScanID, l.AgentID)
+		if err := ReleaseTarget(ctx, l.Job); err != nil {
+			return requeued, err
+		}
+		if err := RequeueJob(ctx, l.Job); err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+	return requeued, nil
+}
+
+// getLease returns ErrLeaseNotFound when the job is not leased.
+func getLease(ctx context.Context, job models.ScanJob) (lease, error) {
+	var l lease
+	v, ok, err := Queue.Leases.GetLease(ctx, leaseField(job))
+	if err != nil {
+		return l, err
+	}
+	if !ok {
+		return l, ErrLeaseNotFound
+	}
+	err = json.Unmarshal(v, &l)
+	return l, err
+}
+
+func putLease(ctx context.Context, agentID int64, job models.ScanJob) error {
+	b, err := json.Marshal(lease{AgentID: agentID, Job: job, Expires: utils.Now().Add(Agents.LeaseTimeout.D()).Unix()})
+	if err != nil {
+		return err
+	}
+	return Queue.Leases.PutLease(ctx, leaseField(job), b)
+}
+
+func (redisQueue) PutLease(ctx context.Context, field string, lease []byte) error {
+	return database.RDB.HSet(ctx, leasesKey, field, lease).Err()
+}
+
+// renewLeaseScript sets the expiry of lease ARGV[1] to ARGV[3] if it is
+// held by agent ARGV[2]. expires is the lease's last field.
+var renewLeaseScript = redis.NewScript(`
+local v = redis.call("HGET", KEYS[1], ARGV[1])
+if not v or cjson.decode(v).agent_id ~= tonumber(ARGV[2]) then
+	return 0
+end
+v = string.gsub(v, '"expires":%-?%d+}$', '"expires":' .. ARGV[3] .. '}')
+redis.call("HSET", KEYS[1], ARGV[1], v)
+return 1`)
+
+func (redisQueue) RenewLease(ctx context.Context, field string, agentID int64, expires time.Time) (bool, error) {
+	n, err := renewLeaseScript.Run(ctx, database.RDB, []string{leasesKey}, field, agentID, expires.Unix()).Int()
+	return n == 1, err
+}
+
+func (redisQueue) GetLease(ctx context.Context, field string) ([]byte, bool, error) {
+	v, err := database.RDB.HGet(ctx, leasesKey, field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return v, err == nil, err
+}
+
+func (redisQueue) DeleteLease(ctx context.Context, field string) (bool, error) {
+	n, err := database.RDB.HDel(ctx, leasesKey, field).Result()
+	return n == 1, err
+}
+
+func (redisQueue) Leases(ctx context.Context) (map[string][]byte, error) {
+	all, err := database.RDB.HGetAll(ctx, leasesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	leases := make(map[string][]byte, len(all))
+	for field, v := range all {
+		leases[field] = []byte(v)
+	}
+	return leases, nil
+}
+
+// listAgents returns every registered agent, marking those heard from within
+// Agents.OfflineAfter as online.
+func listAgents() ([]models.Agent, error) {
+	agents, err := database.ListAgents()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := utils.Now().Add(-Agents.OfflineAfter.D())
+	for i := range agents {
+		agents[i].Online = agents[i].LastSeen.After(cutoff)
+	}
+	return agents, nil
+}
+
+// deleteAgent revokes the agent's token. Its leased jobs are requeued when
+// the leases expire.
+func deleteAgent(id string) error {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrAgentNotFound
+	}
+	return notFound(database.DeleteAgent(n), ErrAgentNotFound)
+}
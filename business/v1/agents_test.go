@@ -0,0 +1,182 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLease struct {
+	AgentID int64          `json:"agent_id"`
+	Job     models.ScanJob `json:"job"`
+	Expires int64          `json:"expires"`
+}
+
+func leaseJSON(agentID int64, job models.ScanJob, expires time.Time) []byte {
+	b, _ := json.Marshal(testLease{AgentID: agentID, Job: job, Expires: expires.Unix()})
+	return b
+}
+
+func TestRegisterAgent_ChecksEnrollmentToken(t *testing.T) {
+	business.Agents.EnrollmentToken = ""
+	_, err := business.RegisterAgent("anything", models.AgentRegistration{Name: "dmz-1"}, "")
+	assert.ErrorIs(t, err, business.ErrEnrollmentDisabled)
+
+	business.Agents.EnrollmentToken = "s3cret"
+	defer func() { business.Agents.EnrollmentToken = "" }()
+	_, err = business.RegisterAgent("wrong", models.AgentRegistration{Name: "dmz-1"}, "")
+	assert.ErrorIs(t, err, business.ErrAgentUnauthorized)
+
+	var storedHash string
+	database.RegisterAgent = func(reg models.AgentRegistration, tokenHash, remoteAddr string) (int64, error) {
+		storedHash = tokenHash
+		assert.Equal(t, 1, reg.Capacity)
+		return 7, nil
+	}
+	database.GetAgentByKey = func(tokenHash string) (models.Agent, error) {
+		assert.Equal(t, storedHash, tokenHash)
+		return models.Agent{ID: 7, Name: "dmz-1"}, nil
+	}
+	creds, err := business.RegisterAgent("s3cret", models.AgentRegistration{Name: "dmz-1"}, "10.1.0.2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), creds.ID)
+	assert.Len(t, creds.Token, 64)
+	assert.NotEqual(t, creds.Token, storedHash, "only the hash is stored")
+
+	a, err := business.AuthenticateAgent(creds.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), a.ID)
+}
+
+func TestLeaseAgentJobs_LeasesUpToCapacity(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	database.TouchAgent = func(id int64, hb *models.AgentHeartbeat, remoteAddr string) error { return nil }
+	repos := withMemory(t)
+
+	agent := models.Agent{ID: 3, Name: "dmz-1", Zone: "dmz", Capacity: 2}
+	first := models.ScanJob{ScanID: "s1", Host: "10.1.0.7", Zone: "dmz"}
+	second := models.ScanJob{ScanID: "s1", Host: "10.1.0.8", Zone: "dmz"}
+	expires := enqueuedAt.Add(business.Agents.LeaseTimeout.D())
+
+	waitJobs := business.WaitJobs
+	defer func() { business.WaitJobs = waitJobs }()
+	business.WaitJobs = func(ctx context.Context, zone string, max int, timeout time.Duration) ([]models.ScanJob, error) {
+		assert.Equal(t, "dmz", zone)
+		assert.Equal(t, 2, max, "capped at the agent's capacity")
+		assert.Equal(t, 5*time.Second, timeout)
+		return []models.ScanJob{first, second}, nil
+	}
+	mockRedis.ExpectHSet("agent_leases", "s1|10.1.0.7", leaseJSON(3, first, expires)).SetVal(1)
+	mockRedis.ExpectHSet("agent_leases", "s1|10.1.0.8", leaseJSON(3, second, expires)).SetVal(1)
+
+	jobs, err := business.LeaseAgentJobs(context.Background(), agent, 10, 5*time.Second, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ScanJob{first, second}, jobs)
+	assert.Equal(t, []string{"10.1.0.7=in_progress", "10.1.0.8=in_progress"}, statuses(t, repos, "s1"))
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestCompleteAgentJob_RejectsForeignLease(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	job := models.ScanJob{ScanID: "s1", Host: "10.1.0.7", Zone: "dmz"}
+	mockRedis.ExpectHGet("agent_leases", "s1|10.1.0.7").SetVal(string(leaseJSON(4, job, enqueuedAt)))
+
+	err := business.CompleteAgentJob(context.Background(), models.Agent{ID: 3}, models.AgentResult{Job: job})
+
+	assert.ErrorIs(t, err, business.ErrLeaseNotFound)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestCompleteAgentJob_StoresResult(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	persist := business.PersistResult
+	defer func() { business.PersistResult = persist }()
+	var stored models.ScanResult
+	business.PersistResult = func(ctx context.Context, res models.ScanResult) error {
+		stored = res
+		return nil
+	}
+
+	job := models.ScanJob{ScanID: "s1", Host: "10.1.0.7", Zone: "dmz"}
+	mockRedis.ExpectHGet("agent_leases", "s1|10.1.0.7").SetVal(string(leaseJSON(3, job, enqueuedAt)))
+	mockRedis.ExpectHDel("agent_leases", "s1|10.1.0.7").SetVal(1)
+
+	err := business.CompleteAgentJob(context.Background(), models.Agent{ID: 3}, models.AgentResult{
+		Job:    job,
+		Result: models.ScanResult{ScanID: "other", Host: "other", OpenPorts: []int{22}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", stored.ScanID, "identity comes from the lease, not the upload")
+	assert.Equal(t, "10.1.0.7", stored.Host)
+	assert.Equal(t, enqueuedAt, stored.ScannedAt)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestRequeueExpiredJobs(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	withMemory(t)
+
+	expired := models.ScanJob{ScanID: "s1", Host: "10.1.0.7", Zone: "dmz"}
+	live := models.ScanJob{ScanID: "s1", Host: "10.1.0.8", Zone: "dmz"}
+	mockRedis.ExpectHGetAll("agent_leases").SetVal(map[string]string{
+		"s1|10.1.0.7": string(leaseJSON(3, expired, enqueuedAt.Add(-time.Second))),
+		"s1|10.1.0.8": string(leaseJSON(3, live, enqueuedAt.Add(time.Minute))),
+	})
+	mockRedis.ExpectHDel("agent_leases", "s1|10.1.0.7").SetVal(1)
+	expectPush(mockRedis, expired, "front").SetVal(int64(1))
+
+	n, err := business.RequeueExpiredJobs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestAgentHeartbeat_RenewsOnlyItsLiveLeases(t *testing.T) {
+	ctx := context.Background()
+	database.TouchAgent = func(id int64, hb *models.AgentHeartbeat, remoteAddr string) error { return nil }
+	withMemory(t)
+	q := withMemoryQueue(t, "")
+	mine := models.ScanJob{ScanID: "s1", Host: "10.1.0.7", Zone: "dmz"}
+	theirs := models.ScanJob{ScanID: "s1", Host: "10.1.0.8", Zone: "dmz"}
+	requeued := models.ScanJob{ScanID: "s1", Host: "10.1.0.9", Zone: "dmz"}
+	require.NoError(t, q.PutLease(ctx, "s1|10.1.0.7", leaseJSON(3, mine, enqueuedAt)))
+	require.NoError(t, q.PutLease(ctx, "s1|10.1.0.8", leaseJSON(4, theirs, enqueuedAt)))
+
+	hb := models.AgentHeartbeat{Jobs: []models.ScanJob{mine, theirs, requeued}}
+	require.NoError(t, business.AgentHeartbeat(ctx, models.Agent{ID: 3}, hb, ""))
+
+	leases, err := q.Leases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"s1|10.1.0.7": leaseJSON(3, mine, enqueuedAt.Add(business.Agents.LeaseTimeout.D())),
+		"s1|10.1.0.8": leaseJSON(4, theirs, enqueuedAt),
+	}, leases, "a lease requeued meanwhile is not brought back")
+}
+
+func TestRedisQueue_RenewLeaseIsOneScript(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	mockRedis.ExpectEvalSha(business.RenewLeaseScriptHash, []string{"agent_leases"}, "s1|10.1.0.7", int64(3), enqueuedAt.Unix()).SetVal(int64(0))
+
+	renewed, err := business.RedisQueue().Leases.RenewLease(context.Background(), "s1|10.1.0.7", 3, enqueuedAt)
+
+	assert.NoError(t, err)
+	assert.False(t, renewed)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
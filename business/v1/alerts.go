@@ -0,0 +1,442 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/notify"
+	"nmap-rest-api/utils"
+)
+
+const (
+	maxAlertsLimit     = 500
+	defaultDedupWindow = time.Hour
+	notifyTimeout      = 10 * time.Second
+)
+
+var (
+	CreateAlertRule  = createAlertRule
+	ReplaceAlertRule = replaceAlertRule
+	GetAlertRule     = getAlertRule
+	ListAlertRules   = listAlertRules
+	DeleteAlertRule  = deleteAlertRule
+	EvaluateAlerts   = evaluateAlerts
+	ListAlerts       = listAlerts
+	CreateSilence    = createSilence
+	ListSilences     = listSilences
+	DeleteSilence    = deleteSilence
+	CreateChannel    = createChannel
+	ListChannels     = listChannels
+	DeleteChannel    = deleteChannel
+	DispatchAlert    = dispatchAlert
+
+	ErrRuleNotFound    = errors.New("alert rule not found")
+	ErrRuleExists      = errors.New("alert rule already exists")
+	ErrInvalidRule     = errors.New("invalid alert rule")
+	ErrSilenceNotFound = errors.New("silence not found")
+	ErrInvalidSilence  = errors.New("invalid silence")
+	ErrChannelNotFound = errors.New("channel not found")
+	ErrChannelExists   = errors.New("channel already exists")
+	ErrInvalidChannel  = errors.New("invalid channel")
+)
+
+var channelNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+// dispatches counts alert deliveries still running.
+var dispatches sync.WaitGroup
+
+func createAlertRule(r models.AlertRule) (models.AlertRule, error) {
+	if err := validateAlertRule(&r); err != nil {
+		return r, err
+	}
+	id, err := database.CreateAlertRule(r)
+	if errors.Is(err, database.ErrConflict) {
+		return r, ErrRuleExists
+	}
+	if err != nil {
+		return r, err
+	}
+	return getAlertRule(id)
+}
+
+func replaceAlertRule(r models.AlertRule) (models.AlertRule, error) {
+	if err := validateAlertRule(&r); err != nil {
+		return r, err
+	}
+	err := database.ReplaceAlertRule(r)
+	if errors.Is(err, database.ErrConflict) {
+		return r, ErrRuleExists
+	}
+	if err := notFound(err, ErrRuleNotFound); err != nil {
+		return r, err
+	}
+	return getAlertRule(r.ID)
+}
+
+func getAlertRule(id int64) (models.AlertRule, error) {
+	r, err := database.GetAlertRule(id)
+	return r, notFound(err, ErrRuleNotFound)
+}
+
+func listAlertRules() ([]models.AlertRule, error) {
+	return database.ListAlertRules()
+}
+
+func deleteAlertRule(id int64) error {
+	return notFound(database.DeleteAlertRule(id), ErrRuleNotFound)
+}
+
+// evaluateAlerts runs every enabled rule against a stored result, the host's
+// previous result and the baseline violations the result opened. Fired
+// alerts are stored; new, unsilenced ones are sent to the rule's channels.
+func evaluateAlerts(ctx context.Context, res models.ScanResult, violations []models.Violation) ([]models.Alert, error) {
+	rules, err := database.ListAlertRules()
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	var prev *models.ScanResult
+	p, err := Repos.Results.GetPreviousResult(res.Host, res.ScannedAt)
+	switch {
+	case err == nil:
+		prev = &p
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	silences, err := database.ListSilences(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []models.Alert
+	m := membership{host: res.Host}
+	for _, rule := range rules {
+		if rule.Disabled || (rule.Group != "" && !m.in(rule.Group)) {
+			continue
+		}
+		ports, message, ok := EvaluateRule(rule, res, prev, violations)
+		if !ok {
+			continue
+		}
+
+		alert := models.Alert{
+			RuleID:      rule.ID,
+			RuleName:    rule.Name,
+			Severity:    rule.Severity,
+			Host:        res.Host,
+			ScanID:      res.ScanID,
+			Message:     message,
+			Ports:       ports,
+			Fingerprint: fmt.Sprintf("%d|%s|%v", rule.ID, res.Host, ports),
+			FiredAt:     res.ScannedAt,
+			Silenced:    silenced(silences, rule.ID, res.Host),
+		}
+		window := time.Duration(rule.DedupWindowSeconds) * time.Second
+		alert, created, err := database.RecordAlert(alert, window)
+		if err != nil {
+			return fired, err
+		}
+		fired = append(fired, alert)
+		if created && !alert.Silenced && len(rule.Channels) > 0 {
+			dispatches.Add(1)
+			go func() {
+				defer dispatches.Done()
+				DispatchAlert(ctx, alert, rule.Channels)
+			}()
+		}
+	}
+	return fired, nil
+}
+
+// EvaluateRule decides whether rule fires for res. prev is the host's
+// previous result, nil on the first scan. It returns the ports that triggered
+// the rule and a human readable message.
+func EvaluateRule(rule models.AlertRule, res models.ScanResult, prev *models.ScanResult, violations []models.Violation) ([]int, string, bool) {
+	switch rule.Condition {
+	case models.ConditionPortOpen:
+		var hit []int
+		for _, p := range rule.Ports {
+			if containsInt(res.OpenPorts, p) {
+				hit = append(hit, p)
+			}
+		}
+		if len(hit) == 0 {
+			return nil, "", false
+		}
+		return hit, fmt.Sprintf("%s has forbidden port(s) %v open", res.Host, hit), true
+
+	case models.ConditionNewPortOpened, models.ConditionNewPortsExceed:
+		if prev == nil || res.HostState == "down" {
+			return nil, "", false
+		}
+		opened := utils.Diff(res.OpenPorts, prev.OpenPorts)
+		sort.Ints(opened)
+		if rule.Condition == models.ConditionNewPortOpened && len(opened) > 0 {
+			return opened, fmt.Sprintf("%s opened new port(s) %v since scan %s", res.Host, opened, prev.ScanID), true
+		}
+		if rule.Condition == models.ConditionNewPortsExceed && len(opened) > rule.Threshold {
+			return opened, fmt.Sprintf("%s opened %d new ports since scan %s (threshold %d)",
+				res.Host, len(opened), prev.ScanID, rule.Threshold), true
+		}
+		return nil, "", false
+
+	case models.ConditionHostDown:
+		if prev == nil || res.HostState != "down" || !wasUp(*prev) {
+			return nil, "", false
+		}
+		return nil, fmt.Sprintf("%s was up in scan %s and is now down", res.Host, prev.ScanID), true
+
+	case models.ConditionViolationOpened:
+		if len(violations) == 0 {
+			return nil, "", false
+		}
+		var (
+			ports   []int
+			details []string
+		)
+		for _, v := range violations {
+			ports = append(ports, v.Port)
+			details = append(details, v.Detail)
+		}
+		sort.Ints(ports)
+		return ports, fmt.Sprintf("%s violates its baseline: %s", res.Host, strings.Join(details, "; ")), true
+	}
+	return nil, "", false
+}
+
+func wasUp(res models.ScanResult) bool {
+	return res.HostState == "up" || (res.HostState == "" && len(res.OpenPorts) > 0)
+}
+
+func silenced(silences []models.AlertSilence, ruleID int64, host string) bool {
+	for _, s := range silences {
+		if s.RuleID != 0 && s.RuleID != ruleID {
+			continue
+		}
+		if s.Host == "" {
+			return true
+		}
+		if ok, _ := path.Match(s.Host, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitForDispatches waits until the alert deliveries in flight are done or
+// ctx is, so shutdown keeps the database open for them. It reports whether
+// they all finished.
+func WaitForDispatches(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		dispatches.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// dispatchAlert sends an alert to the named channels and records the outcome
+// on the alert. It is run in its own goroutine by evaluateAlerts.
+func dispatchAlert(ctx context.Context, alert models.Alert, channels []string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	configured, err := database.ListChannels()
+	if err != nil {
+		log.Printf("Failed to load alert channels: %v", err)
+		return
+	}
+	byName := make(map[string]models.AlertChannel)
+	for _, ch := range configured {
+		byName[ch.Name] = ch
+	}
+
+	var errs []string
+	for _, name := range channels {
+		ch, ok := byName[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: channel not found", name))
+			continue
+		}
+		n, err := notify.New(ch)
+		if err == nil {
+			err = n.Notify(ctx, alert)
+		}
+		if err != nil {
+			log.Printf("Failed to notify %s about alert %d: %v", name, alert.ID, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if err := database.MarkAlertNotified(alert.ID, strings.Join(errs, "; ")); err != nil {
+		log.Printf("Failed to mark alert %d notified: %v", alert.ID, err)
+	}
+}
+
+func listAlerts(f models.AlertFilter) ([]models.Alert, error) {
+	if f.Limit <= 0 || f.Limit > maxAlertsLimit {
+		f.Limit = maxAlertsLimit
+	}
+	return database.ListAlerts(f)
+}
+
+func createSilence(s models.AlertSilence) (models.AlertSilence, error) {
+	if s.Until.Before(time.Now()) {
+		return s, fmt.Errorf("%w: until must be in the future", ErrInvalidSilence)
+	}
+	if _, err := path.Match(s.Host, ""); err != nil {
+		return s, fmt.Errorf("%w: invalid host pattern %q", ErrInvalidSilence, s.Host)
+	}
+	if s.RuleID != 0 {
+		if _, err := getAlertRule(s.RuleID); err != nil {
+			return s, err
+		}
+	}
+	id, err := database.CreateSilence(s)
+	s.ID = id
+	return s, err
+}
+
+// listSilences returns active silences, or all of them when all is set.
+func listSilences(all bool) ([]models.AlertSilence, error) {
+	if all {
+		return database.ListSilences(time.Time{})
+	}
+	return database.ListSilences(time.Now())
+}
+
+func deleteSilence(id int64) error {
+	return notFound(database.DeleteSilence(id), ErrSilenceNotFound)
+}
+
+// createChannel validates the channel by building its notifier.
+func createChannel(ch models.AlertChannel) error {
+	if !channelNameRegex.MatchString(ch.Name) {
+		return fmt.Errorf("%w: name must match %s", ErrInvalidChannel, channelNameRegex)
+	}
+	if len(ch.Config) == 0 {
+		ch.Config = json.RawMessage("{}")
+	}
+	if _, err := notify.New(ch); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+	}
+	err := database.CreateChannel(ch)
+	if errors.Is(err, database.ErrConflict) {
+		return ErrChannelExists
+	}
+	return err
+}
+
+// listChannels returns the configured channels with secrets redacted.
+func listChannels() ([]models.AlertChannel, error) {
+	channels, err := database.ListChannels()
+	if err != nil {
+		return nil, err
+	}
+	for i := range channels {
+		channels[i].Config = redactConfig(channels[i].Config)
+	}
+	return channels, nil
+}
+
+func deleteChannel(name string) error {
+	return notFound(database.DeleteChannel(name), ErrChannelNotFound)
+}
+
+// redactConfig masks password, token and secret values, including header values.
+func redactConfig(raw json.RawMessage) json.RawMessage {
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return raw
+	}
+	for k, v := range cfg {
+		if isSecretKey(k) {
+			cfg[k] = "REDACTED"
+		}
+		if headers, ok := v.(map[string]interface{}); ok {
+			for h := range headers {
+				headers[h] = "REDACTED"
+			}
+		}
+	}
+	out, _ := json.Marshal(cfg)
+	return out
+}
+
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	return strings.Contains(k, "password") || strings.Contains(k, "token") || strings.Contains(k, "secret")
+}
+
+func validateAlertRule(r *models.AlertRule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	switch r.Condition {
+	case models.ConditionPortOpen:
+		if len(r.Ports) == 0 {
+			return fmt.Errorf("%w: %s needs ports", ErrInvalidRule, r.Condition)
+		}
+	case models.ConditionNewPortsExceed:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("%w: %s needs a positive threshold", ErrInvalidRule, r.Condition)
+		}
+	case models.ConditionNewPortOpened, models.ConditionHostDown, models.ConditionViolationOpened:
+	default:
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidRule, r.Condition)
+	}
+	for _, p := range r.Ports {
+		if p <= 0 || p > 65535 {
+			return fmt.Errorf("%w: invalid port %d", ErrInvalidRule, p)
+		}
+	}
+
+	r.Severity = strings.ToLower(r.Severity)
+	switch r.Severity {
+	case "":
+		r.Severity = models.SeverityMedium
+	case models.SeverityInfo, models.SeverityLow, models.SeverityMedium, models.SeverityHigh, models.SeverityCritical:
+	default:
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidRule, r.Severity)
+	}
+
+	if r.DedupWindowSeconds < 0 {
+		return fmt.Errorf("%w: dedup_window_seconds must not be negative", ErrInvalidRule)
+	}
+	if r.DedupWindowSeconds == 0 {
+		r.DedupWindowSeconds = int(defaultDedupWindow.Seconds())
+	}
+	if r.Group != "" {
+		if _, err := getGroup(r.Group); err != nil {
+			return fmt.Errorf("%w: group %q: %v", ErrInvalidRule, r.Group, err)
+		}
+	}
+	return nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package v1_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateRule_PortOpen(t *testing.T) {
+	rule := models.AlertRule{Condition: models.ConditionPortOpen, Ports: []int{23, 3389}}
+	res := models.ScanResult{Host: "h", OpenPorts: []int{22, 3389}}
+
+	ports, msg, ok := business.EvaluateRule(rule, res, nil, nil)
+
+	assert.True(t, ok)
+	assert.Equal(t, []int{3389}, ports)
+	assert.Contains(t, msg, "3389")
+}
+
+func TestEvaluateRule_NewPorts(t *testing.T) {
+	prev := &models.ScanResult{ScanID: "old", OpenPorts: []int{22}}
+	res := models.ScanResult{Host: "h", HostState: "up", OpenPorts: []int{22, 8080, 443}}
+
+	ports, _, ok := business.EvaluateRule(models.AlertRule{Condition: models.ConditionNewPortOpened}, res, prev, nil)
+	assert.True(t, ok)
+	assert.Equal(t, []int{443, 8080}, ports)
+
+	_, _, ok = business.EvaluateRule(models.AlertRule{Condition: models.ConditionNewPortsExceed, Threshold: 2}, res, prev, nil)
+	assert.False(t, ok)
+	_, _, ok = business.EvaluateRule(models.AlertRule{Condition: models.ConditionNewPortsExceed, Threshold: 1}, res, prev, nil)
+	assert.True(t, ok)
+
+	// The first scan of a host has nothing to compare against.
+	_, _, ok = business.EvaluateRule(models.AlertRule{Condition: models.ConditionNewPortOpened}, res, nil, nil)
+	assert.False(t, ok)
+}
+
+func TestEvaluateRule_HostDown(t *testing.T) {
+	rule := models.AlertRule{Condition: models.ConditionHostDown}
+	down := models.ScanResult{Host: "h", HostState: "down"}
+
+	_, _, ok := business.EvaluateRule(rule, down, &models.ScanResult{HostState: "up"}, nil)
+	assert.True(t, ok)
+
+	_, _, ok = business.EvaluateRule(rule, down, &models.ScanResult{HostState: "down"}, nil)
+	assert.False(t, ok)
+}
+
+func TestEvaluateRule_ViolationOpened(t *testing.T) {
+	rule := models.AlertRule{Condition: models.ConditionViolationOpened}
+	violations := []models.Violation{{Port: 22, Detail: "22/tcp open but not in baseline"}}
+
+	ports, msg, ok := business.EvaluateRule(rule, models.ScanResult{Host: "h"}, nil, violations)
+
+	assert.True(t, ok)
+	assert.Equal(t, []int{22}, ports)
+	assert.Contains(t, msg, "22/tcp open but not in baseline")
+}
+
+func TestWaitForDispatches_WaitsForAlertDeliveries(t *testing.T) {
+	withMemory(t)
+	savedRules, savedSilences, savedRecord, savedDispatch := database.ListAlertRules, database.ListSilences, database.RecordAlert, business.DispatchAlert
+	t.Cleanup(func() {
+		database.ListAlertRules, database.ListSilences, database.RecordAlert = savedRules, savedSilences, savedRecord
+		business.DispatchAlert = savedDispatch
+	})
+	database.ListAlertRules = func() ([]models.AlertRule, error) {
+		return []models.AlertRule{{ID: 1, Condition: models.ConditionPortOpen, Ports: []int{23}, Channels: []string{"ops"}}}, nil
+	}
+	database.ListSilences = func(time.Time) ([]models.AlertSilence, error) { return nil, nil }
+	database.RecordAlert = func(a models.Alert, _ time.Duration) (models.Alert, bool, error) { return a, true, nil }
+	release := make(chan struct{})
+	business.DispatchAlert = func(context.Context, models.Alert, []string) { <-release }
+
+	fired, err := business.EvaluateAlerts(context.Background(), models.ScanResult{Host: "h", OpenPorts: []int{23}}, nil)
+	require.NoError(t, err)
+	require.Len(t, fired, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, business.WaitForDispatches(ctx), "the delivery is still running")
+
+	close(release)
+	assert.True(t, business.WaitForDispatches(context.Background()))
+}
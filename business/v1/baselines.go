@@ -0,0 +1,280 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+const maxViolationsLimit = 500
+
+var (
+	CreateBaseline         = createBaseline
+	CreateBaselineFromHost = createBaselineFromHost
+	ReplaceBaseline        = replaceBaseline
+	GetBaseline            = getBaseline
+	ListBaselines          = listBaselines
+	DeleteBaseline         = deleteBaseline
+	EvaluateBaselines      = evaluateBaselines
+	ListViolations         = listViolations
+	AcknowledgeViolation   = acknowledgeViolation
+	ResolveViolation       = resolveViolation
+
+	ErrBaselineNotFound  = errors.New("baseline not found")
+	ErrBaselineExists    = errors.New("baseline already exists")
+	ErrInvalidBaseline   = errors.New("invalid baseline")
+	ErrViolationNotFound = errors.New("violation not found or already resolved")
+)
+
+func createBaseline(b models.Baseline) (models.Baseline, error) {
+	if err := validateBaseline(&b); err != nil {
+		return b, err
+	}
+	id, err := database.CreateBaseline(b)
+	if errors.Is(err, database.ErrConflict) {
+		return b, ErrBaselineExists
+	}
+	if err != nil {
+		return b, err
+	}
+	return getBaseline(id)
+}
+
+// createBaselineFromHost snapshots the host's current open ports as the
+// baseline for the host itself, or for group when one is given.
+func createBaselineFromHost(host, group string) (models.Baseline, error) {
+	h, err := getHost(host)
+	if err != nil {
+		return models.Baseline{}, err
+	}
+
+	b := models.Baseline{
+		Scope:       models.BaselineScopeHost,
+		Target:      host,
+		Description: fmt.Sprintf("Snapshot of %s from scan %s", host, h.LastScanID),
+	}
+	if group != "" {
+		b.Scope, b.Target = models.BaselineScopeGroup, group
+	}
+	ports := h.Ports
+	if len(ports) == 0 {
+		for _, p := range h.OpenPorts {
+			ports = append(ports, models.PortInfo{Port: p, Protocol: "tcp"})
+		}
+	}
+	for _, p := range ports {
+		b.Ports = append(b.Ports, models.BaselinePort{Port: p.Port, Protocol: p.Protocol, Service: p.Service})
+	}
+	return createBaseline(b)
+}
+
+func replaceBaseline(b models.Baseline) (models.Baseline, error) {
+	existing, err := getBaseline(b.ID)
+	if err != nil {
+		return b, err
+	}
+	b.Scope, b.Target = existing.Scope, existing.Target
+	if err := validateBaseline(&b); err != nil {
+		return b, err
+	}
+	if err := notFound(database.ReplaceBaseline(b), ErrBaselineNotFound); err != nil {
+		return b, err
+	}
+	return getBaseline(b.ID)
+}
+
+func getBaseline(id int64) (models.Baseline, error) {
+	b, err := database.GetBaseline(id)
+	return b, notFound(err, ErrBaselineNotFound)
+}
+
+func listBaselines() ([]models.Baseline, error) {
+	return database.ListBaselines()
+}
+
+func deleteBaseline(id int64) error {
+	return notFound(database.DeleteBaseline(id), ErrBaselineNotFound)
+}
+
+// evaluateBaselines checks a stored result against the baselines that apply to
+// its host and records the outcome. A host baseline takes precedence over
+// group baselines. Results of hosts that were down are skipped, since every
+// expected port would otherwise be reported missing. It returns the newly
+// opened violations.
+func evaluateBaselines(res models.ScanResult) ([]models.Violation, error) {
+	if res.HostState == "down" {
+		return nil, nil
+	}
+	baselines, err := applicableBaselines(res.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := res.Ports
+	if len(ports) == 0 {
+		for _, p := range res.OpenPorts {
+			ports = append(ports, models.PortInfo{Port: p, Protocol: "tcp", State: "open"})
+		}
+	}
+
+	var opened []models.Violation
+	for _, b := range baselines {
+		found := CheckBaseline(b, ports)
+		v, err := database.RecordViolations(b.ID, res.Host, res.ScanID, res.ScannedAt, found)
+		if err != nil {
+			return opened, err
+		}
+		opened = append(opened, v...)
+	}
+	return opened, nil
+}
+
+func applicableBaselines(host string) ([]models.Baseline, error) {
+	all, err := database.ListBaselines()
+	if err != nil {
+		return nil, err
+	}
+
+	var groupBaselines []models.Baseline
+	m := membership{host: host}
+	for _, b := range all {
+		switch b.Scope {
+		case models.BaselineScopeHost:
+			if b.Target == host {
+				return []models.Baseline{b}, nil
+			}
+		case models.BaselineScopeGroup:
+			if m.in(b.Target) {
+				groupBaselines = append(groupBaselines, b)
+			}
+		}
+	}
+	return groupBaselines, nil
+}
+
+// CheckBaseline returns the violations of ports against baseline b.
+func CheckBaseline(b models.Baseline, ports []models.PortInfo) []models.Violation {
+	key := func(port int, proto string) string { return fmt.Sprintf("%d/%s", port, proto) }
+
+	expected := make(map[string]models.BaselinePort)
+	for _, p := range b.Ports {
+		expected[key(p.Port, p.Protocol)] = p
+	}
+
+	var found []models.Violation
+	observed := make(map[string]bool)
+	for _, p := range ports {
+		if p.State != "" && p.State != "open" {
+			continue
+		}
+		proto := p.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		k := key(p.Port, proto)
+		observed[k] = true
+		exp, ok := expected[k]
+		switch {
+		case !ok:
+			detail := k + " open but not in baseline"
+			if p.Service != "" {
+				detail += " (" + p.Service + ")"
+			}
+			found = append(found, models.Violation{
+				Kind: models.ViolationUnexpectedOpen, Port: p.Port, Protocol: proto, Detail: detail,
+			})
+		case exp.Service != "" && p.Service != "" && !strings.EqualFold(exp.Service, p.Service):
+			found = append(found, models.Violation{
+				Kind: models.ViolationUnexpectedService, Port: p.Port, Protocol: proto,
+				Detail: fmt.Sprintf("%s runs %s, baseline expects %s", k, p.Service, exp.Service),
+			})
+		}
+	}
+
+	for k, exp := range expected {
+		if !exp.Optional && !observed[k] {
+			found = append(found, models.Violation{
+				Kind: models.ViolationExpectedMissing, Port: exp.Port, Protocol: exp.Protocol,
+				Detail: fmt.Sprintf("%s expected open but not found", k),
+			})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Port != found[j].Port {
+			return found[i].Port < found[j].Port
+		}
+		return found[i].Kind < found[j].Kind
+	})
+	return found
+}
+
+func listViolations(f models.ViolationFilter) ([]models.Violation, error) {
+	switch f.Status {
+	case "", models.ViolationOpen, models.ViolationAcknowledged, models.ViolationResolved:
+	default:
+		return nil, ErrInvalidFilter
+	}
+	if f.Limit <= 0 || f.Limit > maxViolationsLimit {
+		f.Limit = maxViolationsLimit
+	}
+	return database.ListViolations(f)
+}
+
+func acknowledgeViolation(id int64, a models.ViolationAction) (models.Violation, error) {
+	return transitionViolation(id, models.ViolationAcknowledged, a)
+}
+
+func resolveViolation(id int64, a models.ViolationAction) (models.Violation, error) {
+	return transitionViolation(id, models.ViolationResolved, a)
+}
+
+func transitionViolation(id int64, status string, a models.ViolationAction) (models.Violation, error) {
+	if err := notFound(database.SetViolationStatus(id, status, a), ErrViolationNotFound); err != nil {
+		return models.Violation{}, err
+	}
+	v, err := database.GetViolation(id)
+	return v, notFound(err, ErrViolationNotFound)
+}
+
+func validateBaseline(b *models.Baseline) error {
+	b.Target = strings.TrimSpace(b.Target)
+	switch b.Scope {
+	case models.BaselineScopeHost:
+		if !utils.IsValidHostname(b.Target) {
+			return fmt.Errorf("%w: invalid host %q", ErrInvalidBaseline, b.Target)
+		}
+	case models.BaselineScopeGroup:
+		if _, err := getGroup(b.Target); err != nil {
+			return fmt.Errorf("%w: group %q: %v", ErrInvalidBaseline, b.Target, err)
+		}
+	default:
+		return fmt.Errorf("%w: scope must be host or group", ErrInvalidBaseline)
+	}
+
+	seen := make(map[string]bool)
+	ports := []models.BaselinePort{}
+	for _, p := range b.Ports {
+		p.Protocol = strings.ToLower(p.Protocol)
+		if p.Protocol == "" {
+			p.Protocol = "tcp"
+		}
+		if p.Port <= 0 || p.Port > 65535 {
+			return fmt.Errorf("%w: invalid port %d", ErrInvalidBaseline, p.Port)
+		}
+		k := fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	b.Ports = ports
+	return nil
+}
@@ -0,0 +1,44 @@
+package v1_test
+
+import (
+	"testing"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	b := models.Baseline{Ports: []models.BaselinePort{
+		{Port: 80, Protocol: "tcp", Service: "http"},
+		{Port: 443, Protocol: "tcp"},
+		{Port: 8443, Protocol: "tcp", Optional: true},
+	}}
+	ports := []models.PortInfo{
+		{Port: 22, Protocol: "tcp", State: "open", Service: "ssh"},
+		{Port: 80, Protocol: "tcp", State: "open", Service: "ftp"},
+	}
+
+	found := business.CheckBaseline(b, ports)
+
+	assert.Len(t, found, 3)
+	assert.Equal(t, models.ViolationUnexpectedOpen, found[0].Kind)
+	assert.Equal(t, 22, found[0].Port)
+	assert.Equal(t, models.ViolationUnexpectedService, found[1].Kind)
+	assert.Equal(t, 80, found[1].Port)
+	assert.Equal(t, models.ViolationExpectedMissing, found[2].Kind)
+	assert.Equal(t, 443, found[2].Port)
+}
+
+func TestCheckBaseline_Clean(t *testing.T) {
+	b := models.Baseline{Ports: []models.BaselinePort{{Port: 443, Protocol: "tcp"}}}
+	found := business.CheckBaseline(b, []models.PortInfo{{Port: 443, Protocol: "tcp", State: "open"}})
+	assert.Empty(t, found)
+}
+
+func TestEvaluateBaselines_SkipsDownHosts(t *testing.T) {
+	opened, err := business.EvaluateBaselines(models.ScanResult{Host: "host1", HostState: "down"})
+	assert.NoError(t, err)
+	assert.Empty(t, opened)
+}
@@ -0,0 +1,99 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"nmap-rest-api/export"
+	models "nmap-rest-api/models/v1"
+)
+
+var (
+	ExportResults  = exportResults
+	ValidateExport = validateExport
+
+	ErrInvalidExport = errors.New("invalid export")
+)
+
+// validateExport checks an export request before anything is written, so
+// handlers can still answer with an error status.
+func validateExport(q models.ExportQuery, format string) error {
+	switch format {
+	case export.FormatCSV, export.FormatJSONL, export.FormatXML, export.FormatSARIF:
+	default:
+		return fmt.Errorf("%w: format must be csv, jsonl, xml or sarif", ErrInvalidExport)
+	}
+
+	sources := 0
+	for _, set := range []bool{q.ScanID != "", q.Host != "", q.Search != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("%w: specify exactly one of scan_id, host or a search", ErrInvalidExport)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: invalid limit", ErrInvalidExport)
+	}
+	if q.Search != nil {
+		if _, err := normalizeSearch(*q.Search); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+	}
+	return nil
+}
+
+// exportResults streams the results selected by q to w in format. Searches
+// export one result per host and evidence scan, holding only the matched ports.
+func exportResults(q models.ExportQuery, format string, w io.Writer) error {
+	if err := validateExport(q, format); err != nil {
+		return err
+	}
+	ew, err := export.New(format, w)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case q.ScanID != "":
+		err = Repos.Results.StreamResults(models.ResultFilter{ScanID: q.ScanID, Limit: q.Limit}, ew.Write)
+	case q.Host != "":
+		err = Repos.Results.StreamResults(models.ResultFilter{Host: q.Host, Limit: q.Limit}, ew.Write)
+	default:
+		err = exportSearch(*q.Search, ew)
+	}
+	if err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func exportSearch(q models.PortSearchQuery, ew export.Writer) error {
+	q, _ = normalizeSearch(q)
+	q.Limit = 0 // exports are streamed, the search cap does not apply
+
+	var cur *models.ScanResult
+	err := Repos.Results.StreamOpenPorts(q, func(hit models.PortSearchHit) error {
+		if cur != nil && (cur.Host != hit.Host || cur.ScanID != hit.ScanID) {
+			if err := ew.Write(*cur); err != nil {
+				return err
+			}
+			cur = nil
+		}
+		if cur == nil {
+			cur = &models.ScanResult{ScanID: hit.ScanID, Host: hit.Host, ScannedAt: hit.ScannedAt, HostState: "up"}
+		}
+		cur.Ports = append(cur.Ports, hit.PortInfo)
+		cur.OpenPorts = append(cur.OpenPorts, hit.Port)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if cur != nil {
+		return ew.Write(*cur)
+	}
+	return nil
+}
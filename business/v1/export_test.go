@@ -0,0 +1,28 @@
+package v1
+
+// Script hashes for redismock expectations in the external tests.
+var (
+	PushScriptHash       = pushScript.Hash()
+	PopScriptHash        = popScript.Hash()
+	PositionScriptHash   = positionScript.Hash()
+	ClaimScriptHash      = claimScript.Hash()
+	ReleaseScriptHash    = releaseScript.Hash()
+	ShardScriptHash      = shardScript.Hash()
+	RenewKeyScriptHash   = renewKeyScript.Hash()
+	ReleaseKeyScriptHash = releaseKeyScript.Hash()
+	RenewLeaseScriptHash = renewLeaseScript.Hash()
+)
+
+// Fingerprint exposes the idempotency request fingerprint.
+var Fingerprint = fingerprint
+
+// SplitPorts exposes the port range sharding.
+var SplitPorts = splitPorts
+
+// Entries counts what the queue holds in keys, shard sets and claim
+// semaphores, expired or not.
+func (m *MemoryQueue) Entries() (keys, shards, sems int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys), len(m.shards), len(m.claims.sems)
+}
@@ -0,0 +1,251 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+var (
+	CreateGroup  = createGroup
+	ReplaceGroup = replaceGroup
+	GetGroup     = getGroup
+	ListGroups   = listGroups
+	DeleteGroup  = deleteGroup
+	ResolveGroup = resolveGroup
+
+	ErrGroupNotFound = errors.New("group not found")
+	ErrGroupExists   = errors.New("group already exists")
+	ErrInvalidGroup  = errors.New("invalid group")
+)
+
+var groupNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+func createGroup(g models.AssetGroup) error {
+	if err := validateGroup(&g); err != nil {
+		return err
+	}
+	err := database.CreateGroup(g)
+	if errors.Is(err, database.ErrConflict) {
+		return ErrGroupExists
+	}
+	return err
+}
+
+func replaceGroup(g models.AssetGroup) error {
+	if err := validateGroup(&g); err != nil {
+		return err
+	}
+	return notFound(database.ReplaceGroup(g), ErrGroupNotFound)
+}
+
+func getGroup(name string) (models.AssetGroup, error) {
+	g, err := database.GetGroup(name)
+	return g, notFound(err, ErrGroupNotFound)
+}
+
+func listGroups() ([]models.AssetGroup, error) {
+	return database.ListGroups()
+}
+
+func deleteGroup(name string) error {
+	return notFound(database.DeleteGroup(name), ErrGroupNotFound)
+}
+
+// resolveGroup expands a group into its current, sorted member hosts.
+func resolveGroup(name string) ([]string, error) {
+	g, err := getGroup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool)
+	for _, h := range g.Hosts {
+		members[h] = true
+	}
+	if g.Match != nil {
+		hosts, err := Repos.Hosts.ListHosts(models.HostFilter{Lifecycle: models.HostActive})
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hosts {
+			if MatchesGroup(g.Match, h) {
+				members[h.Host] = true
+			}
+		}
+	}
+
+	out := make([]string, 0, len(members))
+	for h := range members {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// MatchesGroup reports whether an inventory host satisfies a dynamic group.
+func MatchesGroup(m *models.GroupMatch, h models.Host) bool {
+	for _, want := range m.Tags {
+		found := false
+		for _, t := range h.Tags {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(m.CIDRs) > 0 {
+		ips := append([]string{h.Host}, h.ResolvedIPs...)
+		if !anyInCIDRs(ips, m.CIDRs) {
+			return false
+		}
+	}
+
+	if len(m.NamePatterns) > 0 {
+		matched := false
+		for _, p := range m.NamePatterns {
+			if ok, _ := path.Match(p, h.Host); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// membership answers "is host in group X" for one host, loading the host's
+// inventory entry at most once.
+type membership struct {
+	host   string
+	inv    *models.Host
+	loaded bool
+}
+
+// in reports whether the host currently belongs to group. Unknown groups and
+// lookup failures count as "not a member".
+func (m *membership) in(group string) bool {
+	g, err := database.GetGroup(group)
+	if err != nil {
+		return false
+	}
+	for _, h := range g.Hosts {
+		if h == m.host {
+			return true
+		}
+	}
+	if g.Match == nil {
+		return false
+	}
+	if !m.loaded {
+		m.loaded = true
+		if h, err := Repos.Hosts.GetHost(m.host); err == nil {
+			m.inv = &h
+		}
+	}
+	return m.inv != nil && MatchesGroup(g.Match, *m.inv)
+}
+
+func anyInCIDRs(ips, cidrs []string) bool {
+	for _, c := range cidrs {
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+		for _, s := range ips {
+			if ip := net.ParseIP(s); ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandTargets merges the requested hosts with every requested group's
+// members. It returns the de-duplicated target list in request order and the
+// per-group expansion to record on the scan.
+func expandTargets(req models.ScanRequest) ([]string, map[string][]string, error) {
+	seen := make(map[string]bool)
+	var hosts []string
+	add := func(h string) {
+		if !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+
+	for _, h := range req.Hosts {
+		add(h)
+	}
+
+	var expansion map[string][]string
+	for _, name := range req.Groups {
+		members, err := resolveGroup(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("group %q: %w", name, err)
+		}
+		if expansion == nil {
+			expansion = make(map[string][]string)
+		}
+		expansion[name] = members
+		for _, h := range members {
+			add(h)
+		}
+	}
+	return hosts, expansion, nil
+}
+
+func validateGroup(g *models.AssetGroup) error {
+	g.Name = strings.ToLower(strings.TrimSpace(g.Name))
+	if !groupNameRegex.MatchString(g.Name) {
+		return fmt.Errorf("%w: name must match %s", ErrInvalidGroup, groupNameRegex)
+	}
+	for _, h := range g.Hosts {
+		if !utils.IsValidHostname(h) {
+			return fmt.Errorf("%w: invalid host %q", ErrInvalidGroup, h)
+		}
+	}
+	if g.Match != nil {
+		g.Match.Tags = normalizeTags(g.Match.Tags)
+		for _, c := range g.Match.CIDRs {
+			if _, _, err := net.ParseCIDR(c); err != nil {
+				return fmt.Errorf("%w: invalid CIDR %q", ErrInvalidGroup, c)
+			}
+		}
+		for _, p := range g.Match.NamePatterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("%w: invalid name pattern %q", ErrInvalidGroup, p)
+			}
+		}
+		if len(g.Match.Tags) == 0 && len(g.Match.CIDRs) == 0 && len(g.Match.NamePatterns) == 0 {
+			g.Match = nil
+		}
+	}
+	if len(g.Hosts) == 0 && g.Match == nil {
+		return fmt.Errorf("%w: a group needs hosts or match criteria", ErrInvalidGroup)
+	}
+	return validateWindows(g.Windows)
+}
+
+// notFound maps sql.ErrNoRows to the given domain error.
+func notFound(err, target error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return target
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+)
+
+var (
+	BeginIdempotent   = beginIdempotent
+	HoldIdempotent    = holdIdempotent
+	FinishIdempotent  = finishIdempotent
+	AbandonIdempotent = abandonIdempotent
+
+	ErrIdempotencyKeyInvalid  = errors.New("Idempotency-Key must be 1 to 255 characters")
+	ErrIdempotencyKeyReused   = errors.New("Idempotency-Key was already used for a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this Idempotency-Key is still being processed")
+)
+
+// IdempotencyTTL is how long a completed key replays its response; main sets
+// it from the config.
+var IdempotencyTTL = config.Default().Server.IdempotencyTTL
+
+// idempotencyReserve is how long a reservation lasts unless the request
+// holding it renews it; a server that dies meanwhile frees the key after it.
+const idempotencyReserve = time.Minute
+
+func idempotencyKey(key string) string {
+	return "idempotency:scan:" + key
+}
+
+// fingerprint identifies a request body, so a key reused for a different
+// request is caught.
+func fingerprint(req models.ScanRequest) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reservationFor is the value that reserves a key for req while it runs.
+func reservationFor(req models.ScanRequest) (fp string, reservation []byte, err error) {
+	fp, err = fingerprint(req)
+	if err != nil {
+		return "", nil, err
+	}
+	reservation, err = json.Marshal(models.IdempotentResponse{Fingerprint: fp})
+	return fp, reservation, err
+}
+
+// beginIdempotent reserves key for req. It returns the recorded response when
+// the key already completed for the same request, and nil when the caller
+// should process the request and then finish or abandon the key.
+func beginIdempotent(ctx context.Context, key string, req models.ScanRequest) (*models.IdempotentResponse, error) {
+	if key == "" || len(key) > 255 {
+		return nil, ErrIdempotencyKeyInvalid
+	}
+	fp, reservation, err := reservationFor(req)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := Queue.Keys.SetNX(ctx, idempotencyKey(key), reservation, idempotencyReserve)
+	if err != nil || ok {
+		return nil, err
+	}
+
+	v, ok, err := Queue.Keys.Get(ctx, idempotencyKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Expired since the reservation failed; the key is free again.
+		return beginIdempotent(ctx, key, req)
+	}
+	var stored models.IdempotentResponse
+	if err := json.Unmarshal(v, &stored); err != nil {
+		return nil, err
+	}
+	switch {
+	case stored.Fingerprint != fp:
+		return nil, ErrIdempotencyKeyReused
+	case stored.Status == 0:
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	return &stored, nil
+}
+
+// holdIdempotent renews key's reservation for req until the returned stop is
+// called, so a request outliving idempotencyReserve keeps a concurrent retry
+// out. Renewal stops by itself once the key no longer holds the reservation.
+func holdIdempotent(ctx context.Context, key string, req models.ScanRequest) (stop func()) {
+	_, reservation, err := reservationFor(req)
+	if err != nil {
+		log.Printf("Failed to hold Idempotency-Key: %v", err)
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(idempotencyReserve / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				ok, err := Queue.Keys.RenewKey(ctx, idempotencyKey(key), reservation, idempotencyReserve)
+				if err != nil {
+					log.Printf("Failed to renew Idempotency-Key: %v", err)
+				} else if !ok {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// finishIdempotent records the response for replays of key.
+func finishIdempotent(ctx context.Context, key string, req models.ScanRequest, status int, body []byte) error {
+	fp, err := fingerprint(req)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(models.IdempotentResponse{Fingerprint: fp, Status: status, Body: body})
+	if err != nil {
+		return err
+	}
+	return Queue.Keys.Set(ctx, idempotencyKey(key), v, IdempotencyTTL.D())
+}
+
+// abandonIdempotent frees key after a failed request so a retry runs anew.
+func abandonIdempotent(ctx context.Context, key string) error {
+	return Queue.Keys.Del(ctx, idempotencyKey(key))
+}
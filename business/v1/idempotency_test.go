@@ -0,0 +1,70 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeginIdempotent_ReservesNewKey(t *testing.T) {
+	req := models.ScanRequest{Hosts: []string{"example.com"}}
+	fp, _ := business.Fingerprint(req)
+	reservation, _ := json.Marshal(models.IdempotentResponse{Fingerprint: fp})
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	mockRedis.ExpectSetNX("idempotency:scan:k1", reservation, time.Minute).SetVal(true)
+
+	stored, err := business.BeginIdempotent(context.Background(), "k1", req)
+
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestBeginIdempotent_ExistingKey(t *testing.T) {
+	req := models.ScanRequest{Hosts: []string{"example.com"}}
+	fp, _ := business.Fingerprint(req)
+	reservation, _ := json.Marshal(models.IdempotentResponse{Fingerprint: fp})
+	done, _ := json.Marshal(models.IdempotentResponse{Fingerprint: fp, Status: 202, Body: json.RawMessage(`{"scan_id":"s1"}`)})
+
+	cases := []struct {
+		name    string
+		stored  []byte
+		req     models.ScanRequest
+		wantErr error
+	}{
+		{"replays completed", done, req, nil},
+		{"rejects other request", done, models.ScanRequest{Hosts: []string{"example.com"}, Priority: "high"}, business.ErrIdempotencyKeyReused},
+		{"rejects while in flight", reservation, req, business.ErrIdempotencyKeyInFlight},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rdb, mockRedis := redismock.NewClientMock()
+			database.RDB = rdb
+			mockRedis.Regexp().ExpectSetNX("idempotency:scan:k1", `.*`, time.Minute).SetVal(false)
+			mockRedis.ExpectGet("idempotency:scan:k1").SetVal(string(tc.stored))
+
+			stored, err := business.BeginIdempotent(context.Background(), "k1", tc.req)
+
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, 202, stored.Status)
+				assert.JSONEq(t, `{"scan_id":"s1"}`, string(stored.Body))
+			}
+			assert.NoError(t, mockRedis.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBeginIdempotent_RejectsOverlongKey(t *testing.T) {
+	_, err := business.BeginIdempotent(context.Background(), string(make([]byte, 256)), models.ScanRequest{})
+	assert.ErrorIs(t, err, business.ErrIdempotencyKeyInvalid)
+}
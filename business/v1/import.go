@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/nmap"
+	"nmap-rest-api/utils"
+)
+
+var (
+	ImportNmapXML = importNmapXML
+
+	ErrInvalidImport = errors.New("invalid nmap XML")
+)
+
+// importNmapXML turns one nmap XML document into a scan record carrying the
+// run's original timestamps, and stores every host through PersistResult so
+// imported history feeds diffs and the inventory exactly like live scans.
+// source names the file and ends up as "import:<source>" on the scan.
+func importNmapXML(ctx context.Context, r io.Reader, source string) (models.ImportSummary, error) {
+	run, err := nmap.Parse(r)
+	if err != nil {
+		return models.ImportSummary{}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	sum := models.ImportSummary{
+		ScanID:    utils.GenerateScanID(),
+		Source:    "import:" + filepath.Base(source),
+		StartedAt: run.StartedAt(),
+	}
+	var results []models.ScanResult
+	var started []time.Time
+	for _, h := range run.Hosts {
+		res, ok := importedResult(h, run)
+		if !ok {
+			sum.Skipped++
+			continue
+		}
+		res.ScanID = sum.ScanID
+		results = append(results, res)
+		started = append(started, h.StartedAt())
+		sum.Hosts = append(sum.Hosts, res.Host)
+		if sum.StartedAt.IsZero() || (!h.StartedAt().IsZero() && h.StartedAt().Before(sum.StartedAt)) {
+			sum.StartedAt = h.StartedAt()
+		}
+	}
+	if len(results) == 0 {
+		return models.ImportSummary{}, fmt.Errorf("%w: no hosts", ErrInvalidImport)
+	}
+	if sum.StartedAt.IsZero() {
+		sum.StartedAt = time.Now().UTC()
+	}
+
+	err = Repos.Scans.CreateScan(models.Scan{
+		ScanID:         sum.ScanID,
+		CreatedAt:      sum.StartedAt,
+		RequestedHosts: sum.Hosts,
+		Hosts:          sum.Hosts,
+		Source:         sum.Source,
+	})
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	for i, res := range results {
+		if err := PersistResult(ctx, res); err != nil {
+			log.Printf("Failed to store imported result for %s: %v", res.Host, err)
+			sum.Failed = append(sum.Failed, res.Host)
+			continue
+		}
+		start := started[i]
+		if start.IsZero() {
+			start = sum.StartedAt
+		}
+		if err := Repos.Statuses.SetStatusTimes(res.ScanID, res.Host, start, res.ScannedAt); err != nil {
+			log.Printf("Failed to set imported status times for %s: %v", res.Host, err)
+		}
+	}
+	return sum, nil
+}
+
+// importedResult maps one <host> to a ScanResult the way the worker does.
+// Hosts nmap could not name are skipped.
+func importedResult(h nmap.Host, run *nmap.Run) (models.ScanResult, bool) {
+	res := models.ScanResult{Host: h.Target(), HostState: "down"}
+	if res.Host == "" {
+		return res, false
+	}
+	if h.Up() {
+		res.HostState = "up"
+	}
+	if addr := h.Address(); addr != "" {
+		res.Addresses = []string{addr}
+	}
+	res.Ports = h.PortInfos()
+	res.OpenPorts = utils.PortNumbers(res.Ports)
+
+	res.ScannedAt = h.ScannedAt()
+	if res.ScannedAt.IsZero() {
+		res.ScannedAt = run.FinishedAt()
+	}
+	if res.ScannedAt.IsZero() {
+		res.ScannedAt = run.StartedAt()
+	}
+	if res.ScannedAt.IsZero() {
+		res.ScannedAt = time.Now().UTC()
+	}
+	return res, true
+}
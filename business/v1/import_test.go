@@ -0,0 +1,109 @@
+package v1_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+	utils "nmap-rest-api/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importXML = `<nmaprun scanner="nmap" start="1715238000">
+<host starttime="1715238001" endtime="1715238042">
+  <status state="up" reason="syn-ack"/>
+  <address addr="10.0.0.5" addrtype="ipv4"/>
+  <hostnames><hostname name="web-1" type="user"/></hostnames>
+  <ports>
+    <port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+    <port protocol="tcp" portid="25"><state state="filtered"/></port>
+  </ports>
+</host>
+<host><status state="down" reason="no-response"/></host>
+<runstats><finished time="1715238050"/></runstats>
+</nmaprun>`
+
+// statusTimes records the times imports set on top of a status repository.
+type statusTimes struct {
+	database.StatusRepository
+	completed map[string]time.Time
+}
+
+func (s statusTimes) SetStatusTimes(scanID, host string, startedAt, completedAt time.Time) error {
+	s.completed[host] = completedAt
+	return s.StatusRepository.SetStatusTimes(scanID, host, startedAt, completedAt)
+}
+
+func TestImportNmapXML_StoresResultsWithOriginalTimes(t *testing.T) {
+	utils.GenerateScanID = func() string { return "import-id" }
+	repos := withMemory(t)
+	times := statusTimes{repos.Statuses, map[string]time.Time{}}
+	business.Repos.Statuses = times
+	var processed []models.ScanResult
+	orig := business.ProcessStoredResult
+	defer func() { business.ProcessStoredResult = orig }()
+	business.ProcessStoredResult = func(ctx context.Context, res models.ScanResult) { processed = append(processed, res) }
+
+	sum, err := business.ImportNmapXML(context.Background(), strings.NewReader(importXML), "/tmp/nightly.xml")
+	require.NoError(t, err)
+
+	start := time.Unix(1715238000, 0).UTC()
+	assert.Equal(t, "import-id", sum.ScanID)
+	assert.Equal(t, "import:nightly.xml", sum.Source)
+	assert.Equal(t, []string{"web-1"}, sum.Hosts)
+	assert.Equal(t, 1, sum.Skipped)
+	scan, err := repos.Scans.GetScan("import-id")
+	require.NoError(t, err)
+	assert.Equal(t, start, scan.CreatedAt)
+	assert.Equal(t, "import:nightly.xml", scan.Source)
+
+	var stored []models.ScanResult
+	require.NoError(t, repos.Results.StreamResults(models.ResultFilter{ScanID: "import-id"}, func(r models.ScanResult) error {
+		stored = append(stored, r)
+		return nil
+	}))
+	require.Len(t, stored, 1)
+	assert.Equal(t, "web-1", stored[0].Host)
+	assert.Equal(t, "up", stored[0].HostState)
+	assert.Equal(t, []int{22}, stored[0].OpenPorts)
+	assert.Equal(t, time.Unix(1715238042, 0).UTC(), stored[0].ScannedAt)
+	assert.Equal(t, []string{"web-1=done"}, statuses(t, repos, "import-id"), "the result and its status are stored together")
+	assert.Equal(t, stored[0].ScannedAt, times.completed["web-1"])
+	require.Len(t, processed, 1)
+	assert.Equal(t, []string{"10.0.0.5"}, processed[0].Addresses)
+}
+
+func TestImportNmapXML_Invalid(t *testing.T) {
+	repos := withMemory(t)
+
+	_, err := business.ImportNmapXML(context.Background(), strings.NewReader("not xml"), "x.xml")
+	assert.ErrorIs(t, err, business.ErrInvalidImport)
+
+	_, err = business.ImportNmapXML(context.Background(), strings.NewReader(`<nmaprun></nmaprun>`), "x.xml")
+	assert.ErrorIs(t, err, business.ErrInvalidImport)
+
+	_, err = repos.Scans.GetScan("import-id")
+	assert.ErrorIs(t, err, sql.ErrNoRows, "no scan is recorded")
+}
+
+func TestProcessStoredResult_BackfillOnlyUpdatesInventory(t *testing.T) {
+	repos := withMemory(t)
+	require.NoError(t, repos.Hosts.UpsertHost(models.ScanResult{Host: "web-1", ScannedAt: time.Now()}))
+	database.ListBaselines = func() ([]models.Baseline, error) {
+		t.Fatal("baselines evaluated for a backfilled result")
+		return nil, sql.ErrNoRows
+	}
+
+	backfill := time.Now().Add(-24 * time.Hour)
+	business.ProcessStoredResult(context.Background(), models.ScanResult{Host: "web-1", ScannedAt: backfill})
+	h, err := repos.Hosts.GetHost("web-1")
+	require.NoError(t, err)
+	assert.Equal(t, backfill, h.FirstSeen, "the inventory still learns of the older sighting")
+}
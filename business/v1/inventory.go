@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+const maxHostsLimit = 500
+
+var (
+	UpdateInventory = updateInventory
+	ListHosts       = listHosts
+	GetHost         = getHost
+	UpdateHost      = updateHost
+	MarkStaleHosts  = markStaleHosts
+
+	ErrHostNotFound  = errors.New("host not found")
+	ErrInvalidFilter = errors.New("invalid host filter")
+)
+
+// updateInventory refreshes the hosts table from a freshly stored result.
+func updateInventory(res models.ScanResult) error {
+	return Repos.Hosts.UpsertHost(res)
+}
+
+func listHosts(f models.HostFilter) ([]models.Host, error) {
+	switch f.Lifecycle {
+	case "", models.HostActive, models.HostStale:
+	default:
+		return nil, ErrInvalidFilter
+	}
+	if f.Port < 0 || f.Port > 65535 || f.Offset < 0 {
+		return nil, ErrInvalidFilter
+	}
+	if f.Limit <= 0 || f.Limit > maxHostsLimit {
+		f.Limit = maxHostsLimit
+	}
+	return Repos.Hosts.ListHosts(f)
+}
+
+func getHost(host string) (models.Host, error) {
+	h, err := Repos.Hosts.GetHost(host)
+	return h, notFound(err, ErrHostNotFound)
+}
+
+// updateHost sets owner/tag metadata. Tags are trimmed, de-duplicated and sorted.
+func updateHost(host string, upd models.HostUpdate) (models.Host, error) {
+	if upd.Tags != nil {
+		tags := normalizeTags(*upd.Tags)
+		upd.Tags = &tags
+	}
+	if err := notFound(Repos.Hosts.UpdateHostMeta(host, upd), ErrHostNotFound); err != nil {
+		return models.Host{}, err
+	}
+	return getHost(host)
+}
+
+// markStaleHosts flags hosts that have not been seen up within staleAfter.
+func markStaleHosts(staleAfter time.Duration) {
+	n, err := Repos.Hosts.MarkStaleHosts(time.Now().Add(-staleAfter))
+	if err != nil {
+		log.Printf("Failed to mark stale hosts: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Marked %d host(s) stale", n)
+	}
+}
+
+func normalizeTags(in []string) []string {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, t := range in {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,36 @@
+package v1_test
+
+import (
+	"testing"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateHost_NormalizesTags(t *testing.T) {
+	repos := withMemory(t)
+	seedHost(t, repos, "host1", nil)
+	owner := "team-a"
+	assert.NoError(t, repos.Hosts.UpdateHostMeta("host1", models.HostUpdate{Owner: &owner}))
+
+	tags := []string{" Prod", "web", "prod", ""}
+	h, err := business.UpdateHost("host1", models.HostUpdate{Tags: &tags})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "web"}, h.Tags)
+	assert.Equal(t, "team-a", h.Owner, "fields not in the update are kept")
+}
+
+func TestGetHost_NotFound(t *testing.T) {
+	withMemory(t)
+
+	_, err := business.GetHost("unknown")
+	assert.ErrorIs(t, err, business.ErrHostNotFound)
+}
+
+func TestListHosts_RejectsUnknownLifecycle(t *testing.T) {
+	_, err := business.ListHosts(models.HostFilter{Lifecycle: "retired"})
+	assert.ErrorIs(t, err, business.ErrInvalidFilter)
+}
@@ -0,0 +1,277 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"nmap-rest-api/config"
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Per-target limits are semaphores held in the ClaimStore. In Redis there is
+// one sorted set per host and per subnet whose members are the jobs scanning
+// it, scored by when their claim lapses. Every running job is also in
+// limitsRunning, its share of the packet budget in limitsRates and its claim
+// group in limitsGroups: the shards of one scan's host form a group and
+// share its slots.
+var (
+	ClaimTarget   = claimTarget
+	RenewTarget   = renewTarget
+	ReleaseTarget = releaseTarget
+)
+
+// Limits configures the per-target semaphores and the packet budget; main
+// sets it from the config.
+var Limits = config.Default().Limits
+
+const (
+	limitsRunning = "limits:running"
+	limitsRates   = "limits:rates"
+	limitsHeld    = "limits:held"
+	limitsGroups  = "limits:groups"
+)
+
+// claimScript takes a slot in every semaphore in KEYS[5..] for holder
+// ARGV[1] of group ARGV[5] unless one is full. A semaphore counts groups, so
+// a holder whose group is already in it always gets in. ARGV[2] is now and
+// ARGV[3] the claim's expiry (Unix ms), ARGV[4] the packet budget and
+// ARGV[6..] the semaphores' limits. It returns {1, granted rate} or {0, index
+// of the full semaphore}, -1 when the budget is spent.
+var claimScript = redis.NewScript(`
+local holder, now, expires, budget, group = ARGV[1], tonumber(ARGV[2]), ARGV[3], tonumber(ARGV[4]), ARGV[5]
+for _, gone in ipairs(redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", now)) do
+	redis.call("HDEL", KEYS[2], gone)
+	redis.call("HDEL", KEYS[3], gone)
+	redis.call("HDEL", KEYS[4], gone)
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local sems = {}
+for i = 5, #KEYS do
+	redis.call("ZREMRANGEBYSCORE", KEYS[i], "-inf", now)
+	local groups, n, shared = {}, 0, false
+	for _, h in ipairs(redis.call("ZRANGE", KEYS[i], 0, -1)) do
+		local g = redis.call("HGET", KEYS[4], h) or h
+		if g == group then
+			shared = true
+		elseif not groups[g] then
+			groups[g] = true
+			n = n + 1
+		end
+	end
+	if not shared and n >= tonumber(ARGV[i + 1]) then
+		return {0, i - 5}
+	end
+	table.insert(sems, KEYS[i])
+end
+local rate = 0
+if budget > 0 then
+	redis.call("HDEL", KEYS[2], holder)
+	local used = 0
+	for _, r in ipairs(redis.call("HVALS", KEYS[2])) do
+		used = used + tonumber(r)
+	end
+	redis.call("ZREM", KEYS[1], holder)
+	local running = redis.call("ZCARD", KEYS[1])
+	rate = math.min(math.floor(budget / (running + 1)), budget - used)
+	if rate < 1 then
+		return {0, -1}
+	end
+	redis.call("HSET", KEYS[2], holder, rate)
+end
+redis.call("ZADD", KEYS[1], expires, holder)
+for _, k in ipairs(sems) do
+	redis.call("ZADD", k, expires, holder)
+end
+redis.call("HSET", KEYS[3], holder, cjson.encode(sems))
+redis.call("HSET", KEYS[4], holder, group)
+return {1, rate}`)
+
+// renewScript moves the expiry of holder ARGV[1]'s claim to ARGV[2].
+var renewScript = redis.NewScript(`
+local held = redis.call("HGET", KEYS[3], ARGV[1])
+if not held then return 0 end
+redis.call("ZADD", KEYS[1], "XX", ARGV[2], ARGV[1])
+for _, k in ipairs(cjson.decode(held)) do
+	redis.call("ZADD", k, "XX", ARGV[2], ARGV[1])
+end
+return 1`)
+
+// releaseScript frees every slot holder ARGV[1] holds.
+var releaseScript = redis.NewScript(`
+local held = redis.call("HGET", KEYS[3], ARGV[1])
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("HDEL", KEYS[4], ARGV[1])
+if held then
+	for _, k in ipairs(cjson.decode(held)) do
+		redis.call("ZREM", k, ARGV[1])
+	end
+end
+return 1`)
+
+// semaphore is one limited resource the target occupies.
+type semaphore struct {
+	key   string
+	limit int
+}
+
+// targetSemaphores returns the host and subnet semaphores scanning target
+// takes. A name counts as every address the inventory resolved it to; a CIDR
+// wider than a subnet is limited as one subnet of its own size.
+func targetSemaphores(target string) ([]semaphore, error) {
+	var ips []net.IP
+	hosts := []string{strings.ToLower(target)}
+	var nets []string
+	switch {
+	case net.ParseIP(target) != nil:
+		ips = []net.IP{net.ParseIP(target)}
+	default:
+		if _, n, err := net.ParseCIDR(target); err == nil {
+			ones, bits := n.Mask.Size()
+			if size := subnetBits(bits == 32); ones > size {
+				ones = size
+			}
+			nets = append(nets, (&net.IPNet{IP: n.IP.Mask(net.CIDRMask(ones, bits)), Mask: net.CIDRMask(ones, bits)}).String())
+			break
+		}
+		h, err := Repos.Hosts.GetHost(target)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		for _, s := range h.ResolvedIPs {
+			if ip := net.ParseIP(s); ip != nil {
+				ips = append(ips, ip)
+				hosts = append(hosts, ip.String())
+			}
+		}
+	}
+	for _, ip := range ips {
+		v4 := ip.To4() != nil
+		bits := 128
+		if v4 {
+			ip, bits = ip.To4(), 32
+		}
+		mask := net.CIDRMask(subnetBits(v4), bits)
+		nets = append(nets, (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String())
+	}
+
+	var sems []semaphore
+	seen := map[string]bool{}
+	add := func(key string, limit int) {
+		if limit > 0 && !seen[key] {
+			seen[key] = true
+			sems = append(sems, semaphore{key: key, limit: limit})
+		}
+	}
+	for _, h := range hosts {
+		add("limits:host:"+h, Limits.PerHost)
+	}
+	for _, n := range nets {
+		add("limits:net:"+n, Limits.PerSubnet)
+	}
+	return sems, nil
+}
+
+func subnetBits(v4 bool) int {
+	if v4 {
+		return Limits.SubnetBitsV4
+	}
+	return Limits.SubnetBitsV6
+}
+
+// limitKeys are the keys every limits script touches.
+var limitKeys = []string{limitsRunning, limitsRates, limitsHeld, limitsGroups}
+
+// claimGroup is the job's scan and host: the shards of one host share their
+// slots, so they may run side by side under limits.per_host.
+func claimGroup(job models.ScanJob) string {
+	return job.ScanID + "|" + job.Host
+}
+
+// claimTarget takes the job's host and subnet slots and its share of the
+// packet budget, returned as the job's MaxRate. When a slot is taken or the
+// budget spent it defers the job by Limits.DeferDelay instead and returns
+// false. A claim lapses after Limits.LockTTL unless renewed.
+func claimTarget(ctx context.Context, job models.ScanJob) (models.ScanJob, bool, error) {
+	job.MaxRate = 0
+	if !Limits.Enabled() {
+		return job, true, nil
+	}
+	sems, err := targetSemaphores(job.Host)
+	if err != nil {
+		return job, false, err
+	}
+	now := utils.Now()
+	keys, limits := make([]string, len(sems)), make([]int, len(sems))
+	for i, s := range sems {
+		keys[i], limits[i] = s.key, s.limit
+	}
+	ok, n, err := Queue.Claims.Claim(ctx, leaseField(job), claimGroup(job), keys, limits, now, now.Add(Limits.LockTTL.D()), Limits.MaxPPS)
+	if err != nil {
+		return job, false, err
+	}
+	if ok {
+		job.MaxRate = n
+		return job, true, nil
+	}
+
+	busy := "packet budget spent"
+	if n >= 0 && n < len(sems) {
+		busy = strings.TrimPrefix(sems[n].key, "limits:") + " busy"
+	}
+	log.Printf("Deferring %s for scan %s by %s: %s", job.Host, job.ScanID, Limits.DeferDelay, busy)
+	if err := pushJob(ctx, job, now.Add(Limits.DeferDelay.D())); err != nil {
+		return job, false, err
+	}
+	return job, false, nil
+}
+
+// renewTarget extends the job's claim by Limits.LockTTL.
+func renewTarget(ctx context.Context, job models.ScanJob) error {
+	if !Limits.Enabled() {
+		return nil
+	}
+	return Queue.Claims.RenewClaim(ctx, leaseField(job), utils.Now().Add(Limits.LockTTL.D()))
+}
+
+// releaseTarget frees the job's slots and packet share.
+func releaseTarget(ctx context.Context, job models.ScanJob) error {
+	if !Limits.Enabled() {
+		return nil
+	}
+	return Queue.Claims.ReleaseClaim(ctx, leaseField(job))
+}
+
+func (redisQueue) Claim(ctx context.Context, holder, group string, keys []string, limits []int, now, expires time.Time, budget int) (bool, int, error) {
+	args := []interface{}{holder, now.UnixMilli(), expires.UnixMilli(), budget, group}
+	for _, l := range limits {
+		args = append(args, l)
+	}
+	vals, err := claimScript.Run(ctx, database.RDB, append(append([]string(nil), limitKeys...), keys...), args...).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(vals) != 2 {
+		return false, 0, fmt.Errorf("unexpected claim reply %v", vals)
+	}
+	return vals[0] == 1, int(vals[1]), nil
+}
+
+func (redisQueue) RenewClaim(ctx context.Context, holder string, expires time.Time) error {
+	return renewScript.Run(ctx, database.RDB, limitKeys, holder, expires.UnixMilli()).Err()
+}
+
+func (redisQueue) ReleaseClaim(ctx context.Context, holder string) error {
+	return releaseScript.Run(ctx, database.RDB, limitKeys, holder).Err()
+}
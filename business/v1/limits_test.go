@@ -0,0 +1,133 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func withLimits(t *testing.T) redismock.ClientMock {
+	t.Helper()
+	business.Limits = config.Limits{
+		PerHost: 1, PerSubnet: 2, SubnetBitsV4: 24, SubnetBitsV6: 64, MaxPPS: 1000,
+		LockTTL: config.Duration(5 * time.Minute), DeferDelay: config.Duration(30 * time.Second),
+	}
+	t.Cleanup(func() { business.Limits = config.Limits{} })
+	withMemory(t)
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	return mockRedis
+}
+
+func expectClaim(mock redismock.ClientMock, holder string, sems []string, limits ...interface{}) *redismock.ExpectedCmd {
+	keys := append([]string{"limits:running", "limits:rates", "limits:held", "limits:groups"}, sems...)
+	args := append([]interface{}{holder, enqueuedAt.UnixMilli(), enqueuedAt.Add(5 * time.Minute).UnixMilli(), 1000, holder}, limits...)
+	return mock.ExpectEvalSha(business.ClaimScriptHash, keys, args...)
+}
+
+func TestClaimTarget_GrantsShareOfBudget(t *testing.T) {
+	mockRedis := withLimits(t)
+	expectClaim(mockRedis, "s1|10.1.2.3", []string{"limits:host:10.1.2.3", "limits:net:10.1.2.0/24"}, 1, 2).
+		SetVal([]interface{}{int64(1), int64(500)})
+
+	job, ok, err := business.ClaimTarget(context.Background(), models.ScanJob{ScanID: "s1", Host: "10.1.2.3"})
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 500, job.MaxRate)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestClaimTarget_CountsResolvedAddressesOfNames(t *testing.T) {
+	mockRedis := withLimits(t)
+	seedHost(t, business.Repos, "Web-1", nil, "192.0.2.10", "2001:db8::1")
+	expectClaim(mockRedis, "s1|Web-1",
+		[]string{"limits:host:web-1", "limits:host:192.0.2.10", "limits:host:2001:db8::1", "limits:net:192.0.2.0/24", "limits:net:2001:db8::/64"},
+		1, 1, 1, 2, 2).
+		SetVal([]interface{}{int64(1), int64(1000)})
+
+	_, ok, err := business.ClaimTarget(context.Background(), models.ScanJob{ScanID: "s1", Host: "Web-1"})
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestClaimTarget_DefersBusyTarget(t *testing.T) {
+	mockRedis := withLimits(t)
+	job := models.ScanJob{ScanID: "s2", Host: "10.1.2.0/28", MaxRate: 250}
+	expectClaim(mockRedis, "s2|10.1.2.0/28", []string{"limits:host:10.1.2.0/28", "limits:net:10.1.2.0/24"}, 1, 2).
+		SetVal([]interface{}{int64(0), int64(1)})
+	deferred := job
+	deferred.MaxRate = 0
+	deferredJSON, _ := json.Marshal(deferred)
+	mockRedis.ExpectZAdd("scan_jobs:delayed", redis.Z{Score: float64(enqueuedAt.Add(30 * time.Second).Unix()), Member: deferredJSON}).SetVal(1)
+
+	_, ok, err := business.ClaimTarget(context.Background(), job)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestClaimTarget_NoLimitsNoRedis(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+
+	job, ok, err := business.ClaimTarget(context.Background(), models.ScanJob{ScanID: "s1", Host: "h", MaxRate: 10})
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, job.MaxRate)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestReleaseTarget(t *testing.T) {
+	mockRedis := withLimits(t)
+	mockRedis.ExpectEvalSha(business.ReleaseScriptHash, []string{"limits:running", "limits:rates", "limits:held", "limits:groups"}, "s1|h").SetVal(int64(1))
+
+	assert.NoError(t, business.ReleaseTarget(context.Background(), models.ScanJob{ScanID: "s1", Host: "h"}))
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestClaimTarget_ShardsOfOneHostShareItsSlot(t *testing.T) {
+	ctx := context.Background()
+	withMemory(t)
+	q := withMemoryQueue(t, "")
+	business.Limits = config.Default().Limits
+	t.Cleanup(func() { business.Limits = config.Limits{} })
+	shard := func(scanID string, i int) models.ScanJob {
+		return models.ScanJob{ScanID: scanID, Host: "10.1.2.3", Ports: "1-65535", Shard: i, Shards: 2}
+	}
+
+	_, ok, err := business.ClaimTarget(ctx, shard("s1", 0))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = business.ClaimTarget(ctx, shard("s1", 1))
+	assert.NoError(t, err)
+	assert.True(t, ok, "the second shard runs alongside the first")
+
+	_, ok, err = business.ClaimTarget(ctx, shard("s2", 0))
+	assert.NoError(t, err)
+	assert.False(t, ok, "another scan of the host waits")
+
+	assert.NoError(t, business.ReleaseTarget(ctx, shard("s1", 0)))
+	_, ok, _ = business.ClaimTarget(ctx, shard("s2", 0))
+	assert.False(t, ok, "the host stays busy while a shard of s1 runs")
+	assert.NoError(t, business.ReleaseTarget(ctx, shard("s1", 1)))
+	_, ok, _ = business.ClaimTarget(ctx, shard("s2", 0))
+	assert.True(t, ok)
+	delayed, err := q.TakeDelayed(ctx, enqueuedAt.Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.1.2.3"}, hosts(delayed), "the busy shard was deferred")
+}
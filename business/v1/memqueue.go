@@ -0,0 +1,889 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+// MemoryQueue holds every queue store in process memory, for a single binary
+// that both queues and runs every job. It keeps the Redis queue's order: the most
+// urgent priority first and, within it, one job per scan in turn.
+//
+// With a write-ahead file, waiting and delayed jobs, leases, unfinished
+// shards and keys survive restarts. So do popped jobs until they are
+// finished, pushed, delayed or leased again: a crash interrupts their scans,
+// and reopening the file queues them again first. Claims and start counts
+// are not logged: the scans that held them do not survive a restart either.
+type MemoryQueue struct {
+	mu      sync.Mutex
+	queues  map[string]*memJobs  // by zone
+	delayed map[string]time.Time // job JSON → when it may run
+	leases  map[string][]byte
+	popped  map[string]string // ScanJob.RunKey → JSON of jobs not yet finished
+	claims  memClaims
+	shards  map[string]*memShards
+	keys    map[string]memKey
+	swept   time.Time // when expired entries were last dropped
+	wal     *wal
+}
+
+// memJobs is one zone's queue.
+type memJobs struct {
+	levels  map[string]*memLevel // by priority
+	waiting int64
+	ready   chan struct{} // one signal per push, for idle poppers
+	popped  map[int64]int64
+}
+
+// memLevel is one priority of a queue: a ring of the scans with waiting
+// jobs and each scan's jobs as JSON, FIFO.
+type memLevel struct {
+	ring  []string
+	scans map[string][]string
+}
+
+type memClaims struct {
+	running map[string]time.Time            // holder → when its claim lapses
+	rates   map[string]int                  // holder → granted packets per second
+	held    map[string][]string             // holder → semaphores it holds
+	groups  map[string]string               // holder → its claim group
+	sems    map[string]map[string]time.Time // semaphore → holder → lapse
+}
+
+type memShards struct {
+	outcomes map[int][]byte
+	expires  time.Time
+}
+
+type memKey struct {
+	value   []byte
+	expires time.Time // zero for never
+}
+
+// sweepEvery is how often expired keys, shard sets and claims are dropped.
+// They read as absent already; dropping them bounds the memory they hold.
+const sweepEvery = time.Minute
+
+// readySignals caps the wake-ups kept for idle poppers, as Redis does.
+const readySignals = 1000
+
+// NewMemoryQueue returns an empty in-process queue or, with walPath, the
+// queue recorded in that write-ahead file, which it then keeps appending to.
+func NewMemoryQueue(walPath string) (*MemoryQueue, error) {
+	m := &MemoryQueue{
+		queues:  map[string]*memJobs{},
+		delayed: map[string]time.Time{},
+		leases:  map[string][]byte{},
+		popped:  map[string]string{},
+		claims: memClaims{
+			running: map[string]time.Time{},
+			rates:   map[string]int{},
+			held:    map[string][]string{},
+			groups:  map[string]string{},
+			sems:    map[string]map[string]time.Time{},
+		},
+		shards: map[string]*memShards{},
+		keys:   map[string]memKey{},
+	}
+	if walPath == "" {
+		return m, nil
+	}
+	w, records, err := openWAL(walPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		m.apply(r)
+	}
+	interrupted := m.requeuePopped()
+	m.wal = w
+	if err := m.compact(); err != nil {
+		w.close()
+		return nil, err
+	}
+	var waiting int64
+	for _, q := range m.queues {
+		waiting += q.waiting
+	}
+	log.Printf("Recovered %d waiting (%d interrupted) and %d delayed jobs from %s", waiting, interrupted, len(m.delayed), walPath)
+	return m, nil
+}
+
+// Stores hands out the queue as each store.
+func (m *MemoryQueue) Stores() QueueStores {
+	return QueueStores{Jobs: m, Leases: m, Claims: m, Shards: m, Keys: m}
+}
+
+// Close closes the write-ahead file; changes after it fail.
+func (m *MemoryQueue) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.wal == nil {
+		return nil
+	}
+	return m.wal.close()
+}
+
+// requeuePopped puts the jobs popped before a restart back at the front of
+// their scans' queues, oldest first, and returns how many there were.
+func (m *MemoryQueue) requeuePopped() int {
+	jobs := make([]models.ScanJob, 0, len(m.popped))
+	for _, v := range m.popped {
+		var job models.ScanJob
+		if err := json.Unmarshal([]byte(v), &job); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	// Pushing to the front reverses them, so the newest go first.
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].EnqueuedAt > jobs[b].EnqueuedAt ||
+			jobs[a].EnqueuedAt == jobs[b].EnqueuedAt && jobs[a].RunKey() > jobs[b].RunKey()
+	})
+	for _, job := range jobs {
+		m.push(job, m.popped[job.RunKey()], true)
+	}
+	clear(m.popped)
+	return len(jobs)
+}
+
+// log appends records to the write-ahead file, compacting it once it has
+// grown well past what it describes.
+func (m *MemoryQueue) log(records ...walRecord) error {
+	if m.wal == nil {
+		return nil
+	}
+	if err := m.wal.append(records...); err != nil {
+		return err
+	}
+	if m.wal.appended >= walCompactEvery {
+		if err := m.compact(); err != nil {
+			log.Printf("Failed to compact %s: %v", m.wal.path, err)
+		}
+	}
+	return nil
+}
+
+// compact rewrites the write-ahead file as the records recreating the
+// current state.
+func (m *MemoryQueue) compact() error {
+	var records []walRecord
+	zones := make([]string, 0, len(m.queues))
+	for zone := range m.queues {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		q := m.queues[zone]
+		for _, p := range models.Priorities {
+			l := q.levels[p]
+			if l == nil {
+				continue
+			}
+			for _, sid := range l.ring {
+				for _, v := range l.scans[sid] {
+					records = append(records, walRecord{Op: "push", Value: v})
+				}
+			}
+		}
+	}
+	for _, v := range m.popped {
+		records = append(records, walRecord{Op: "popped", Value: v})
+	}
+	for v, at := range m.delayed {
+		records = append(records, walRecord{Op: "delay", Value: v, At: at.UnixMilli()})
+	}
+	for field, v := range m.leases {
+		records = append(records, walRecord{Op: "lease", Key: field, Value: string(v)})
+	}
+	now := utils.Now()
+	for key, s := range m.shards {
+		if !s.expires.After(now) {
+			continue
+		}
+		for shard, v := range s.outcomes {
+			records = append(records, walRecord{Op: "shard", Key: key, Shard: shard, Value: string(v), At: s.expires.UnixMilli()})
+		}
+	}
+	for key, k := range m.keys {
+		if k.live(now) {
+			records = append(records, walRecord{Op: "set", Key: key, Value: string(k.value), At: unixMilli(k.expires)})
+		}
+	}
+	return m.wal.rewrite(records)
+}
+
+// apply replays one logged change.
+func (m *MemoryQueue) apply(r walRecord) {
+	switch r.Op {
+	case "push", "pop", "popped", "delay":
+		var job models.ScanJob
+		if err := json.Unmarshal([]byte(r.Value), &job); err != nil {
+			log.Printf("Invalid job in write-ahead file: %v", err)
+			return
+		}
+		switch r.Op {
+		case "push":
+			m.push(job, r.Value, r.Front)
+			delete(m.popped, job.RunKey())
+		case "pop":
+			m.remove(job, r.Value)
+			m.popped[job.RunKey()] = r.Value
+		case "popped":
+			m.popped[job.RunKey()] = r.Value
+		case "delay":
+			m.delayed[r.Value] = time.UnixMilli(r.At)
+			delete(m.popped, job.RunKey())
+		}
+	case "finish":
+		delete(m.popped, r.Key)
+	case "undelay":
+		delete(m.delayed, r.Value)
+	case "lease":
+		m.leases[r.Key] = []byte(r.Value)
+		delete(m.popped, r.Key)
+	case "unlease":
+		delete(m.leases, r.Key)
+	case "shard":
+		m.shardSet(r.Key, time.UnixMilli(r.At)).outcomes[r.Shard] = []byte(r.Value)
+	case "unshard":
+		delete(m.shards, r.Key)
+	case "set":
+		m.keys[r.Key] = memKey{value: []byte(r.Value), expires: fromUnixMilli(r.At)}
+	case "del":
+		delete(m.keys, r.Key)
+	default:
+		log.Printf("Unknown %q record in write-ahead file", r.Op)
+	}
+}
+
+func (m *MemoryQueue) queue(zone string) *memJobs {
+	q := m.queues[zone]
+	if q == nil {
+		q = &memJobs{levels: map[string]*memLevel{}, ready: make(chan struct{}, readySignals), popped: map[int64]int64{}}
+		m.queues[zone] = q
+	}
+	return q
+}
+
+func (q *memJobs) level(priority string) *memLevel {
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	l := q.levels[priority]
+	if l == nil {
+		l = &memLevel{scans: map[string][]string{}}
+		q.levels[priority] = l
+	}
+	return l
+}
+
+func (m *MemoryQueue) push(job models.ScanJob, v string, front bool) {
+	q := m.queue(job.Zone)
+	l := q.level(job.Priority)
+	jobs := l.scans[job.ScanID]
+	if front {
+		jobs = append([]string{v}, jobs...)
+	} else {
+		jobs = append(jobs, v)
+	}
+	l.scans[job.ScanID] = jobs
+	if len(jobs) == 1 {
+		if front {
+			l.ring = append([]string{job.ScanID}, l.ring...)
+		} else {
+			l.ring = append(l.ring, job.ScanID)
+		}
+	}
+	q.waiting++
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// remove drops one waiting copy of job, as a replayed pop.
+func (m *MemoryQueue) remove(job models.ScanJob, v string) {
+	q := m.queue(job.Zone)
+	l := q.level(job.Priority)
+	jobs := l.scans[job.ScanID]
+	for i, w := range jobs {
+		if w == v {
+			l.take(job.ScanID, i)
+			q.waiting--
+			return
+		}
+	}
+}
+
+// take removes the scan's i-th job, and the scan from the ring when it has
+// none left.
+func (l *memLevel) take(scanID string, i int) string {
+	jobs := l.scans[scanID]
+	v := jobs[i]
+	jobs = append(jobs[:i:i], jobs[i+1:]...)
+	if len(jobs) > 0 {
+		l.scans[scanID] = jobs
+		return v
+	}
+	delete(l.scans, scanID)
+	for j := len(l.ring) - 1; j >= 0; j-- {
+		if l.ring[j] == scanID {
+			l.ring = append(l.ring[:j:j], l.ring[j+1:]...)
+			break
+		}
+	}
+	return v
+}
+
+func (m *MemoryQueue) Push(ctx context.Context, job models.ScanJob, front bool) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.log(walRecord{Op: "push", Value: string(b), Front: front}); err != nil {
+		return err
+	}
+	m.push(job, string(b), front)
+	delete(m.popped, job.RunKey())
+	return nil
+}
+
+func (m *MemoryQueue) Pop(ctx context.Context, zone string, max int) ([]models.ScanJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.queue(zone)
+	var out []string
+	for _, p := range models.Priorities {
+		l := q.levels[p]
+		if l == nil {
+			continue
+		}
+		for len(out) < max && len(l.ring) > 0 {
+			sid := l.ring[0]
+			l.ring = append(l.ring[1:], sid)
+			out = append(out, l.take(sid, 0))
+		}
+	}
+	if len(out) > 0 {
+		q.waiting -= int64(len(out))
+		minute := utils.Now().Unix() / 60
+		q.popped[minute] += int64(len(out))
+		for at := range q.popped {
+			if at < minute-int64(rateWindow/time.Minute) {
+				delete(q.popped, at)
+			}
+		}
+		records := make([]walRecord, len(out))
+		for i, v := range out {
+			records[i] = walRecord{Op: "pop", Value: v}
+		}
+		// The jobs are handed out either way; at worst they run again after
+		// a restart.
+		if err := m.log(records...); err != nil {
+			log.Printf("Failed to log popped jobs: %v", err)
+		}
+	}
+	if m.wal != nil {
+		// Until finished they are queued again by a restart.
+		for _, v := range out {
+			var job models.ScanJob
+			if err := json.Unmarshal([]byte(v), &job); err == nil {
+				m.popped[job.RunKey()] = v
+			}
+		}
+	}
+	if q.waiting <= 0 {
+		// Nothing waits: drop signals left by pushes that busy poppers took.
+		for len(q.ready) > 0 {
+			<-q.ready
+		}
+	}
+	return decodeJobs(out, "job"), nil
+}
+
+func (m *MemoryQueue) Finish(ctx context.Context, job models.ScanJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := job.RunKey()
+	if _, ok := m.popped[key]; !ok {
+		return nil
+	}
+	if err := m.log(walRecord{Op: "finish", Key: key}); err != nil {
+		return err
+	}
+	delete(m.popped, key)
+	return nil
+}
+
+func (m *MemoryQueue) Wait(ctx context.Context, zone string, timeout time.Duration) error {
+	m.mu.Lock()
+	ready := m.queue(zone).ready
+	m.mu.Unlock()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-ready:
+	case <-t.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (m *MemoryQueue) Len(ctx context.Context, zone string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queue(zone).waiting, nil
+}
+
+func (m *MemoryQueue) Position(ctx context.Context, scanID, zone, priority string) (int, int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.queue(zone)
+	ahead := 0
+	for _, p := range models.Priorities {
+		if p == priority {
+			break
+		}
+		if l := q.levels[p]; l != nil {
+			for _, sid := range l.ring {
+				ahead += len(l.scans[sid])
+			}
+		}
+	}
+	l := q.level(priority)
+	mine := len(l.scans[scanID])
+	if mine == 0 {
+		return 0, 0, 0, nil
+	}
+	first, last, before := ahead, ahead+mine-1, true
+	for _, sid := range l.ring {
+		if sid == scanID {
+			before = false
+			continue
+		}
+		n := len(l.scans[sid])
+		if before {
+			first += min(n, 1)
+			last += min(n, mine)
+		} else {
+			last += min(n, mine-1)
+		}
+	}
+	return first, last, mine, nil
+}
+
+func (m *MemoryQueue) Started(ctx context.Context, zone string, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.queue(zone)
+	minute := utils.Now().Unix() / 60
+	var started int64
+	for at := minute - int64(window/time.Minute); at < minute; at++ {
+		started += q.popped[at]
+	}
+	return started, nil
+}
+
+func (m *MemoryQueue) Delay(ctx context.Context, job models.ScanJob, at time.Time) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.log(walRecord{Op: "delay", Value: string(b), At: at.UnixMilli()}); err != nil {
+		return err
+	}
+	m.delayed[string(b)] = at
+	delete(m.popped, job.RunKey())
+	return nil
+}
+
+func (m *MemoryQueue) TakeDelayed(ctx context.Context, now time.Time, max int) ([]models.ScanJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []string
+	for v, at := range m.delayed {
+		if at.Unix() <= now.Unix() {
+			due = append(due, v)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := m.delayed[due[i]].Unix(), m.delayed[due[j]].Unix()
+		return a < b || a == b && due[i] < due[j]
+	})
+	if len(due) > max {
+		due = due[:max]
+	}
+	records := make([]walRecord, len(due))
+	for i, v := range due {
+		records[i] = walRecord{Op: "undelay", Value: v}
+	}
+	if err := m.log(records...); err != nil {
+		return nil, err
+	}
+	for _, v := range due {
+		delete(m.delayed, v)
+	}
+	return decodeJobs(due, "delayed job"), nil
+}
+
+func (m *MemoryQueue) PutLease(ctx context.Context, field string, lease []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.log(walRecord{Op: "lease", Key: field, Value: string(lease)}); err != nil {
+		return err
+	}
+	m.leases[field] = lease
+	delete(m.popped, field) // the lease requeues it if the agent is lost
+	return nil
+}
+
+func (m *MemoryQueue) GetLease(ctx context.Context, field string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.leases[field]
+	return v, ok, nil
+}
+
+func (m *MemoryQueue) DeleteLease(ctx context.Context, field string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.leases[field]; !ok {
+		return false, nil
+	}
+	if err := m.log(walRecord{Op: "unlease", Key: field}); err != nil {
+		return false, err
+	}
+	delete(m.leases, field)
+	return true, nil
+}
+
+func (m *MemoryQueue) RenewLease(ctx context.Context, field string, agentID int64, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.leases[field]
+	if !ok {
+		return false, nil
+	}
+	var l lease
+	if err := json.Unmarshal(v, &l); err != nil || l.AgentID != agentID {
+		return false, err
+	}
+	l.Expires = expires.Unix()
+	b, err := json.Marshal(l)
+	if err != nil {
+		return false, err
+	}
+	if err := m.log(walRecord{Op: "lease", Key: field, Value: string(b)}); err != nil {
+		return false, err
+	}
+	m.leases[field] = b
+	return true, nil
+}
+
+func (m *MemoryQueue) Leases(ctx context.Context) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	leases := make(map[string][]byte, len(m.leases))
+	for field, v := range m.leases {
+		leases[field] = v
+	}
+	return leases, nil
+}
+
+func (m *MemoryQueue) Claim(ctx context.Context, holder, group string, keys []string, limits []int, now, expires time.Time, budget int) (bool, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	c := &m.claims
+	c.lapseRunning(now)
+	for i, key := range keys {
+		c.lapseSem(key, now)
+		sem := c.sems[key]
+		others := map[string]bool{}
+		shared := false
+		for h := range sem {
+			g, ok := c.groups[h]
+			if !ok {
+				g = h
+			}
+			if g == group {
+				shared = true
+			}
+			others[g] = true
+		}
+		if !shared && len(others) >= limits[i] {
+			return false, i, nil
+		}
+	}
+	rate := 0
+	if budget > 0 {
+		delete(c.rates, holder)
+		used := 0
+		for _, r := range c.rates {
+			used += r
+		}
+		delete(c.running, holder)
+		rate = min(budget/(len(c.running)+1), budget-used)
+		if rate < 1 {
+			return false, -1, nil
+		}
+		c.rates[holder] = rate
+	}
+	c.running[holder] = expires
+	for _, key := range keys {
+		if c.sems[key] == nil {
+			c.sems[key] = map[string]time.Time{}
+		}
+		c.sems[key][holder] = expires
+	}
+	c.held[holder] = append([]string(nil), keys...)
+	c.groups[holder] = group
+	return true, rate, nil
+}
+
+func (m *MemoryQueue) RenewClaim(ctx context.Context, holder string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &m.claims
+	held, ok := c.held[holder]
+	if !ok {
+		return nil
+	}
+	if _, ok := c.running[holder]; ok {
+		c.running[holder] = expires
+	}
+	for _, key := range held {
+		if _, ok := c.sems[key][holder]; ok {
+			c.sems[key][holder] = expires
+		}
+	}
+	return nil
+}
+
+func (m *MemoryQueue) ReleaseClaim(ctx context.Context, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &m.claims
+	for _, key := range c.held[holder] {
+		delete(c.sems[key], holder)
+		if len(c.sems[key]) == 0 {
+			delete(c.sems, key)
+		}
+	}
+	delete(c.running, holder)
+	delete(c.rates, holder)
+	delete(c.held, holder)
+	delete(c.groups, holder)
+	return nil
+}
+
+// lapseRunning forgets holders whose claims lapsed by now.
+func (c *memClaims) lapseRunning(now time.Time) {
+	for h, lapse := range c.running {
+		if !lapse.After(now) {
+			delete(c.running, h)
+			delete(c.rates, h)
+			delete(c.held, h)
+			delete(c.groups, h)
+		}
+	}
+}
+
+// lapseSem drops the holders of semaphore key whose claims lapsed by now,
+// and the semaphore once it has none.
+func (c *memClaims) lapseSem(key string, now time.Time) {
+	sem := c.sems[key]
+	for h, lapse := range sem {
+		if !lapse.After(now) {
+			delete(sem, h)
+		}
+	}
+	if len(sem) == 0 {
+		delete(c.sems, key)
+	}
+}
+
+// sweep drops expired keys and shard sets and lapsed claims, at most once
+// per sweepEvery; the caller holds m.mu. Without a write-ahead file nothing
+// else would, since compaction needs one. Nothing is logged: replay and
+// compaction skip expired entries too.
+func (m *MemoryQueue) sweep(now time.Time) {
+	if now.Before(m.swept.Add(sweepEvery)) {
+		return
+	}
+	m.swept = now
+	for key, k := range m.keys {
+		if !k.live(now) {
+			delete(m.keys, key)
+		}
+	}
+	for key, s := range m.shards {
+		if !s.expires.After(now) {
+			delete(m.shards, key)
+		}
+	}
+	m.claims.lapseRunning(now)
+	for key := range m.claims.sems {
+		m.claims.lapseSem(key, now)
+	}
+}
+
+// shardSet returns key's shards, starting over when they expired.
+func (m *MemoryQueue) shardSet(key string, expires time.Time) *memShards {
+	s := m.shards[key]
+	if s == nil || !s.expires.After(utils.Now()) {
+		s = &memShards{outcomes: map[int][]byte{}}
+		m.shards[key] = s
+	}
+	s.expires = expires
+	return s
+}
+
+func (m *MemoryQueue) AddShard(ctx context.Context, key string, shard, shards int, outcome []byte, ttl time.Duration) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(utils.Now())
+	expires := utils.Now().Add(ttl)
+	s := m.shardSet(key, expires)
+	in := len(s.outcomes)
+	if _, seen := s.outcomes[shard]; !seen {
+		in++
+	}
+	if in < shards {
+		if err := m.log(walRecord{Op: "shard", Key: key, Shard: shard, Value: string(outcome), At: expires.UnixMilli()}); err != nil {
+			return nil, err
+		}
+		s.outcomes[shard] = outcome
+		return nil, nil
+	}
+	if err := m.log(walRecord{Op: "unshard", Key: key}); err != nil {
+		return nil, err
+	}
+	s.outcomes[shard] = outcome
+	delete(m.shards, key)
+	indexes := make([]int, 0, len(s.outcomes))
+	for i := range s.outcomes {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	all := make([][]byte, len(indexes))
+	for i, idx := range indexes {
+		all[i] = s.outcomes[idx]
+	}
+	return all, nil
+}
+
+func (k memKey) live(now time.Time) bool {
+	return k.expires.IsZero() || k.expires.After(now)
+}
+
+// expiry is when a key set now with ttl lapses; zero for never.
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return utils.Now().Add(ttl)
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// setKey logs and stores key; the caller holds m.mu.
+func (m *MemoryQueue) setKey(key string, value []byte, expires time.Time) error {
+	m.sweep(utils.Now())
+	if err := m.log(walRecord{Op: "set", Key: key, Value: string(value), At: unixMilli(expires)}); err != nil {
+		return err
+	}
+	m.keys[key] = memKey{value: value, expires: expires}
+	return nil
+}
+
+// delKey logs and deletes key; the caller holds m.mu.
+func (m *MemoryQueue) delKey(key string) error {
+	if err := m.log(walRecord{Op: "del", Key: key}); err != nil {
+		return err
+	}
+	delete(m.keys, key)
+	return nil
+}
+
+func (m *MemoryQueue) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[key]; ok && k.live(utils.Now()) {
+		return false, nil
+	}
+	return true, m.setKey(key, value, expiry(ttl))
+}
+
+func (m *MemoryQueue) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !k.live(utils.Now()) {
+		delete(m.keys, key)
+		return nil, false, nil
+	}
+	return k.value, true, nil
+}
+
+func (m *MemoryQueue) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setKey(key, value, expiry(ttl))
+}
+
+func (m *MemoryQueue) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[key]; !ok {
+		return nil
+	}
+	return m.delKey(key)
+}
+
+func (m *MemoryQueue) RenewKey(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[key]
+	if !ok || !k.live(utils.Now()) || string(k.value) != string(value) {
+		return false, nil
+	}
+	return true, m.setKey(key, value, expiry(ttl))
+}
+
+func (m *MemoryQueue) ReleaseKey(ctx context.Context, key string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[key]
+	if !ok || !k.live(utils.Now()) || string(k.value) != string(value) {
+		return false, nil
+	}
+	return true, m.delKey(key)
+}
@@ -0,0 +1,202 @@
+package v1_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMemoryQueue points business logic at an in-process queue recorded in
+// wal (none when empty).
+func withMemoryQueue(t *testing.T, wal string) *business.MemoryQueue {
+	t.Helper()
+	q, err := business.NewMemoryQueue(wal)
+	require.NoError(t, err)
+	saved := business.Queue
+	t.Cleanup(func() {
+		business.Queue = saved
+		q.Close()
+	})
+	business.Queue = q.Stores()
+	return q
+}
+
+func hosts(jobs []models.ScanJob) []string {
+	var out []string
+	for _, j := range jobs {
+		out = append(out, j.Host)
+	}
+	return out
+}
+
+func TestMemoryQueue_PopsByPriorityThenScansInTurn(t *testing.T) {
+	ctx := context.Background()
+	q := withMemoryQueue(t, "")
+	for _, job := range []models.ScanJob{
+		{ScanID: "big", Host: "a1"}, {ScanID: "big", Host: "a2"}, {ScanID: "big", Host: "a3"},
+		{ScanID: "small", Host: "b1"},
+		{ScanID: "urgent", Host: "h1", Priority: "high"},
+		{ScanID: "other", Host: "z1", Zone: "dmz"},
+	} {
+		require.NoError(t, business.Enqueue(ctx, job))
+	}
+	require.NoError(t, business.PushFront(ctx, models.ScanJob{ScanID: "small", Host: "b0"}))
+
+	first, last, queued, err := q.Position(ctx, "small", "", "normal")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 2}, []int{first, last, queued})
+	n, _ := business.QueueLength(ctx, "")
+	assert.Equal(t, int64(6), n)
+
+	jobs, err := business.PopJobs(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h1", "a1", "b0", "a2", "b1", "a3"}, hosts(jobs))
+	n, _ = business.QueueLength(ctx, "")
+	assert.Zero(t, n)
+	started, _ := q.Started(ctx, "", time.Hour)
+	assert.Zero(t, started, "the current minute is not complete yet")
+
+	jobs, err = business.WaitJobs(ctx, "dmz", 5, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z1"}, hosts(jobs))
+}
+
+func TestMemoryQueue_WaitWakesOnPush(t *testing.T) {
+	ctx := context.Background()
+	q := withMemoryQueue(t, "")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(ctx, models.ScanJob{ScanID: "s1", Host: "web-1"}, false)
+	}()
+
+	start := time.Now()
+	jobs, err := business.WaitJobs(ctx, "", 1, 5*time.Second)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web-1"}, hosts(jobs))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestMemoryQueue_ClaimsLimitHoldersAndShareBudget(t *testing.T) {
+	ctx := context.Background()
+	q := withMemoryQueue(t, "")
+	expires := enqueuedAt.Add(time.Minute)
+
+	ok, rate, err := q.Claim(ctx, "s1|web-1", "s1|web-1", []string{"limits:host:web-1"}, []int{1}, enqueuedAt, expires, 1000)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, rate)
+
+	ok, full, err := q.Claim(ctx, "s2|web-1", "s2|web-1", []string{"limits:net:10.0.0.0/24", "limits:host:web-1"}, []int{2, 1}, enqueuedAt, expires, 1000)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, full, "the host semaphore is full")
+
+	ok, full, err = q.Claim(ctx, "s3|web-2", "s3|web-2", nil, nil, enqueuedAt, expires, 1000)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, -1, full, "the first scan holds the whole budget")
+
+	require.NoError(t, q.ReleaseClaim(ctx, "s1|web-1"))
+	ok, rate, err = q.Claim(ctx, "s2|web-1", "s2|web-1", []string{"limits:host:web-1"}, []int{1}, enqueuedAt, expires, 1000)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, rate)
+
+	ok, _, err = q.Claim(ctx, "s4|web-1", "s4|web-1", []string{"limits:host:web-1"}, []int{1}, expires, expires.Add(time.Minute), 0)
+	require.NoError(t, err)
+	assert.True(t, ok, "an unrenewed claim lapses")
+}
+
+func TestMemoryQueue_RecoversFromWriteAheadFile(t *testing.T) {
+	ctx := context.Background()
+	wal := filepath.Join(t.TempDir(), "queue.wal")
+	q := withMemoryQueue(t, wal)
+	for _, host := range []string{"web-1", "web-2", "web-3"} {
+		require.NoError(t, q.Push(ctx, models.ScanJob{ScanID: "s1", Host: host}, false))
+	}
+	jobs, err := q.Pop(ctx, "", 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"web-1"}, hosts(jobs))
+	require.NoError(t, q.Delay(ctx, models.ScanJob{ScanID: "s2", Host: "db-1"}, enqueuedAt.Add(time.Hour)))
+	require.NoError(t, q.PutLease(ctx, "s1|web-1", []byte(`{"agent_id":1}`)))
+	require.NoError(t, q.PutLease(ctx, "s1|web-9", []byte(`{"agent_id":2}`)))
+	_, err = q.DeleteLease(ctx, "s1|web-9")
+	require.NoError(t, err)
+	held, err := q.AddShard(ctx, "scan_shards:s3|web-1", 0, 2, []byte(`{"shard":0}`), time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, held)
+	require.NoError(t, q.Set(ctx, "idempotency:scan:k1", []byte("done"), time.Hour))
+	require.NoError(t, q.Close())
+
+	q = withMemoryQueue(t, wal)
+
+	jobs, err = q.Pop(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web-2", "web-3"}, hosts(jobs), "popped jobs stay popped")
+	delayed, err := q.TakeDelayed(ctx, enqueuedAt.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db-1"}, hosts(delayed))
+	leases, err := q.Leases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"s1|web-1": []byte(`{"agent_id":1}`)}, leases)
+	all, err := q.AddShard(ctx, "scan_shards:s3|web-1", 1, 2, []byte(`{"shard":1}`), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte(`{"shard":0}`), []byte(`{"shard":1}`)}, all)
+	v, ok, err := q.Get(ctx, "idempotency:scan:k1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("done"), v)
+}
+
+func TestMemoryQueue_RequeuesJobsInterruptedByACrash(t *testing.T) {
+	ctx := context.Background()
+	wal := filepath.Join(t.TempDir(), "queue.wal")
+	q := withMemoryQueue(t, wal)
+	for i, host := range []string{"web-1", "web-2", "web-3", "web-4"} {
+		require.NoError(t, q.Push(ctx, models.ScanJob{ScanID: "s1", Host: host, EnqueuedAt: int64(i)}, false))
+	}
+	jobs, err := q.Pop(ctx, "", 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"web-1", "web-2", "web-3"}, hosts(jobs))
+	require.NoError(t, q.Finish(ctx, jobs[1]))
+	// The process dies with web-1 and web-3 still scanning.
+	require.NoError(t, q.Close())
+
+	q = withMemoryQueue(t, wal)
+
+	jobs, err = q.Pop(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "web-3", "web-4"}, hosts(jobs), "interrupted jobs run again first")
+}
+
+func TestMemoryQueue_DropsExpiredEntriesWithoutAWriteAheadFile(t *testing.T) {
+	ctx := context.Background()
+	q := withMemoryQueue(t, "")
+	require.NoError(t, q.Set(ctx, "idempotency:scan:k1", []byte("done"), time.Minute))
+	held, err := q.AddShard(ctx, "scan_shards:s1|web-1", 0, 2, []byte(`{"shard":0}`), time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, held)
+	ok, _, err := q.Claim(ctx, "s1|web-1", "s1|web-1", []string{"limits:host:web-1"}, []int{1}, enqueuedAt, enqueuedAt.Add(30*time.Second), 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	later := enqueuedAt.Add(2 * time.Hour)
+	saved := utils.Now
+	t.Cleanup(func() { utils.Now = saved })
+	utils.Now = func() time.Time { return later }
+	require.NoError(t, q.Set(ctx, "scheduler:last_run:prune", []byte("1"), 0))
+
+	keys, shards, sems := q.Entries()
+	assert.Equal(t, 1, keys, "only the key without a time to live is left")
+	assert.Zero(t, shards, "an unfinished shard set is dropped once it expires")
+	assert.Zero(t, sems, "a semaphore is dropped once its claims lapse")
+}
@@ -0,0 +1,515 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The job queue lives behind Queue: Redis when several processes share it,
+// or process memory for a single binary. In Redis every queue (the central
+// workers' or a zone's) has, per priority, a ring of the scans with waiting
+// jobs and one job list per scan:
+//
+//	<queue>:<priority>:rr             scan IDs, taken in turn
+//	<queue>:<priority>:scan:<scan_id> that scan's jobs, FIFO
+//	<queue>:len                       waiting jobs in all levels
+//	<queue>:ready                     wake-up signals for idle poppers
+//	<queue>:popped:<unix minute>      jobs started that minute, for estimates
+//
+// Popping takes from the most urgent non-empty priority and, within it, one
+// job from the next scan in the ring, so a large scan cannot hold every
+// worker while a small one waits behind it.
+var (
+	Enqueue       = enqueue
+	PushFront     = pushFront
+	PopJobs       = popJobs
+	WaitJobs      = waitJobs
+	QueueLength   = queueLength
+	QueuePosition = queuePosition
+	ScanQueue     = scanQueue
+
+	ErrInvalidPriority = errors.New("priority must be high, normal or low")
+)
+
+// JobQueue holds the jobs waiting to run, in one queue per zone, and the
+// delayed ones. Values are the callers' JSON.
+type JobQueue interface {
+	// Push adds job to its zone's queue at its priority, behind its scan's
+	// other jobs or, with front, ahead of them.
+	Push(ctx context.Context, job models.ScanJob, front bool) error
+	// Pop takes up to max jobs from zone's queue without waiting.
+	Pop(ctx context.Context, zone string, max int) ([]models.ScanJob, error)
+	// Wait returns once a job may have been pushed to zone's queue, or after
+	// timeout.
+	Wait(ctx context.Context, zone string, timeout time.Duration) error
+	Len(ctx context.Context, zone string) (int64, error)
+	// Position returns how many jobs are ahead of the scan's next and last
+	// job waiting at priority in zone's queue, and how many it has waiting.
+	Position(ctx context.Context, scanID, zone, priority string) (first, last, queued int, err error)
+	// Started counts the jobs popped from zone's queue in the whole minutes
+	// of the last window.
+	Started(ctx context.Context, zone string, window time.Duration) (int64, error)
+	// Finish tells the queue a popped job's outcome is stored. A popped job
+	// that is neither finished nor pushed, delayed or leased again may be
+	// queued again after a restart.
+	Finish(ctx context.Context, job models.ScanJob) error
+
+	// Delay holds job until at; TakeDelayed removes and returns up to max
+	// jobs due by now. A job is only ever taken once.
+	Delay(ctx context.Context, job models.ScanJob, at time.Time) error
+	TakeDelayed(ctx context.Context, now time.Time, max int) ([]models.ScanJob, error)
+}
+
+// LeaseStore holds the leases of jobs handed to agents, by leaseField.
+// GetLease reports ok false for unknown fields; DeleteLease reports whether
+// it deleted, so only one caller ever owns a lease's job.
+type LeaseStore interface {
+	PutLease(ctx context.Context, field string, lease []byte) error
+	GetLease(ctx context.Context, field string) (lease []byte, ok bool, err error)
+	DeleteLease(ctx context.Context, field string) (bool, error)
+	// RenewLease moves the lease's expiry to expires if it still exists and
+	// belongs to agentID, in one step, and reports whether it did.
+	RenewLease(ctx context.Context, field string, agentID int64, expires time.Time) (bool, error)
+	Leases(ctx context.Context) (map[string][]byte, error)
+}
+
+// ClaimStore holds the per-target claims that enforce Limits.
+type ClaimStore interface {
+	// Claim takes a slot for holder in every semaphore in keys, each limited
+	// to the matching limits entry, until expires, plus a share of budget
+	// packets per second when budget is positive. Semaphores count groups:
+	// holders of the same group share a slot. It returns ok and the granted
+	// rate, or the index of the full semaphore (-1 when the budget is spent).
+	// Claims lapse at expires unless renewed.
+	Claim(ctx context.Context, holder, group string, keys []string, limits []int, now, expires time.Time, budget int) (ok bool, n int, err error)
+	RenewClaim(ctx context.Context, holder string, expires time.Time) error
+	ReleaseClaim(ctx context.Context, holder string) error
+}
+
+// ShardStore collects the outcomes of a sharded host's scans.
+type ShardStore interface {
+	// AddShard records shard's outcome under key and, once all shards are
+	// in, removes and returns them all to exactly one caller. Unfinished sets
+	// expire after ttl.
+	AddShard(ctx context.Context, key string, shard, shards int, outcome []byte, ttl time.Duration) ([][]byte, error)
+}
+
+// KeyStore holds short-lived keys with an optional time to live (0 keeps
+// them). Get reports ok false for missing keys. RenewKey and ReleaseKey only
+// act while key still holds value, and report whether they did.
+type KeyStore interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+	RenewKey(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	ReleaseKey(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// QueueStores are the jobs and everything the processes sharing them
+// coordinate through; main picks Redis or a MemoryQueue and hands them out.
+type QueueStores struct {
+	Jobs   JobQueue
+	Leases LeaseStore
+	Claims ClaimStore
+	Shards ShardStore
+	Keys   KeyStore
+}
+
+// Queue are the stores in use.
+var Queue = RedisQueue()
+
+// RedisQueue returns the stores shared through database.RDB.
+func RedisQueue() QueueStores {
+	q := redisQueue{}
+	return QueueStores{Jobs: q, Leases: q, Claims: q, Shards: q, Keys: q}
+}
+
+type redisQueue struct{}
+
+// defaultQueue is the queue the central workers pop from.
+const defaultQueue = "scan_jobs"
+
+// rateWindow is how far back queue throughput is measured for estimates.
+const rateWindow = 10 * time.Minute
+
+// QueueKey is the key prefix of zone's queue: the default queue for the
+// empty zone, a per-zone queue for agents otherwise.
+func QueueKey(zone string) string {
+	if zone == "" {
+		return defaultQueue
+	}
+	return defaultQueue + ":zone:" + zone
+}
+
+// normalizePriority maps "" to normal and rejects unknown priorities.
+func normalizePriority(p string) (string, error) {
+	if p == "" {
+		return models.PriorityNormal, nil
+	}
+	for _, known := range models.Priorities {
+		if p == known {
+			return p, nil
+		}
+	}
+	return "", ErrInvalidPriority
+}
+
+// pushScript adds a job to its scan's list, at the back or (ARGV[3] ==
+// "front") the front, and puts the scan in the ring if it was not waiting.
+var pushScript = redis.NewScript(`
+local front = ARGV[3] == "front"
+local n
+if front then
+	n = redis.call("LPUSH", KEYS[1], ARGV[2])
+else
+	n = redis.call("RPUSH", KEYS[1], ARGV[2])
+end
+if n == 1 then
+	if front then
+		redis.call("LPUSH", KEYS[2], ARGV[1])
+	else
+		redis.call("RPUSH", KEYS[2], ARGV[1])
+	end
+end
+redis.call("INCR", KEYS[3])
+redis.call("RPUSH", KEYS[4], 1)
+redis.call("LTRIM", KEYS[4], -1000, -1)
+return n`)
+
+// popScript takes up to ARGV[1] jobs, priorities in ARGV[3..] order, one job
+// per scan in ring order. Jobs left in the plain list of older releases are
+// taken first. ARGV[2] is the current Unix minute.
+var popScript = redis.NewScript(`
+local q = KEYS[1]
+local want = tonumber(ARGV[1])
+local out = {}
+while #out < want do
+	local legacy = redis.call("LPOP", q)
+	if not legacy then break end
+	table.insert(out, legacy)
+end
+local counted = -#out
+for i = 3, #ARGV do
+	local ring = q .. ":" .. ARGV[i] .. ":rr"
+	local tries = redis.call("LLEN", ring)
+	while #out < want and tries > 0 do
+		local sid = redis.call("LMOVE", ring, ring, "LEFT", "RIGHT")
+		local list = q .. ":" .. ARGV[i] .. ":scan:" .. sid
+		local job = redis.call("LPOP", list)
+		if job then
+			table.insert(out, job)
+		end
+		if redis.call("LLEN", list) == 0 then
+			redis.call("LREM", ring, -1, sid)
+			tries = tries - 1
+		end
+	end
+end
+if #out > 0 then
+	redis.call("DECRBY", q .. ":len", #out + counted)
+	local popped = q .. ":popped:" .. ARGV[2]
+	redis.call("INCRBY", popped, #out)
+	redis.call("EXPIRE", popped, 900)
+end
+if tonumber(redis.call("GET", q .. ":len") or "0") <= 0 then
+	-- Nothing waits: drop signals left by pushes that busy poppers took.
+	redis.call("DEL", q .. ":ready")
+end
+return out`)
+
+// positionScript returns {jobs ahead of the scan's next job, jobs ahead of
+// its last job, its waiting jobs} for scan ARGV[1] at priority ARGV[2], with
+// the priorities in ARGV[3..] order.
+var positionScript = redis.NewScript(`
+local q, sid, level = KEYS[1], ARGV[1], ARGV[2]
+local function waiting(lvl, id)
+	return redis.call("LLEN", q .. ":" .. lvl .. ":scan:" .. id)
+end
+local ahead = redis.call("LLEN", q)
+for i = 3, #ARGV do
+	if ARGV[i] == level then break end
+	for _, id in ipairs(redis.call("LRANGE", q .. ":" .. ARGV[i] .. ":rr", 0, -1)) do
+		ahead = ahead + waiting(ARGV[i], id)
+	end
+end
+local mine = waiting(level, sid)
+if mine == 0 then
+	return {0, 0, 0}
+end
+local first, last, before = ahead, ahead + mine - 1, true
+for _, id in ipairs(redis.call("LRANGE", q .. ":" .. level .. ":rr", 0, -1)) do
+	if id == sid then
+		before = false
+	else
+		local n = waiting(level, id)
+		if before then
+			first = first + math.min(n, 1)
+			last = last + math.min(n, mine)
+		else
+			last = last + math.min(n, mine - 1)
+		end
+	end
+end
+return {first, last, mine}`)
+
+func levelKeys(job models.ScanJob) (list, ring string) {
+	level := job.Priority
+	if level == "" {
+		level = models.PriorityNormal
+	}
+	q := QueueKey(job.Zone)
+	return q + ":" + level + ":scan:" + job.ScanID, q + ":" + level + ":rr"
+}
+
+func (redisQueue) Push(ctx context.Context, job models.ScanJob, front bool) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	where := "back"
+	if front {
+		where = "front"
+	}
+	list, ring := levelKeys(job)
+	q := QueueKey(job.Zone)
+	return pushScript.Run(ctx, database.RDB, []string{list, ring, q + ":len", q + ":ready"}, job.ScanID, jobJSON, where).Err()
+}
+
+func (redisQueue) Pop(ctx context.Context, zone string, max int) ([]models.ScanJob, error) {
+	args := []interface{}{max, utils.Now().Unix() / 60}
+	for _, p := range models.Priorities {
+		args = append(args, p)
+	}
+	vals, err := popScript.Run(ctx, database.RDB, []string{QueueKey(zone)}, args...).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	return decodeJobs(vals, "job"), nil
+}
+
+// Finish does nothing: a popped job has already left Redis.
+func (redisQueue) Finish(ctx context.Context, job models.ScanJob) error {
+	return nil
+}
+
+// decodeJobs unmarshals queued jobs, dropping (and logging) malformed ones.
+func decodeJobs(vals []string, what string) []models.ScanJob {
+	jobs := make([]models.ScanJob, 0, len(vals))
+	for _, v := range vals {
+		var job models.ScanJob
+		if err := json.Unmarshal([]byte(v), &job); err != nil {
+			log.Printf("Invalid %s format: %v", what, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func (redisQueue) Wait(ctx context.Context, zone string, timeout time.Duration) error {
+	err := database.RDB.BLPop(ctx, timeout, QueueKey(zone)+":ready").Err()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+func (redisQueue) Len(ctx context.Context, zone string) (int64, error) {
+	n, err := database.RDB.Get(ctx, QueueKey(zone)+":len").Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (redisQueue) Position(ctx context.Context, scanID, zone, priority string) (int, int, int, error) {
+	args := []interface{}{scanID, priority}
+	for _, p := range models.Priorities {
+		args = append(args, p)
+	}
+	vals, err := positionScript.Run(ctx, database.RDB, []string{QueueKey(zone)}, args...).Int64Slice()
+	if err != nil || len(vals) != 3 {
+		return 0, 0, 0, err
+	}
+	return int(vals[0]), int(vals[1]), int(vals[2]), nil
+}
+
+func (redisQueue) Started(ctx context.Context, zone string, window time.Duration) (int64, error) {
+	q := QueueKey(zone)
+	minute := utils.Now().Unix() / 60
+	var keys []string
+	for m := minute - int64(window/time.Minute); m < minute; m++ {
+		keys = append(keys, q+":popped:"+strconv.FormatInt(m, 10))
+	}
+	vals, err := database.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	var started int64
+	for _, v := range vals {
+		if s, ok := v.(string); ok {
+			n, _ := strconv.ParseInt(s, 10, 64)
+			started += n
+		}
+	}
+	return started, nil
+}
+
+// enqueue appends a job to its queue at its priority.
+func enqueue(ctx context.Context, job models.ScanJob) error {
+	return Queue.Jobs.Push(ctx, job, false)
+}
+
+// pushFront puts a job back at the head of its scan's list, and the scan at
+// the head of the ring if it had nothing else waiting.
+func pushFront(ctx context.Context, job models.ScanJob) error {
+	return Queue.Jobs.Push(ctx, job, true)
+}
+
+// popJobs takes up to max jobs from zone's queue without waiting. Jobs that
+// may no longer run now are rescheduled instead of returned, so it may
+// return fewer than it popped.
+func popJobs(ctx context.Context, zone string, max int) ([]models.ScanJob, error) {
+	popped, err := Queue.Jobs.Pop(ctx, zone, max)
+	if err != nil {
+		return nil, err
+	}
+	jobs := popped[:0]
+	for _, job := range popped {
+		if admit(ctx, job) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// finishJob finishes a popped job in the queue, logging a failure: at worst
+// the job runs again after a restart.
+func finishJob(ctx context.Context, job models.ScanJob) {
+	if err := Queue.Jobs.Finish(ctx, job); err != nil {
+		log.Printf("Failed to finish %s for scan %s: %v", job.Host, job.ScanID, err)
+	}
+}
+
+// waitJobs is popJobs that, when the queue is empty, waits up to timeout for
+// a job to be pushed. It may return no jobs even before the timeout when
+// another popper took the new job first.
+func waitJobs(ctx context.Context, zone string, max int, timeout time.Duration) ([]models.ScanJob, error) {
+	jobs, err := PopJobs(ctx, zone, max)
+	if err != nil || len(jobs) > 0 {
+		return jobs, err
+	}
+	if err := Queue.Jobs.Wait(ctx, zone, timeout); err != nil {
+		return nil, err
+	}
+	return PopJobs(ctx, zone, max)
+}
+
+// queueLength is the number of jobs waiting in zone's queue.
+func queueLength(ctx context.Context, zone string) (int64, error) {
+	return Queue.Jobs.Len(ctx, zone)
+}
+
+// queuePosition reports where a scan's waiting jobs stand in zone's queue.
+// Queued is 0 when none are waiting there.
+func queuePosition(ctx context.Context, scanID, zone, priority string) (models.QueuePosition, error) {
+	pos := models.QueuePosition{Zone: zone, Priority: priority}
+	if pos.Priority == "" {
+		pos.Priority = models.PriorityNormal
+	}
+	first, last, queued, err := Queue.Jobs.Position(ctx, scanID, zone, pos.Priority)
+	if err != nil || queued == 0 {
+		return pos, err
+	}
+	pos.Position, pos.LastPosition, pos.Queued = first, last, queued
+
+	started, err := Queue.Jobs.Started(ctx, zone, rateWindow)
+	rate := float64(started) / rateWindow.Seconds()
+	if err != nil || rate == 0 {
+		return pos, err
+	}
+	now := utils.Now()
+	start := now.Add(time.Duration(float64(pos.Position) / rate * float64(time.Second)))
+	end := now.Add(time.Duration(float64(pos.LastPosition) / rate * float64(time.Second)))
+	pos.EstimatedStart, pos.EstimatedLastStart = &start, &end
+	return pos, nil
+}
+
+// scanQueue reports the scan's position in every queue it has jobs waiting
+// in: the central workers' and those of the zones.
+func scanQueue(ctx context.Context, scan models.Scan) ([]models.QueuePosition, error) {
+	zones, err := database.ListZones()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{""}
+	for _, z := range zones {
+		names = append(names, z.Name)
+	}
+	positions := []models.QueuePosition{}
+	for _, zone := range names {
+		pos, err := QueuePosition(ctx, scan.ScanID, zone, scan.Priority)
+		if err != nil {
+			return nil, err
+		}
+		if pos.Queued > 0 {
+			positions = append(positions, pos)
+		}
+	}
+	return positions, nil
+}
+
+// renewKeyScript extends key's TTL to ARGV[2] ms while it holds ARGV[1].
+var renewKeyScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseKeyScript deletes key while it holds ARGV[1].
+var releaseKeyScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+func (redisQueue) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return database.RDB.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (redisQueue) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := database.RDB.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return v, err == nil, err
+}
+
+func (redisQueue) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return database.RDB.Set(ctx, key, value, ttl).Err()
+}
+
+func (redisQueue) Del(ctx context.Context, key string) error {
+	return database.RDB.Del(ctx, key).Err()
+}
+
+func (redisQueue) RenewKey(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	n, err := renewKeyScript.Run(ctx, database.RDB, []string{key}, value, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (redisQueue) ReleaseKey(ctx context.Context, key string, value []byte) (bool, error) {
+	n, err := releaseKeyScript.Run(ctx, database.RDB, []string{key}, value).Int()
+	return n == 1, err
+}
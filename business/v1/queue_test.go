@@ -0,0 +1,123 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/models/v1"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+
+	database "nmap-rest-api/database"
+)
+
+// expectPush expects the push script for job, as Enqueue ("back") or
+// PushFront ("front") run it.
+func expectPush(mock redismock.ClientMock, job models.ScanJob, where string) *redismock.ExpectedCmd {
+	q := business.QueueKey(job.Zone)
+	level := job.Priority
+	if level == "" {
+		level = "normal"
+	}
+	jobJSON, _ := json.Marshal(job)
+	keys := []string{q + ":" + level + ":scan:" + job.ScanID, q + ":" + level + ":rr", q + ":len", q + ":ready"}
+	return mock.ExpectEvalSha(business.PushScriptHash, keys, job.ScanID, jobJSON, where)
+}
+
+func TestPopJobs_ServesPrioritiesInOrder(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+
+	job := models.ScanJob{ScanID: "s1", Host: "web-1", Priority: "high"}
+	jobJSON, _ := json.Marshal(job)
+	mockRedis.ExpectEvalSha(business.PopScriptHash, []string{"scan_jobs"},
+		3, enqueuedAt.Unix()/60, "high", "normal", "low").SetVal([]interface{}{string(jobJSON), "not json"})
+
+	jobs, err := business.PopJobs(context.Background(), "", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ScanJob{job}, jobs, "malformed jobs are dropped")
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestWaitJobs_WaitsForReadySignal(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+
+	job := models.ScanJob{ScanID: "s1", Host: "web-1", Zone: "dmz"}
+	jobJSON, _ := json.Marshal(job)
+	popArgs := []interface{}{1, enqueuedAt.Unix() / 60, "high", "normal", "low"}
+	mockRedis.ExpectEvalSha(business.PopScriptHash, []string{"scan_jobs:zone:dmz"}, popArgs...).SetVal([]interface{}{})
+	mockRedis.ExpectBLPop(2*time.Second, "scan_jobs:zone:dmz:ready").SetVal([]string{"scan_jobs:zone:dmz:ready", "1"})
+	mockRedis.ExpectEvalSha(business.PopScriptHash, []string{"scan_jobs:zone:dmz"}, popArgs...).SetVal([]interface{}{string(jobJSON)})
+
+	jobs, err := business.WaitJobs(context.Background(), "dmz", 1, 2*time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ScanJob{job}, jobs)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueuePosition_EstimatesFromRecentThroughput(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+
+	mockRedis.ExpectEvalSha(business.PositionScriptHash, []string{"scan_jobs"},
+		"s1", "low", "high", "normal", "low").SetVal([]interface{}{int64(120), int64(600), int64(5)})
+	minute := enqueuedAt.Unix() / 60
+	var keys []string
+	for m := minute - 10; m < minute; m++ {
+		keys = append(keys, "scan_jobs:popped:"+strconv.FormatInt(m, 10))
+	}
+	// 1,200 jobs started in ten minutes: two per second.
+	vals := make([]interface{}, 10)
+	vals[0], vals[9] = "700", "500"
+	mockRedis.ExpectMGet(keys...).SetVal(vals)
+
+	pos, err := business.QueuePosition(context.Background(), "s1", "", "low")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, pos.Queued)
+	assert.Equal(t, 120, pos.Position)
+	assert.Equal(t, 600, pos.LastPosition)
+	assert.Equal(t, enqueuedAt.Add(time.Minute), *pos.EstimatedStart)
+	assert.Equal(t, enqueuedAt.Add(5*time.Minute), *pos.EstimatedLastStart)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueuePosition_NotQueued(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	mockRedis.ExpectEvalSha(business.PositionScriptHash, []string{"scan_jobs:zone:dmz"},
+		"s1", "normal", "high", "normal", "low").SetVal([]interface{}{int64(0), int64(0), int64(0)})
+
+	pos, err := business.QueuePosition(context.Background(), "s1", "dmz", "")
+
+	assert.NoError(t, err)
+	assert.Zero(t, pos.Queued)
+	assert.Nil(t, pos.EstimatedStart)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestRedisQueue_RenewsAndReleasesOnlyOwnKeys(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	ctx := context.Background()
+	q := business.RedisQueue().Keys
+
+	mockRedis.ExpectEvalSha(business.RenewKeyScriptHash, []string{"lock"}, []byte("me"), int64(30000)).SetVal(int64(1))
+	mockRedis.ExpectEvalSha(business.ReleaseKeyScriptHash, []string{"lock"}, []byte("other")).SetVal(int64(0))
+
+	renewed, err := q.RenewKey(ctx, "lock", []byte("me"), 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, renewed)
+	released, err := q.ReleaseKey(ctx, "lock", []byte("other"))
+	assert.NoError(t, err)
+	assert.False(t, released)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/telemetry"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	UpdateScanStatus    = updateScanStatus
+	PersistResult       = persistResult
+	ProcessStoredResult = processStoredResult
+)
+
+const storeAttempts = 3
+
+// updateScanStatus records a host's status transition under its own span so
+// it shows up in the trace of the scan it belongs to.
+func updateScanStatus(ctx context.Context, scanID, host, status string) error {
+	_, span := tracer.Start(ctx, "db.set_status", trace.WithAttributes(
+		telemetry.AttrScanID.String(scanID),
+		telemetry.AttrHost.String(host),
+		telemetry.AttrStatus.String(status),
+	))
+	defer span.End()
+	err := Repos.Statuses.SetScanStatus(scanID, host, status)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// persistResult is the single path every finished result takes into the
+// database, whether it came from a worker or an import: store it and mark the
+// host done in one transaction (retrying transient errors) or mark it failed,
+// then run the post-store pipeline. A merged shard result with Incomplete
+// ranges is stored as partial and skips the pipeline.
+func persistResult(ctx context.Context, res models.ScanResult) error {
+	status, reason := "done", ""
+	if len(res.Incomplete) > 0 {
+		status = "partial"
+		reason = "no results for ports " + strings.Join(res.Incomplete, ",") + ": their shards failed"
+	}
+	var err error
+	for attempt := 1; attempt <= storeAttempts; attempt++ {
+		_, span := tracer.Start(ctx, "db.store_result", trace.WithAttributes(
+			telemetry.AttrScanID.String(res.ScanID),
+			telemetry.AttrHost.String(res.Host),
+			telemetry.AttrStatus.String(status),
+		))
+		start := time.Now()
+		err = storeResult(res, status, reason)
+		telemetry.DBStoreDuration.Record(ctx, time.Since(start).Seconds())
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		if err == nil || attempt == storeAttempts {
+			break
+		}
+		telemetry.ScanRetries.Add(ctx, 1, metric.WithAttributes(telemetry.LabelStage.String("db_store")))
+		log.Printf("Retrying DB store (%d/%d) for %s", attempt, storeAttempts, res.Host)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	if err != nil {
+		UpdateScanStatus(ctx, res.ScanID, res.Host, "failed")
+		return err
+	}
+	for _, p := range res.Ports {
+		telemetry.OpenPortsFound.Add(ctx, 1, metric.WithAttributes(telemetry.LabelProtocol.String(p.Protocol)))
+	}
+	// Missing ranges would read as closed ports, so a partial result must not
+	// replace the inventory's view or raise changes.
+	if status == "done" {
+		ProcessStoredResult(ctx, res)
+	}
+	return nil
+}
+
+// processStoredResult runs the follow-up work once a result is persisted:
+// refresh the host inventory, evaluate the host's baselines, then the alert
+// rules. Results older than what the inventory already holds (imported
+// history) only feed the inventory; they must not raise violations or alerts
+// for a state the host has since left. Failures are logged; the result itself
+// is already safely stored.
+func processStoredResult(ctx context.Context, res models.ScanResult) {
+	backfill := false
+	if h, err := Repos.Hosts.GetHost(res.Host); err == nil && h.LastScanned.After(res.ScannedAt) {
+		backfill = true
+	}
+	if err := UpdateInventory(res); err != nil {
+		log.Printf("Failed to update host inventory for %s: %v", res.Host, err)
+	}
+	if backfill {
+		return
+	}
+	violations, err := EvaluateBaselines(res)
+	if err != nil {
+		log.Printf("Failed to evaluate baselines for %s: %v", res.Host, err)
+	}
+	if _, err := EvaluateAlerts(ctx, res, violations); err != nil {
+		log.Printf("Failed to evaluate alert rules for %s: %v", res.Host, err)
+	}
+}
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+var PruneResults = pruneResults
+
+// Retention decides how long results keep full detail and whether unchanged
+// results are collapsed; main sets it from the configuration.
+var Retention = config.Default().Retention
+
+// retentionHostPage is how many hosts one pruning pass loads at a time.
+const retentionHostPage = 500
+
+// pruneBatchPause separates a host's delete batches so other writers get the
+// table in between.
+const pruneBatchPause = 50 * time.Millisecond
+
+// pruneResults applies Retention to every host with results: past its
+// owner's full detail period a host keeps only the results that differ from
+// the one before, the first of each month and those that collapsed later
+// ones into their seen-again times. Deletes run in batches of
+// Retention.PruneBatch, each its own statement. A dry run deletes nothing
+// and reports what would go.
+func pruneResults(ctx context.Context, dryRun bool) (models.RetentionReport, error) {
+	report := models.RetentionReport{DryRun: dryRun, Owners: []models.OwnerRetention{}}
+	byOwner := map[string]*models.OwnerRetention{}
+	now := utils.Now()
+	for after := ""; ; {
+		hosts, err := Repos.Results.ResultHosts(after, retentionHostPage)
+		if err != nil {
+			return report, err
+		}
+		for _, h := range hosts {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			keep := Retention.FullDetailFor(h.Owner)
+			if keep <= 0 {
+				continue
+			}
+			n, err := pruneHost(ctx, h.Host, now.Add(-keep), dryRun)
+			report.Results += n
+			if n > 0 {
+				o, ok := byOwner[h.Owner]
+				if !ok {
+					o = &models.OwnerRetention{Owner: h.Owner, FullDetail: keep.String()}
+					byOwner[h.Owner] = o
+				}
+				o.Hosts++
+				o.Results += n
+			}
+			if err != nil {
+				return report, err
+			}
+		}
+		if len(hosts) < retentionHostPage {
+			break
+		}
+		after = hosts[len(hosts)-1].Host
+	}
+	for _, o := range byOwner {
+		report.Owners = append(report.Owners, *o)
+	}
+	sort.Slice(report.Owners, func(i, j int) bool { return report.Owners[i].Owner < report.Owners[j].Owner })
+	if !dryRun && report.Results > 0 {
+		log.Printf("Pruned %d scan result(s) past their retention", report.Results)
+	}
+	return report, nil
+}
+
+// pruneHost deletes host's redundant results before cutoff batch by batch
+// until a batch comes back short, or counts them on a dry run.
+func pruneHost(ctx context.Context, host string, cutoff time.Time, dryRun bool) (int64, error) {
+	var total int64
+	for {
+		n, err := Repos.Results.PruneResults(host, cutoff, Retention.PruneBatch, dryRun)
+		total += n
+		if err != nil || dryRun || n < int64(Retention.PruneBatch) {
+			return total, err
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(pruneBatchPause):
+		}
+	}
+}
+
+// storeResult stores res and finishes its host. With
+// Retention.CollapseUnchanged a complete result that found the same as the
+// host's latest one is recorded on that one as seen again instead.
+func storeResult(res models.ScanResult, status, reason string) error {
+	if status == "done" && Retention.CollapseUnchanged {
+		collapsed, err := Repos.Results.CollapseResult(res)
+		if err != nil || collapsed {
+			return err
+		}
+	}
+	return Repos.Results.StoreResult(res, status, reason)
+}
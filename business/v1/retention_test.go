@@ -0,0 +1,136 @@
+package v1_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withRetention(t *testing.T, r config.Retention) {
+	t.Helper()
+	saved := business.Retention
+	t.Cleanup(func() { business.Retention = saved })
+	business.Retention = r
+}
+
+// resultDays lists host's stored results as "MM-DD", oldest first.
+func resultDays(t *testing.T, repos database.Repositories, host string) []string {
+	t.Helper()
+	var days []string
+	require.NoError(t, repos.Results.StreamResults(models.ResultFilter{Host: host}, func(r models.ScanResult) error {
+		days = append([]string{r.ScannedAt.Format("01-02")}, days...)
+		return nil
+	}))
+	return days
+}
+
+func TestPruneResults_KeepsChangesAndMonthlySnapshots(t *testing.T) {
+	ctx := context.Background()
+	repos := withMemory(t)
+	withRetention(t, config.Retention{
+		ResultsFullDetail:        config.Duration(90 * 24 * time.Hour), // before 02-09
+		ResultsFullDetailByOwner: config.OwnerDurations{"legal": 0},
+		PruneBatch:               2,
+	})
+	seedHost(t, repos, "vault", nil)
+	legal := "legal"
+	require.NoError(t, repos.Hosts.UpdateHostMeta("vault", models.HostUpdate{Owner: &legal}))
+	for _, host := range []string{"web-1", "vault"} {
+		for _, r := range []struct {
+			day   string
+			ports []int
+		}{
+			{"01-10", []int{22}}, {"01-11", []int{22}}, {"01-12", []int{22, 80}}, {"01-13", []int{22, 80}},
+			{"02-01", []int{22, 80}}, {"02-02", []int{22, 80}}, {"02-03", []int{22}},
+			{"02-20", []int{22}}, {"02-21", []int{22}},
+		} {
+			at, err := time.Parse("2006-01-02", "2024-"+r.day)
+			require.NoError(t, err)
+			require.NoError(t, repos.Results.StoreResult(models.ScanResult{ScanID: "s-" + r.day, Host: host, ScannedAt: at, OpenPorts: r.ports}, "done", ""))
+		}
+	}
+	want := models.RetentionReport{DryRun: true, Results: 3, Owners: []models.OwnerRetention{
+		{Owner: "", FullDetail: "2160h0m0s", Hosts: 1, Results: 3},
+	}}
+
+	report, err := business.PruneResults(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, want, report)
+	assert.Len(t, resultDays(t, repos, "web-1"), 9, "a dry run deletes nothing")
+
+	report, err = business.PruneResults(ctx, false)
+	require.NoError(t, err)
+	want.DryRun = false
+	assert.Equal(t, want, report)
+	assert.Equal(t, []string{"01-10", "01-12", "02-01", "02-03", "02-20", "02-21"}, resultDays(t, repos, "web-1"))
+	assert.Len(t, resultDays(t, repos, "vault"), 9, "the owner's override keeps everything")
+
+	report, err = business.PruneResults(ctx, false)
+	require.NoError(t, err)
+	assert.Zero(t, report.Results, "a second pass finds nothing left to delete")
+}
+
+func TestPersistResult_CollapsesUnchangedResults(t *testing.T) {
+	ctx := context.Background()
+	repos := withMemory(t)
+	withRetention(t, config.Retention{CollapseUnchanged: true})
+	orig := business.ProcessStoredResult
+	t.Cleanup(func() { business.ProcessStoredResult = orig })
+	var processed []string
+	business.ProcessStoredResult = func(ctx context.Context, res models.ScanResult) { processed = append(processed, res.ScanID) }
+	ports := []models.PortInfo{{Port: 22, Protocol: "tcp", State: "open", Service: "ssh"}}
+	scan := func(scanID string, minutes int, ports []models.PortInfo) {
+		t.Helper()
+		require.NoError(t, business.PersistResult(ctx, models.ScanResult{
+			ScanID: scanID, Host: "web-1", HostState: "up", ScannedAt: enqueuedAt.Add(time.Duration(minutes) * time.Minute),
+			Ports: ports, OpenPorts: []int{22},
+		}))
+	}
+
+	scan("s1", 0, ports)
+	scan("s2", 10, ports)
+	scan("s3", 20, append(ports, models.PortInfo{Port: 22, Protocol: "udp", State: "open"}))
+
+	var stored []models.ScanResult
+	require.NoError(t, repos.Results.StreamResults(models.ResultFilter{Host: "web-1"}, func(r models.ScanResult) error {
+		stored = append(stored, r)
+		return nil
+	}))
+	require.Len(t, stored, 2)
+	assert.Equal(t, "s3", stored[0].ScanID, "a changed service is stored")
+	assert.Equal(t, "s1", stored[1].ScanID)
+	assert.Equal(t, []time.Time{enqueuedAt.Add(10 * time.Minute)}, stored[1].SeenAgain)
+	sts, err := repos.Statuses.GetScanStatuses("s2")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"host": "web-1", "status": "done", "reason": "unchanged since scan s1; recorded there as seen again"}}, sts)
+	assert.Equal(t, []string{"s1", "s2", "s3"}, processed, "the inventory still sees every scan")
+}
+
+func TestPruneResults_KeepsCollapsedResults(t *testing.T) {
+	repos := withMemory(t)
+	withRetention(t, config.Retention{ResultsFullDetail: config.Duration(24 * time.Hour), PruneBatch: 10})
+	for _, day := range []string{"01-10", "01-11", "01-12"} {
+		at, err := time.Parse("2006-01-02", "2024-"+day)
+		require.NoError(t, err)
+		require.NoError(t, repos.Results.StoreResult(models.ScanResult{ScanID: "s-" + day, Host: "web-1", ScannedAt: at, OpenPorts: []int{22}}, "done", ""))
+	}
+	// A later scan is recorded on the latest result as seen again.
+	at, err := time.Parse("2006-01-02", "2024-01-13")
+	require.NoError(t, err)
+	collapsed, err := repos.Results.CollapseResult(models.ScanResult{ScanID: "s-01-13", Host: "web-1", ScannedAt: at, OpenPorts: []int{22}})
+	require.NoError(t, err)
+	require.True(t, collapsed)
+
+	report, err := business.PruneResults(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.Results)
+	assert.Equal(t, []string{"01-10", "01-12"}, resultDays(t, repos, "web-1"), "the result holding seen-again times stays")
+}
@@ -2,127 +2,210 @@ package v1
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"log"
-	"strconv"
-	"strings"
 
 	database "nmap-rest-api/database"
 	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/telemetry"
 	"nmap-rest-api/utils"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var tracer = otel.Tracer("nmap-api")
 
-var QueueScan = queueScan
+// Repos are the stores business logic persists scans, statuses, results and
+// hosts through; main sets them for the configured database driver.
+var Repos = database.Postgres()
+
+// ErrNeedsPostgres is returned when creating groups, zones, agents, alerts or
+// baselines while the database driver is memory.
+var ErrNeedsPostgres = database.ErrNeedsPostgres
+
+var (
+	QueueScan  = queueScan
+	RequeueJob = requeueJob
+
+	ErrNoTargets = errors.New("scan has no targets")
+)
+
+// queueScan expands the requested groups, records the scan and pushes one job
+// per target host, at the requested priority, to the queue of the zone the
+// host lies in. Jobs that may not run yet wait in the delayed set; jobs that
+// never may are skipped. A sharded port range gives each host one job per
+// shard. With req.Coalesce, hosts that already have an identical pending or
+// running job attach to it instead. An error after the first host was
+// queued, attached or skipped comes with the scan ID: the scan happened in
+// part and must not simply be retried.
+func queueScan(ctx context.Context, req models.ScanRequest) (string, error) {
+	priority, err := normalizePriority(req.Priority)
+	if err != nil {
+		return "", err
+	}
+	if err := validateSchedule(req); err != nil {
+		return "", err
+	}
+	spec, shards, err := validatePorts(req)
+	if err != nil {
+		return "", err
+	}
+	hosts, expansion, err := expandTargets(req)
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", ErrNoTargets
+	}
+
+	zones, err := newZoneRouter()
+	if err != nil {
+		return "", err
+	}
+	groups, err := database.ListGroups()
+	if err != nil {
+		return "", err
+	}
 
-func queueScan(ctx context.Context, hosts []string) (string, error) {
 	scanID := utils.GenerateScanID()
+	err = Repos.Scans.CreateScan(models.Scan{
+		ScanID:         scanID,
+		RequestedHosts: req.Hosts,
+		Groups:         expansion,
+		Hosts:          hosts,
+		Priority:       priority,
+		NotBefore:      req.NotBefore,
+		NotAfter:       req.NotAfter,
+		Ports:          spec,
+		ShardSize:      req.ShardSize,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	started := false
+	partial := func(err error) (string, error) {
+		if started {
+			return scanID, err
+		}
+		return "", err
+	}
 	for _, host := range hosts {
-		// setting database status as pending
-		err := database.SetScanStatus(scanID, host, "pending")
+		zone, err := zones.zoneOf(host)
 		if err != nil {
-			return "", err
+			return partial(err)
 		}
-
-		// creating a job model
-		job := models.ScanJob{ScanID: scanID, Host: host}
-		jobJSON, err := json.Marshal(job)
-		if err != nil {
-			log.Println("error receieved while marshaling")
-			return "", err
+		if req.Coalesce {
+			owner, ok, err := Repos.Statuses.FindActiveJob(host, req.NotBefore, req.NotAfter, spec)
+			if err != nil {
+				return partial(err)
+			}
+			if ok {
+				log.Printf("Scan %s: %s attached to the job of scan %s", scanID, host, owner)
+				if err := Repos.Statuses.AttachScanHost(scanID, host, owner); err != nil {
+					return partial(err)
+				}
+				started = true
+				continue
+			}
 		}
 
-		// creating the tracer
-		ctxTracer, span := tracer.Start(ctx, "queue.redis.push")
-		defer span.End()
+		job := models.ScanJob{
+			ScanID:    scanID,
+			Host:      host,
+			Zone:      zone,
+			Priority:  priority,
+			NotBefore: req.NotBefore,
+			NotAfter:  req.NotAfter,
+			Windows:   windowsFor(host, groups),
+		}
 
-		// pushing it to redis
-		errRedis := database.RDB.RPush(ctxTracer, "scan_jobs", jobJSON).Err()
-		if errRedis != nil {
-			log.Println("error generated while storing scan_jobs to redis")
-			// TODO: redis store not getting the value then worker can't pick it
+		// setting database status as pending, scheduled or skipped
+		status, at, reason := placement(job)
+		if reason != "" {
+			if err := skip(job, reason); err != nil {
+				return partial(err)
+			}
+			started = true
+			continue
+		}
+		if err := UpdateScanStatus(ctx, scanID, host, status); err != nil {
+			return partial(err)
+		}
+		started = true
+
+		for _, job := range shardJobs(job, shards) {
+			// creating the tracer
+			ctxTracer, span := tracer.Start(ctx, "queue.redis.push",
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(telemetry.AttrScanID.String(scanID), telemetry.AttrHost.String(host)))
+
+			// the job carries the push span's trace context
+			job.TraceContext = telemetry.Inject(ctxTracer)
+			job.EnqueuedAt = utils.Now().UnixNano()
+
+			// pushing it to redis
+			errRedis := pushJob(ctxTracer, job, at)
+			if errRedis != nil {
+				log.Println("error generated while storing scan_jobs to redis")
+				span.RecordError(errRedis)
+				span.SetStatus(codes.Error, errRedis.Error())
+				// TODO: redis store not getting the value then worker can't pick it
+			}
+			span.End()
 		}
 	}
 	return scanID, nil
 }
 
-func FetchScanHistoryFiltered(host string, scanID string) []models.ScanResult {
-	var (
-		rows *sql.Rows
-		err  error
-	)
-
-	if scanID != "" {
-		rows, err = database.DB.Query(`
-			SELECT scan_id, host, scanned_at, open_ports
-			FROM scan_results
-			WHERE host = $1 AND scan_id = $2
-			ORDER BY scanned_at DESC
-		`, host, scanID)
-	} else {
-		rows, err = database.DB.Query(`
-			SELECT scan_id, host, scanned_at, open_ports
-			FROM scan_results
-			WHERE host = $1
-			ORDER BY scanned_at DESC
-			LIMIT 10
-		`, host)
+// requeueJob hands a job that was taken but not finished back to the queue.
+// It goes to the head of its scan's jobs so it runs before newer work,
+// keeping its trace context, original enqueue time, zone and priority, and
+// its status returns to pending.
+func requeueJob(ctx context.Context, job models.ScanJob) error {
+	if err := UpdateScanStatus(ctx, job.ScanID, job.Host, "pending"); err != nil {
+		return err
 	}
+	return PushFront(ctx, job)
+}
 
+// FetchScanHistoryFiltered returns the host's results in one scan, or its 10
+// most recent, newest first.
+func FetchScanHistoryFiltered(host string, scanID string) []models.ScanResult {
+	f := models.ResultFilter{Host: host, ScanID: scanID}
+	if scanID == "" {
+		f.Limit = 10
+	}
+	var results []models.ScanResult
+	err := Repos.Results.StreamResults(f, func(res models.ScanResult) error {
+		results = append(results, res)
+		return nil
+	})
 	if err != nil {
 		log.Printf("DB query failed: %v", err)
 		return nil
 	}
-	defer rows.Close()
-
-	var results []models.ScanResult
-	for rows.Next() {
-		var res models.ScanResult
-		var portsRaw string
-		if err := rows.Scan(&res.ScanID, &res.Host, &res.ScannedAt, &portsRaw); err == nil {
-			portsStr := strings.Trim(portsRaw, "{}")
-			for _, p := range strings.Split(portsStr, ",") {
-				if port, err := strconv.Atoi(p); err == nil {
-					res.OpenPorts = append(res.OpenPorts, port)
-				}
-			}
-			results = append(results, res)
+	if scanID != "" {
+		// A scan's results stream oldest first.
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
 		}
 	}
 	return results
 }
 
 func ComputeDiff(host string) models.PortDiff {
-	rows, err := database.DB.Query(`
-		SELECT open_ports
-		FROM scan_results
-		WHERE host = $1
-		ORDER BY scanned_at DESC
-		LIMIT 2
-	`, host)
+	var results [][]int
+	err := Repos.Results.StreamResults(models.ResultFilter{Host: host, Limit: 2}, func(res models.ScanResult) error {
+		results = append(results, res.OpenPorts)
+		return nil
+	})
 	if err != nil {
 		log.Printf("DB error: %v", err)
 		return models.PortDiff{Host: host}
 	}
-	defer rows.Close()
-
-	var results [][]int
-	for rows.Next() {
-		var portsRaw string
-		if err := rows.Scan(&portsRaw); err == nil {
-			var parsed []int
-			for _, p := range strings.Split(strings.Trim(portsRaw, "{}"), ",") {
-				if port, err := strconv.Atoi(p); err == nil {
-					parsed = append(parsed, port)
-				}
-			}
-			results = append(results, parsed)
-		}
-	}
 
 	if len(results) < 2 {
 		return models.PortDiff{Host: host}
@@ -2,11 +2,13 @@ package v1_test
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	business "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
 	database "nmap-rest-api/database"
 	"nmap-rest-api/models/v1"
 	utils "nmap-rest-api/utils"
@@ -15,8 +17,83 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var enqueuedAt = time.Date(2024, 5, 9, 7, 0, 0, 0, time.UTC)
+
+func init() {
+	utils.Now = func() time.Time { return enqueuedAt }
+	database.ListZones = func() ([]models.Zone, error) { return nil, nil }
+	database.ListGroups = func() ([]models.AssetGroup, error) { return nil, nil }
+	business.Limits = config.Limits{}
+}
+
+// withMemory points business logic at fresh in-memory repositories.
+func withMemory(t *testing.T) database.Repositories {
+	t.Helper()
+	saved := business.Repos
+	t.Cleanup(func() { business.Repos = saved })
+	business.Repos = database.NewMemory()
+	return business.Repos
+}
+
+// seedHost adds host to the inventory as scanned at enqueuedAt.
+func seedHost(t *testing.T, repos database.Repositories, host string, tags []string, ips ...string) {
+	t.Helper()
+	assert.NoError(t, repos.Hosts.UpsertHost(models.ScanResult{ScanID: "seed", Host: host, ScannedAt: enqueuedAt, HostState: "up", Addresses: ips}))
+	if tags != nil {
+		assert.NoError(t, repos.Hosts.UpdateHostMeta(host, models.HostUpdate{Tags: &tags}))
+	}
+}
+
+// statuses lists a scan's hosts as "host=status".
+func statuses(t *testing.T, repos database.Repositories, scanID string) []string {
+	t.Helper()
+	sts, err := repos.Statuses.GetScanStatuses(scanID)
+	assert.NoError(t, err)
+	var out []string
+	for _, st := range sts {
+		out = append(out, st["host"]+"="+st["status"])
+	}
+	return out
+}
+
+// failingStatuses fails every status change.
+type failingStatuses struct{ database.StatusRepository }
+
+func (failingStatuses) SetScanStatus(scanID, host, status string) error {
+	return errors.New("mock DB error")
+}
+
+// failingHost fails the status changes of one host.
+type failingHost struct {
+	database.StatusRepository
+	host string
+}
+
+func (f failingHost) SetScanStatus(scanID, host, status string) error {
+	if host == f.host {
+		return errors.New("mock DB error")
+	}
+	return f.StatusRepository.SetScanStatus(scanID, host, status)
+}
+
+func TestQueueScan_ErrorAfterAHostWasQueuedKeepsTheID(t *testing.T) {
+	ctx := context.Background()
+	repos := withMemory(t)
+	withMemoryQueue(t, "")
+	business.Repos.Statuses = failingHost{repos.Statuses, "host2"}
+	utils.GenerateScanID = func() string { return "partial-id" }
+
+	scanID, err := business.QueueScan(ctx, models.ScanRequest{Hosts: []string{"host1", "host2"}})
+
+	assert.Error(t, err)
+	assert.Equal(t, "partial-id", scanID, "host1 is queued, so the scan must not be retried as new")
+	n, _ := business.QueueLength(ctx, "")
+	assert.Equal(t, int64(1), n)
+}
+
 func TestQueueScan_Success(t *testing.T) {
 	ctx := context.Background()
+	repos := withMemory(t)
 
 	// Step 1: Match scan ID exactly
 	utils.GenerateScanID = func() string {
@@ -27,40 +104,33 @@ func TestQueueScan_Success(t *testing.T) {
 	rdb, mockRedis := redismock.NewClientMock()
 	database.RDB = rdb
 
-	// Step 3: Mock DB status setter
-	database.SetScanStatus = func(scanID, host, status string) error {
-		return nil
-	}
-
-	// Step 4: Add expected job payloads
+	// Step 3: Add expected job payloads
 	for _, host := range []string{"host1", "host2"} {
-		job := models.ScanJob{ScanID: "mock-scan-id", Host: host}
-		jobJSON, _ := json.Marshal(job)
-		mockRedis.ExpectRPush("scan_jobs", jobJSON).SetVal(1)
+		job := models.ScanJob{ScanID: "mock-scan-id", Host: host, EnqueuedAt: enqueuedAt.UnixNano(), Priority: "normal"}
+		expectPush(mockRedis, job, "back").SetVal(int64(1))
 	}
 
-	// Step 5: Call the function
-	scanID, err := business.QueueScan(ctx, []string{"host1", "host2"})
+	// Step 4: Call the function
+	scanID, err := business.QueueScan(ctx, models.ScanRequest{Hosts: []string{"host1", "host2"}})
 
-	// Step 6: Assert
+	// Step 5: Assert
 	assert.NoError(t, err)
 	assert.Equal(t, "mock-scan-id", scanID)
+	assert.Equal(t, []string{"host1=pending", "host2=pending"}, statuses(t, repos, scanID))
 	assert.NoError(t, mockRedis.ExpectationsWereMet())
 }
 
 func TestQueueScan_DBError(t *testing.T) {
 	ctx := context.Background()
+	repos := withMemory(t)
+	// Fail on purpose
+	business.Repos.Statuses = failingStatuses{repos.Statuses}
 
 	utils.GenerateScanID = func() string {
 		return "bad-id"
 	}
 
-	database.SetScanStatus = func(scanID, host, status string) error {
-		// Fail on purpose
-		return errors.New("mock DB error")
-	}
-
-	scanID, err := business.QueueScan(ctx, []string{"failhost"})
+	scanID, err := business.QueueScan(ctx, models.ScanRequest{Hosts: []string{"failhost"}})
 
 	assert.Error(t, err)
 	assert.Equal(t, "", scanID)
@@ -68,28 +138,142 @@ func TestQueueScan_DBError(t *testing.T) {
 
 func TestQueueScan_RedisError_StillReturnsID(t *testing.T) {
 	ctx := context.Background()
+	withMemory(t)
 
 	utils.GenerateScanID = func() string {
 		return "redis-fail-id"
 	}
 
-	database.SetScanStatus = func(scanID, host, status string) error {
-		return nil
-	}
-
 	// Mock Redis with forced RPush error
 	rdb, mockRedis := redismock.NewClientMock()
 	database.RDB = rdb
 
-	job := models.ScanJob{ScanID: "redis-fail-id", Host: "hostX"}
-	jobJSON, _ := json.Marshal(job)
+	job := models.ScanJob{ScanID: "redis-fail-id", Host: "hostX", EnqueuedAt: enqueuedAt.UnixNano(), Priority: "normal"}
 
 	// Simulate Redis error but continue anyway
-	mockRedis.ExpectRPush("scan_jobs", jobJSON).SetErr(errors.New("redis down"))
+	expectPush(mockRedis, job, "back").SetErr(errors.New("redis down"))
 
-	scanID, err := business.QueueScan(ctx, []string{"hostX"})
+	scanID, err := business.QueueScan(ctx, models.ScanRequest{Hosts: []string{"hostX"}})
 
 	assert.NoError(t, err) // still no error returned
 	assert.Equal(t, "redis-fail-id", scanID)
 	assert.NoError(t, mockRedis.ExpectationsWereMet())
 }
+
+func TestQueueScan_ExpandsGroupsAndRecordsScan(t *testing.T) {
+	ctx := context.Background()
+
+	utils.GenerateScanID = func() string {
+		return "group-scan-id"
+	}
+	database.GetGroup = func(name string) (models.AssetGroup, error) {
+		return models.AssetGroup{
+			Name:  name,
+			Hosts: []string{"host1"},
+			Match: &models.GroupMatch{Tags: []string{"web"}, CIDRs: []string{"10.0.0.0/24"}},
+		}, nil
+	}
+	repos := withMemory(t)
+	seedHost(t, repos, "web-1", []string{"prod", "web"}, "10.0.0.5")
+	seedHost(t, repos, "web-2", []string{"web"}, "192.168.1.5")
+	seedHost(t, repos, "10.0.0.9", []string{"web"})
+
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	for _, host := range []string{"host1", "10.0.0.9", "web-1"} {
+		job := models.ScanJob{ScanID: "group-scan-id", Host: host, EnqueuedAt: enqueuedAt.UnixNano(), Priority: "normal"}
+		expectPush(mockRedis, job, "back").SetVal(int64(1))
+	}
+
+	scanID, err := business.QueueScan(ctx, models.ScanRequest{Hosts: []string{"host1"}, Groups: []string{"web"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "group-scan-id", scanID)
+	recorded, err := repos.Scans.GetScan(scanID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host1"}, recorded.RequestedHosts)
+	assert.Equal(t, map[string][]string{"web": {"10.0.0.9", "host1", "web-1"}}, recorded.Groups)
+	assert.Equal(t, []string{"host1", "10.0.0.9", "web-1"}, recorded.Hosts)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueueScan_RoutesTargetsToZones(t *testing.T) {
+	database.ListZones = func() ([]models.Zone, error) {
+		return []models.Zone{{Name: "dmz", CIDRs: []string{"10.1.0.0/16"}}}, nil
+	}
+	defer func() { database.ListZones = func() ([]models.Zone, error) { return nil, nil } }()
+	repos := withMemory(t)
+	seedHost(t, repos, "web-1", nil, "10.1.2.3")
+	utils.GenerateScanID = func() string { return "zone-scan-id" }
+
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	for _, tc := range []struct{ host, zone string }{
+		{"10.1.0.7", "dmz"},
+		{"10.1.4.0/24", "dmz"},
+		{"10.0.0.0/8", ""},
+		{"web-1", "dmz"},
+		{"unknown", ""},
+	} {
+		job := models.ScanJob{ScanID: "zone-scan-id", Host: tc.host, EnqueuedAt: enqueuedAt.UnixNano(), Zone: tc.zone, Priority: "high"}
+		expectPush(mockRedis, job, "back").SetVal(int64(1))
+	}
+
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{
+		Hosts:    []string{"10.1.0.7", "10.1.4.0/24", "10.0.0.0/8", "web-1", "unknown"},
+		Priority: "high",
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueueScan_RejectsUnknownPriority(t *testing.T) {
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Hosts: []string{"host1"}, Priority: "urgent"})
+	assert.ErrorIs(t, err, business.ErrInvalidPriority)
+}
+
+func TestQueueScan_UnknownGroup(t *testing.T) {
+	database.GetGroup = func(name string) (models.AssetGroup, error) {
+		return models.AssetGroup{}, sql.ErrNoRows
+	}
+
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Groups: []string{"nope"}})
+	assert.ErrorIs(t, err, business.ErrGroupNotFound)
+}
+
+func TestRequeueJob_ResetsStatusAndPushesToHead(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	repos := withMemory(t)
+	assert.NoError(t, repos.Statuses.SetScanStatus("scan-1", "web-1", "in_progress"))
+
+	job := models.ScanJob{ScanID: "scan-1", Host: "web-1", EnqueuedAt: 42, Priority: "low"}
+	expectPush(mockRedis, job, "front").SetVal(int64(1))
+
+	assert.NoError(t, business.RequeueJob(context.Background(), job))
+	assert.Equal(t, []string{"web-1=pending"}, statuses(t, repos, "scan-1"))
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueueScan_CoalesceAttachesToActiveJob(t *testing.T) {
+	repos := withMemory(t)
+	assert.NoError(t, repos.Scans.CreateScan(models.Scan{ScanID: "old-scan", Hosts: []string{"host1", "host2"}}))
+	assert.NoError(t, repos.Statuses.SetScanStatus("old-scan", "host1", "in_progress"))
+	assert.NoError(t, repos.Statuses.SetScanStatus("old-scan", "host2", "done"))
+	utils.GenerateScanID = func() string { return "new-scan" }
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	expectPush(mockRedis, models.ScanJob{ScanID: "new-scan", Host: "host2", EnqueuedAt: enqueuedAt.UnixNano(), Priority: "normal"}, "back").SetVal(int64(1))
+
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Hosts: []string{"host1", "host2"}, Coalesce: true})
+
+	assert.NoError(t, err)
+	sts, err := repos.Statuses.GetScanStatuses("new-scan")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"host": "host1", "status": "in_progress", "attached_to": "old-scan"},
+		{"host": "host2", "status": "pending"},
+	}, sts)
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
@@ -0,0 +1,361 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	PromoteDelayedJobs = promoteDelayedJobs
+
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// delayedKey is the Redis sorted set of jobs that may not run yet, scored by
+// the Unix time they become eligible.
+const delayedKey = "scan_jobs:delayed"
+
+// windowHorizon is how far ahead a job waits for a maintenance window before
+// it is skipped.
+const windowHorizon = 7 * 24 * time.Hour
+
+// promoteBatch bounds the jobs promoted per run.
+const promoteBatch = 500
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func validateSchedule(req models.ScanRequest) error {
+	if req.NotAfter == nil {
+		return nil
+	}
+	if req.NotBefore != nil && !req.NotAfter.After(*req.NotBefore) {
+		return fmt.Errorf("%w: not_after must be after not_before", ErrInvalidSchedule)
+	}
+	if !req.NotAfter.After(utils.Now()) {
+		return fmt.Errorf("%w: not_after is in the past", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+func validateWindows(windows []models.MaintenanceWindow) error {
+	for i, w := range windows {
+		if w.Mode != models.WindowAllow && w.Mode != models.WindowDeny {
+			return fmt.Errorf("%w: window %d: mode must be allow or deny", ErrInvalidGroup, i)
+		}
+		if _, err := clock(w.Start); err != nil {
+			return fmt.Errorf("%w: window %d: start: %v", ErrInvalidGroup, i, err)
+		}
+		if _, err := clock(w.End); err != nil {
+			return fmt.Errorf("%w: window %d: end: %v", ErrInvalidGroup, i, err)
+		}
+		for _, d := range w.Days {
+			if _, ok := weekdays[d]; !ok {
+				return fmt.Errorf("%w: window %d: unknown day %q (use mon..sun)", ErrInvalidGroup, i, d)
+			}
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("%w: window %d: unknown timezone %q", ErrInvalidGroup, i, w.Timezone)
+		}
+	}
+	return nil
+}
+
+// clock parses "HH:MM" into minutes after midnight.
+func clock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// windowsFor collects the maintenance windows of every group target belongs
+// to, tagged with the group name.
+func windowsFor(target string, groups []models.AssetGroup) []models.MaintenanceWindow {
+	var out []models.MaintenanceWindow
+	var inv *models.Host
+	loaded := false
+	for _, g := range groups {
+		if len(g.Windows) == 0 {
+			continue
+		}
+		member := false
+		for _, h := range g.Hosts {
+			if h == target {
+				member = true
+				break
+			}
+		}
+		if !member && g.Match != nil {
+			if !loaded {
+				loaded = true
+				if h, err := Repos.Hosts.GetHost(target); err == nil {
+					inv = &h
+				}
+			}
+			h := models.Host{Host: target}
+			if inv != nil {
+				h = *inv
+			}
+			member = MatchesGroup(g.Match, h)
+		}
+		if !member {
+			continue
+		}
+		for _, w := range g.Windows {
+			w.Group = g.Name
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// contains reports whether t lies in the window.
+func contains(w models.MaintenanceWindow, t time.Time) bool {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	start, _ := clock(w.Start)
+	end, _ := clock(w.End)
+	lt := t.In(loc)
+	now := lt.Hour()*60 + lt.Minute()
+	day := lt.Weekday()
+	switch {
+	case start == end:
+	case start < end:
+		if now < start || now >= end {
+			return false
+		}
+	case now >= start:
+	case now < end:
+		day = (day + 6) % 7 // started the day before
+	default:
+		return false
+	}
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[d] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether windows permit scanning at t.
+func allowed(windows []models.MaintenanceWindow, t time.Time) bool {
+	hasAllow := map[string]bool{}
+	inAllow := map[string]bool{}
+	for _, w := range windows {
+		in := contains(w, t)
+		if w.Mode == models.WindowDeny && in {
+			return false
+		}
+		if w.Mode == models.WindowAllow {
+			hasAllow[w.Group] = true
+			inAllow[w.Group] = inAllow[w.Group] || in
+		}
+	}
+	for g := range hasAllow {
+		if !inAllow[g] {
+			return false
+		}
+	}
+	return true
+}
+
+// nextAllowed returns the first time at or after t the windows permit
+// scanning, looking up to windowHorizon ahead. Permission only changes where
+// a window starts or ends, so only those instants need checking.
+func nextAllowed(windows []models.MaintenanceWindow, t time.Time) (time.Time, bool) {
+	if allowed(windows, t) {
+		return t, true
+	}
+	var candidates []time.Time
+	for _, w := range windows {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			continue
+		}
+		start, _ := clock(w.Start)
+		end, _ := clock(w.End)
+		lt := t.In(loc)
+		for d := 0; d <= int(windowHorizon/(24*time.Hour)); d++ {
+			midnight := time.Date(lt.Year(), lt.Month(), lt.Day()+d, 0, 0, 0, 0, loc)
+			for _, m := range []int{start, end} {
+				if c := midnight.Add(time.Duration(m) * time.Minute); c.After(t) {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	for _, c := range candidates {
+		if c.Sub(t) > windowHorizon {
+			break
+		}
+		if allowed(windows, c) {
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// eligibleAt returns when the job may run, or a reason it never will.
+func eligibleAt(job models.ScanJob, now time.Time) (time.Time, string) {
+	if job.NotAfter != nil && !now.Before(*job.NotAfter) {
+		return time.Time{}, "not_after passed before the host could be scanned"
+	}
+	at := now
+	if job.NotBefore != nil && job.NotBefore.After(at) {
+		at = *job.NotBefore
+	}
+	at, ok := nextAllowed(job.Windows, at)
+	if !ok {
+		return time.Time{}, "maintenance windows allow no scan in the next 7 days"
+	}
+	if job.NotAfter != nil && !at.Before(*job.NotAfter) {
+		return time.Time{}, "maintenance windows allow no scan before not_after"
+	}
+	return at, ""
+}
+
+// restricted reports whether the job has any timing constraint.
+func restricted(job models.ScanJob) bool {
+	return job.NotBefore != nil || job.NotAfter != nil || len(job.Windows) > 0
+}
+
+// placement decides where a job goes now: the status to record and when it
+// may run (now for the queue, later for the delayed set), or the reason it is
+// skipped.
+func placement(job models.ScanJob) (status string, at time.Time, reason string) {
+	now := utils.Now()
+	if !restricted(job) {
+		return "pending", now, ""
+	}
+	at, reason = eligibleAt(job, now)
+	if at.After(now) {
+		return "scheduled", at, reason
+	}
+	return "pending", at, reason
+}
+
+// pushJob queues the job, or holds it in the delayed set until at.
+func pushJob(ctx context.Context, job models.ScanJob, at time.Time) error {
+	if !at.After(utils.Now()) {
+		return Enqueue(ctx, job)
+	}
+	return Queue.Jobs.Delay(ctx, job, at)
+}
+
+func (redisQueue) Delay(ctx context.Context, job models.ScanJob, at time.Time) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return database.RDB.ZAdd(ctx, delayedKey, redis.Z{Score: float64(at.Unix()), Member: jobJSON}).Err()
+}
+
+func (redisQueue) TakeDelayed(ctx context.Context, now time.Time, max int) ([]models.ScanJob, error) {
+	due, err := database.RDB.ZRangeByScore(ctx, delayedKey, &redis.ZRangeBy{
+		Min: "-inf", Max: strconv.FormatInt(now.Unix(), 10), Count: int64(max),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var taken []string
+	for _, member := range due {
+		// Whoever removes the member owns the job.
+		n, err := database.RDB.ZRem(ctx, delayedKey, member).Result()
+		if err != nil {
+			if len(taken) == 0 {
+				return nil, err
+			}
+			// Jobs already removed must not be lost with the error.
+			log.Printf("Taking delayed jobs: %v", err)
+			break
+		}
+		if n == 1 {
+			taken = append(taken, member)
+		}
+	}
+	return decodeJobs(taken, "delayed job"), nil
+}
+
+// schedule puts a job where it belongs now: the queue if it may run, the
+// delayed set if it may run later, or skipped if it never may. The status is
+// recorded first so a failure leaves nothing queued without one.
+func schedule(ctx context.Context, job models.ScanJob) error {
+	status, at, reason := placement(job)
+	if reason != "" {
+		return skip(job, reason)
+	}
+	if err := UpdateScanStatus(ctx, job.ScanID, job.Host, status); err != nil {
+		return err
+	}
+	return pushJob(ctx, job, at)
+}
+
+func skip(job models.ScanJob, reason string) error {
+	log.Printf("Skipping %s for scan %s: %s", job.Host, job.ScanID, reason)
+	return Repos.Statuses.FinishScanHost(job.ScanID, job.Host, "skipped", reason)
+}
+
+// admit checks a popped job against its constraints once more: it may have
+// waited in the queue past not_after or into a closed window. Jobs that may
+// not run now are rescheduled and admit returns false.
+func admit(ctx context.Context, job models.ScanJob) bool {
+	if !restricted(job) {
+		return true
+	}
+	now := utils.Now()
+	if at, reason := eligibleAt(job, now); reason == "" && !at.After(now) {
+		return true
+	}
+	if err := schedule(ctx, job); err != nil {
+		log.Printf("Failed to reschedule %s for scan %s: %v", job.Host, job.ScanID, err)
+	}
+	finishJob(ctx, job)
+	return false
+}
+
+// promoteDelayedJobs moves delayed jobs whose time has come into their
+// queues (or skips them) and returns how many it handled.
+func promoteDelayedJobs(ctx context.Context) (int, error) {
+	due, err := Queue.Jobs.TakeDelayed(ctx, utils.Now(), promoteBatch)
+	if err != nil {
+		return 0, err
+	}
+	handled := 0
+	for _, job := range due {
+		retry := job
+		// Queue wait counts from when the job became eligible.
+		job.EnqueuedAt = utils.Now().UnixNano()
+		if err := schedule(ctx, job); err != nil {
+			log.Printf("Failed to schedule %s for scan %s, retrying in a minute: %v", job.Host, job.ScanID, err)
+			if err := Queue.Jobs.Delay(ctx, retry, utils.Now().Add(time.Minute)); err != nil {
+				return handled, err
+			}
+			continue
+		}
+		handled++
+	}
+	return handled, nil
+}
@@ -0,0 +1,118 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+	utils "nmap-rest-api/utils"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// enqueuedAt is Thursday 2024-05-09 07:00 UTC, 09:00 in Berlin.
+var paymentsDeny = models.MaintenanceWindow{Mode: "deny", Start: "09:00", End: "18:00", Timezone: "Europe/Berlin"}
+
+func stubScheduling(t *testing.T, groups []models.AssetGroup) (redismock.ClientMock, func(scanID string) map[string]string) {
+	t.Helper()
+	database.ListGroups = func() ([]models.AssetGroup, error) { return groups, nil }
+	t.Cleanup(func() { database.ListGroups = func() ([]models.AssetGroup, error) { return nil, nil } })
+	repos := withMemory(t)
+	utils.GenerateScanID = func() string { return "sched-id" }
+
+	statuses := func(scanID string) map[string]string {
+		sts, err := repos.Statuses.GetScanStatuses(scanID)
+		assert.NoError(t, err)
+		m := map[string]string{}
+		for _, st := range sts {
+			m[st["host"]] = st["status"]
+			if st["reason"] != "" {
+				m[st["host"]] += ": " + st["reason"]
+			}
+		}
+		return m
+	}
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	return mockRedis, statuses
+}
+
+func TestQueueScan_DelaysHostsInDenyWindow(t *testing.T) {
+	payments := models.AssetGroup{
+		Name:    "payments",
+		Match:   &models.GroupMatch{CIDRs: []string{"10.9.0.0/16"}},
+		Windows: []models.MaintenanceWindow{paymentsDeny},
+	}
+	mockRedis, statuses := stubScheduling(t, []models.AssetGroup{payments})
+
+	held := paymentsDeny
+	held.Group = "payments"
+	delayed := models.ScanJob{ScanID: "sched-id", Host: "10.9.0.5", Priority: "normal",
+		Windows: []models.MaintenanceWindow{held}, EnqueuedAt: enqueuedAt.UnixNano()}
+	delayedJSON, _ := json.Marshal(delayed)
+	// 18:00 in Berlin is 16:00 UTC.
+	mockRedis.ExpectZAdd("scan_jobs:delayed", redis.Z{Score: float64(enqueuedAt.Add(9 * time.Hour).Unix()), Member: delayedJSON}).SetVal(1)
+	expectPush(mockRedis, models.ScanJob{ScanID: "sched-id", Host: "10.8.0.5", Priority: "normal", EnqueuedAt: enqueuedAt.UnixNano()}, "back").SetVal(int64(1))
+
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Hosts: []string{"10.9.0.5", "10.8.0.5"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"10.9.0.5": "scheduled", "10.8.0.5": "pending"}, statuses("sched-id"))
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueueScan_SkipsHostsWhoseWindowNeverOpensInTime(t *testing.T) {
+	nightly := models.AssetGroup{
+		Name:  "db",
+		Hosts: []string{"db-1"},
+		Windows: []models.MaintenanceWindow{
+			{Mode: "allow", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "22:00", End: "02:00"},
+		},
+	}
+	mockRedis, statuses := stubScheduling(t, []models.AssetGroup{nightly})
+	notAfter := enqueuedAt.Add(12 * time.Hour) // 19:00 UTC, before the 22:00 window
+
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Hosts: []string{"db-1"}, NotAfter: &notAfter})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"db-1": "skipped: maintenance windows allow no scan before not_after"}, statuses("sched-id"))
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestQueueScan_RejectsInvertedSchedule(t *testing.T) {
+	before, after := enqueuedAt.Add(2*time.Hour), enqueuedAt.Add(time.Hour)
+	_, err := business.QueueScan(context.Background(), models.ScanRequest{Hosts: []string{"h"}, NotBefore: &before, NotAfter: &after})
+	assert.ErrorIs(t, err, business.ErrInvalidSchedule)
+}
+
+func TestPromoteDelayedJobs(t *testing.T) {
+	mockRedis, statuses := stubScheduling(t, nil)
+
+	notBefore := enqueuedAt.Add(-time.Minute)
+	expired := enqueuedAt.Add(-time.Second)
+	due := models.ScanJob{ScanID: "s1", Host: "web-1", NotBefore: &notBefore, EnqueuedAt: 1}
+	late := models.ScanJob{ScanID: "s1", Host: "web-2", NotAfter: &expired}
+	dueJSON, _ := json.Marshal(due)
+	lateJSON, _ := json.Marshal(late)
+	mockRedis.ExpectZRangeByScore("scan_jobs:delayed", &redis.ZRangeBy{
+		Min: "-inf", Max: "1715238000", Count: 500,
+	}).SetVal([]string{string(dueJSON), string(lateJSON)})
+	mockRedis.ExpectZRem("scan_jobs:delayed", string(dueJSON)).SetVal(1)
+	mockRedis.ExpectZRem("scan_jobs:delayed", string(lateJSON)).SetVal(1)
+	due.EnqueuedAt = enqueuedAt.UnixNano()
+	expectPush(mockRedis, due, "back").SetVal(int64(1))
+
+	n, err := business.PromoteDelayedJobs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "pending", statuses("s1")["web-1"])
+	assert.Equal(t, "skipped: not_after passed before the host could be scanned", statuses("s1")["web-2"])
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+
+	models "nmap-rest-api/models/v1"
+)
+
+const maxSearchLimit = 1000
+
+var (
+	SearchPorts = searchPorts
+
+	ErrInvalidSearch = errors.New("invalid search query")
+)
+
+// searchPorts finds hosts exposing a port/service matching q. At least one of
+// port, service or product must be given so a search never dumps every port.
+func searchPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error) {
+	q, err := normalizeSearch(q)
+	if err != nil {
+		return nil, err
+	}
+	return Repos.Results.SearchOpenPorts(q)
+}
+
+func normalizeSearch(q models.PortSearchQuery) (models.PortSearchQuery, error) {
+	if q.Port < 0 || q.Port > 65535 {
+		return q, ErrInvalidSearch
+	}
+	if q.Port == 0 && q.Service == "" && q.Product == "" {
+		return q, ErrInvalidSearch
+	}
+	switch strings.ToLower(q.Protocol) {
+	case "", "tcp", "udp", "sctp":
+	default:
+		return q, ErrInvalidSearch
+	}
+	if q.SeenWithin < 0 {
+		return q, ErrInvalidSearch
+	}
+	if q.Limit <= 0 || q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+	return q, nil
+}
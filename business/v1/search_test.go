@@ -0,0 +1,59 @@
+package v1_test
+
+import (
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// searchQueries records port searches instead of running them.
+type searchQueries struct {
+	database.ResultRepository
+	queries []models.PortSearchQuery
+}
+
+func (s *searchQueries) SearchOpenPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error) {
+	s.queries = append(s.queries, q)
+	return []models.PortSearchHit{{Host: "host1", ScanID: "scan-1"}}, nil
+}
+
+func withSearches(t *testing.T) *searchQueries {
+	repos := withMemory(t)
+	s := &searchQueries{ResultRepository: repos.Results}
+	business.Repos.Results = s
+	return s
+}
+
+func TestSearchPorts_RequiresCriteria(t *testing.T) {
+	searches := withSearches(t)
+
+	_, err := business.SearchPorts(models.PortSearchQuery{Protocol: "tcp"})
+	assert.ErrorIs(t, err, business.ErrInvalidSearch)
+
+	_, err = business.SearchPorts(models.PortSearchQuery{Port: 70000})
+	assert.ErrorIs(t, err, business.ErrInvalidSearch)
+
+	_, err = business.SearchPorts(models.PortSearchQuery{Port: 22, Protocol: "icmp"})
+	assert.ErrorIs(t, err, business.ErrInvalidSearch)
+	assert.Empty(t, searches.queries, "database should not be queried")
+}
+
+func TestSearchPorts_PassesQueryAndCapsLimit(t *testing.T) {
+	searches := withSearches(t)
+
+	hits, err := business.SearchPorts(models.PortSearchQuery{Port: 443, SeenWithin: time.Hour, Limit: 50000})
+
+	assert.NoError(t, err)
+	assert.Len(t, hits, 1)
+	if assert.Len(t, searches.queries, 1) {
+		got := searches.queries[0]
+		assert.Equal(t, 443, got.Port)
+		assert.Equal(t, time.Hour, got.SeenWithin)
+		assert.Equal(t, 1000, got.Limit)
+	}
+}
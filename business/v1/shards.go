@@ -0,0 +1,279 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	PersistJobResult = persistJobResult
+
+	ErrInvalidPorts = errors.New("invalid ports")
+)
+
+// maxShards bounds the jobs one host's port range is split into.
+const maxShards = 1024
+
+// shardsTTL bounds how long finished shards wait for the rest; a host whose
+// shards never all finish stays in progress.
+const shardsTTL = 7 * 24 * time.Hour
+
+type portRange struct{ lo, hi int }
+
+// parsePorts reads an nmap style port list ("22,80,8000-8100") into sorted,
+// merged ranges.
+func parsePorts(spec string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+		a, errA := strconv.Atoi(lo)
+		b, errB := strconv.Atoi(hi)
+		if errA != nil || errB != nil || a < 1 || b > 65535 || a > b {
+			return nil, fmt.Errorf("%w: %q is not a port or range within 1-65535", ErrInvalidPorts, part)
+		}
+		ranges = append(ranges, portRange{a, b})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.lo <= last.hi+1 {
+			if r.hi > last.hi {
+				last.hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged, nil
+}
+
+func formatPorts(ranges []portRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.lo == r.hi {
+			parts[i] = strconv.Itoa(r.lo)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", r.lo, r.hi)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitPorts normalizes spec and cuts it into port lists of at most size
+// ports each; size 0 keeps it whole.
+func splitPorts(spec string, size int) ([]string, error) {
+	ranges, err := parsePorts(spec)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return []string{formatPorts(ranges)}, nil
+	}
+	var shards []string
+	var cur []portRange
+	n := 0
+	for _, r := range ranges {
+		for lo := r.lo; lo <= r.hi; {
+			hi := r.hi
+			if hi-lo+1 > size-n {
+				hi = lo + size - n - 1
+			}
+			cur = append(cur, portRange{lo, hi})
+			n += hi - lo + 1
+			lo = hi + 1
+			if n == size {
+				shards = append(shards, formatPorts(cur))
+				cur, n = nil, 0
+			}
+		}
+	}
+	if n > 0 {
+		shards = append(shards, formatPorts(cur))
+	}
+	if len(shards) > maxShards {
+		return nil, fmt.Errorf("%w: %d shards exceed the limit of %d; raise shard_size", ErrInvalidPorts, len(shards), maxShards)
+	}
+	return shards, nil
+}
+
+// validatePorts checks the request's ports and shard size. It returns the
+// normalized port list and the port list of each job a host gets: one entry,
+// empty for nmap's default ports, unless the range is sharded.
+func validatePorts(req models.ScanRequest) (string, []string, error) {
+	if req.ShardSize < 0 {
+		return "", nil, fmt.Errorf("%w: shard_size must not be negative", ErrInvalidPorts)
+	}
+	if req.Ports == "" {
+		if req.ShardSize > 0 {
+			return "", nil, fmt.Errorf("%w: shard_size needs ports", ErrInvalidPorts)
+		}
+		return "", []string{""}, nil
+	}
+	ranges, err := parsePorts(req.Ports)
+	if err != nil {
+		return "", nil, err
+	}
+	spec := formatPorts(ranges)
+	shards, err := splitPorts(spec, req.ShardSize)
+	if err != nil {
+		return "", nil, err
+	}
+	return spec, shards, nil
+}
+
+// shardJobs returns job once per port list, numbered as shards when there
+// are several.
+func shardJobs(job models.ScanJob, ports []string) []models.ScanJob {
+	jobs := make([]models.ScanJob, len(ports))
+	for i, p := range ports {
+		jobs[i] = job
+		jobs[i].Ports = p
+		if len(ports) > 1 {
+			jobs[i].Shard, jobs[i].Shards = i, len(ports)
+		}
+	}
+	return jobs
+}
+
+// shardOutcome is one finished shard as kept until its siblings finish.
+type shardOutcome struct {
+	Shard  int               `json:"shard"`
+	Ports  string            `json:"ports"`
+	Result models.ScanResult `json:"result"`
+}
+
+// shardScript records shard ARGV[1]'s outcome ARGV[2] and, once all ARGV[3]
+// shards of the host are in, removes and returns them all. Only one caller
+// ever sees the full set.
+var shardScript = redis.NewScript(`
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("EXPIRE", KEYS[1], ARGV[4])
+if redis.call("HLEN", KEYS[1]) < tonumber(ARGV[3]) then
+	return {}
+end
+local all = redis.call("HVALS", KEYS[1])
+redis.call("DEL", KEYS[1])
+return all`)
+
+// persistJobResult stores what a worker or agent found for a job. A job
+// nmap never produced a result for fails its host with the error instead.
+// Shard results are held until every shard of the host is in, then merged
+// into one result. The job is finished in the queue either way.
+func persistJobResult(ctx context.Context, job models.ScanJob, res models.ScanResult) error {
+	defer finishJob(ctx, job)
+	if job.Shards <= 1 {
+		if res.Error != "" {
+			log.Printf("Scan of %s for scan %s failed: %s", job.Host, job.ScanID, res.Error)
+			return Repos.Statuses.FinishScanHost(job.ScanID, job.Host, "failed", res.Error)
+		}
+		return PersistResult(ctx, res)
+	}
+	outcome, err := json.Marshal(shardOutcome{Shard: job.Shard, Ports: job.Ports, Result: res})
+	if err != nil {
+		return err
+	}
+	key := "scan_shards:" + job.ScanID + "|" + job.Host
+	vals, err := Queue.Shards.AddShard(ctx, key, job.Shard, job.Shards, outcome, shardsTTL)
+	if err != nil {
+		return err
+	}
+	if len(vals) == 0 {
+		log.Printf("Shard %d/%d of %s for scan %s stored", job.Shard+1, job.Shards, job.Host, job.ScanID)
+		return nil
+	}
+
+	outcomes := make([]shardOutcome, 0, len(vals))
+	for _, v := range vals {
+		var o shardOutcome
+		if err := json.Unmarshal(v, &o); err != nil {
+			return err
+		}
+		outcomes = append(outcomes, o)
+	}
+	merged, ok := mergeShards(job, outcomes)
+	if !ok {
+		reason := fmt.Sprintf("all %d shards failed: %s", job.Shards, outcomes[0].Result.Error)
+		return Repos.Statuses.FinishScanHost(job.ScanID, job.Host, "failed", reason)
+	}
+	return PersistResult(ctx, merged)
+}
+
+func (redisQueue) AddShard(ctx context.Context, key string, shard, shards int, outcome []byte, ttl time.Duration) ([][]byte, error) {
+	vals, err := shardScript.Run(ctx, database.RDB, []string{key}, shard, outcome, shards, int(ttl.Seconds())).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	outcomes := make([][]byte, len(vals))
+	for i, v := range vals {
+		outcomes[i] = []byte(v)
+	}
+	return outcomes, nil
+}
+
+// mergeShards combines the shards of one host: the union of their ports and
+// addresses, up if any shard saw the host up, scanned when the last shard
+// finished. Failed shards' port lists go to Incomplete; ok is false when
+// every shard failed.
+func mergeShards(job models.ScanJob, outcomes []shardOutcome) (models.ScanResult, bool) {
+	sort.Slice(outcomes, func(i, j int) bool { return outcomes[i].Shard < outcomes[j].Shard })
+	merged := models.ScanResult{ScanID: job.ScanID, Host: job.Host, HostState: "down"}
+	seenPort := map[string]bool{}
+	seenAddr := map[string]bool{}
+	succeeded := 0
+	for _, o := range outcomes {
+		r := o.Result
+		if r.Error != "" {
+			merged.Incomplete = append(merged.Incomplete, o.Ports)
+			continue
+		}
+		succeeded++
+		if r.HostState == "up" {
+			merged.HostState = "up"
+		}
+		if r.ScannedAt.After(merged.ScannedAt) {
+			merged.ScannedAt = r.ScannedAt
+		}
+		for _, p := range r.Ports {
+			key := p.Protocol + "/" + strconv.Itoa(p.Port)
+			if !seenPort[key] {
+				seenPort[key] = true
+				merged.Ports = append(merged.Ports, p)
+			}
+		}
+		for _, a := range r.Addresses {
+			if !seenAddr[a] {
+				seenAddr[a] = true
+				merged.Addresses = append(merged.Addresses, a)
+			}
+		}
+	}
+	if merged.ScannedAt.IsZero() {
+		merged.ScannedAt = utils.Now()
+	}
+	sort.Slice(merged.Ports, func(i, j int) bool {
+		if merged.Ports[i].Port != merged.Ports[j].Port {
+			return merged.Ports[i].Port < merged.Ports[j].Port
+		}
+		return merged.Ports[i].Protocol < merged.Ports[j].Protocol
+	})
+	merged.OpenPorts = utils.PortNumbers(merged.Ports)
+	return merged, succeeded > 0
+}
@@ -0,0 +1,136 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	business "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+	"nmap-rest-api/models/v1"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPorts(t *testing.T) {
+	shards, err := business.SplitPorts("8000-8002, 22,80,21-23", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"21-23", "80,8000-8001", "8002"}, shards)
+
+	whole, err := business.SplitPorts("1-65535", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1-65535"}, whole)
+
+	_, err = business.SplitPorts("1-65535", 16)
+	assert.ErrorIs(t, err, business.ErrInvalidPorts, "4096 shards exceed the limit")
+	_, err = business.SplitPorts("80-22", 0)
+	assert.ErrorIs(t, err, business.ErrInvalidPorts)
+}
+
+func shardOutcome(t *testing.T, shard int, ports string, res models.ScanResult) []byte {
+	t.Helper()
+	b, err := json.Marshal(struct {
+		Shard  int               `json:"shard"`
+		Ports  string            `json:"ports"`
+		Result models.ScanResult `json:"result"`
+	}{shard, ports, res})
+	require.NoError(t, err)
+	return b
+}
+
+// storedResults records stored results instead of storing them.
+type storedResults struct {
+	database.ResultRepository
+	results  []models.ScanResult
+	statuses []string
+	reasons  []string
+}
+
+func (s *storedResults) StoreResult(res models.ScanResult, status, reason string) error {
+	s.results = append(s.results, res)
+	s.statuses = append(s.statuses, status)
+	s.reasons = append(s.reasons, reason)
+	return nil
+}
+
+func withStoredResults(t *testing.T) *storedResults {
+	repos := withMemory(t)
+	s := &storedResults{ResultRepository: repos.Results}
+	business.Repos.Results = s
+	return s
+}
+
+func TestPersistJobResult_HoldsShardsUntilAllFinish(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	stored := withStoredResults(t)
+	job := models.ScanJob{ScanID: "s1", Host: "10.0.0.1", Ports: "1-100", Shard: 0, Shards: 2}
+	res := models.ScanResult{ScanID: "s1", Host: "10.0.0.1", HostState: "up"}
+	mockRedis.ExpectEvalSha(business.ShardScriptHash, []string{"scan_shards:s1|10.0.0.1"},
+		0, shardOutcome(t, 0, "1-100", res), 2, 604800).SetVal([]interface{}{})
+
+	assert.NoError(t, business.PersistJobResult(context.Background(), job, res))
+	assert.Empty(t, stored.results, "a shard must not be stored on its own")
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestPersistJobResult_MergesShardsAndMarksFailedRangesPartial(t *testing.T) {
+	rdb, mockRedis := redismock.NewClientMock()
+	database.RDB = rdb
+	scannedAt := time.Date(2024, 5, 9, 7, 30, 0, 0, time.UTC)
+	first := models.ScanResult{ScanID: "s1", Host: "10.0.0.1", HostState: "up", ScannedAt: scannedAt,
+		Ports: []models.PortInfo{{Port: 80, Protocol: "tcp", State: "open"}}, Addresses: []string{"10.0.0.1"}}
+	second := models.ScanResult{ScanID: "s1", Host: "10.0.0.1", Error: "nmap: signal: killed"}
+	third := models.ScanResult{ScanID: "s1", Host: "10.0.0.1", HostState: "up", ScannedAt: scannedAt.Add(time.Minute),
+		Ports: []models.PortInfo{{Port: 443, Protocol: "tcp", State: "open"}, {Port: 22, Protocol: "tcp", State: "open"}}, Addresses: []string{"10.0.0.1"}}
+	job := models.ScanJob{ScanID: "s1", Host: "10.0.0.1", Ports: "201-300", Shard: 2, Shards: 3}
+	mockRedis.ExpectEvalSha(business.ShardScriptHash, []string{"scan_shards:s1|10.0.0.1"},
+		2, shardOutcome(t, 2, "201-300", third), 3, 604800).
+		SetVal([]interface{}{
+			string(shardOutcome(t, 1, "101-200", second)),
+			string(shardOutcome(t, 0, "1-100", first)),
+			string(shardOutcome(t, 2, "201-300", third)),
+		})
+
+	stores := withStoredResults(t)
+	processed := false
+	orig := business.ProcessStoredResult
+	defer func() { business.ProcessStoredResult = orig }()
+	business.ProcessStoredResult = func(ctx context.Context, res models.ScanResult) { processed = true }
+
+	assert.NoError(t, business.PersistJobResult(context.Background(), job, third))
+
+	require.Len(t, stores.results, 1)
+	stored, status, reason := stores.results[0], stores.statuses[0], stores.reasons[0]
+	assert.Equal(t, []int{22, 80, 443}, stored.OpenPorts)
+	assert.Equal(t, []string{"10.0.0.1"}, stored.Addresses)
+	assert.Equal(t, "up", stored.HostState)
+	assert.Equal(t, scannedAt.Add(time.Minute), stored.ScannedAt)
+	assert.Equal(t, []string{"101-200"}, stored.Incomplete)
+	assert.Equal(t, "partial", status)
+	assert.Contains(t, reason, "101-200")
+	assert.False(t, processed, "a partial result must not feed the inventory")
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestPersistJobResult_FailsTheHostWhenNmapFailed(t *testing.T) {
+	stored := withStoredResults(t)
+	require.NoError(t, business.Repos.Statuses.SetScanStatus("s1", "10.0.0.1", "in_progress"))
+	processed := false
+	orig := business.ProcessStoredResult
+	defer func() { business.ProcessStoredResult = orig }()
+	business.ProcessStoredResult = func(ctx context.Context, res models.ScanResult) { processed = true }
+
+	job := models.ScanJob{ScanID: "s1", Host: "10.0.0.1"}
+	res := models.ScanResult{ScanID: "s1", Host: "10.0.0.1", Error: "nmap: exit status 1: Failed to resolve"}
+	assert.NoError(t, business.PersistJobResult(context.Background(), job, res))
+
+	assert.Empty(t, stored.results, "an empty result must not be stored")
+	assert.False(t, processed, "a failed scan must not feed the inventory, baselines or alerts")
+	sts, err := business.Repos.Statuses.GetScanStatuses("s1")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"host": "10.0.0.1", "status": "failed", "reason": "nmap: exit status 1: Failed to resolve"}}, sts)
+}
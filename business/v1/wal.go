@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// walRecord is one change to a MemoryQueue in its write-ahead file, one JSON
+// object per line. At is Unix milliseconds: when a delayed job may run, or
+// when a key or shard set expires (0 for never).
+type walRecord struct {
+	Op    string `json:"op"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	Front bool   `json:"front,omitempty"`
+	Shard int    `json:"shard,omitempty"`
+	At    int64  `json:"at,omitempty"`
+}
+
+// walCompactEvery is how many records are appended before the file is
+// rewritten from the current state.
+const walCompactEvery = 10000
+
+// wal is an append-only file of walRecords. Each append is synced to disk
+// before the change is applied, so it survives a crash of the process or the
+// host.
+type wal struct {
+	path     string
+	f        *os.File
+	appended int // records since the last rewrite
+}
+
+// openWAL reads the records in path, creating it if needed, and opens it
+// for appending. A line that does not decode, such as one cut short by a
+// crash, is skipped.
+func openWAL(path string) (*wal, []walRecord, error) {
+	var records []walRecord
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, nil, err
+	default:
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			var r walRecord
+			if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+				log.Printf("Skipping line %d of %s: %v", line, path, err)
+				continue
+			}
+			records = append(records, r)
+		}
+		err := sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A file just created must stay in its directory too.
+	if err := syncFile(filepath.Dir(path)); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return &wal{path: path, f: f}, records, nil
+}
+
+func encodeRecords(records []walRecord) ([]byte, error) {
+	var buf []byte
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	return buf, nil
+}
+
+// append writes records in one write and syncs them.
+func (w *wal) append(records ...walRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Write(buf); err != nil {
+		return fmt.Errorf("writing %s: %w", w.path, err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("syncing %s: %w", w.path, err)
+	}
+	w.appended += len(records)
+	return nil
+}
+
+// rewrite replaces the file with records, atomically.
+func (w *wal) rewrite(records []walRecord) error {
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return err
+	}
+	if err := syncFile(filepath.Dir(w.path)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	w.f.Close()
+	w.f, w.appended = f, 0
+	return nil
+}
+
+// syncFile syncs a file, or a directory's entries, to disk.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
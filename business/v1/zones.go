@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+)
+
+var (
+	CreateZone  = createZone
+	ReplaceZone = replaceZone
+	GetZone     = getZone
+	ListZones   = listZones
+	DeleteZone  = deleteZone
+
+	ErrZoneNotFound = errors.New("zone not found")
+	ErrZoneExists   = errors.New("zone already exists")
+	ErrInvalidZone  = errors.New("invalid zone")
+)
+
+func createZone(z models.Zone) error {
+	if err := validateZone(&z); err != nil {
+		return err
+	}
+	err := database.CreateZone(z)
+	if errors.Is(err, database.ErrConflict) {
+		return ErrZoneExists
+	}
+	return err
+}
+
+func replaceZone(z models.Zone) error {
+	if err := validateZone(&z); err != nil {
+		return err
+	}
+	return notFound(database.ReplaceZone(z), ErrZoneNotFound)
+}
+
+func getZone(name string) (models.Zone, error) {
+	z, err := database.GetZone(name)
+	return z, notFound(err, ErrZoneNotFound)
+}
+
+func listZones() ([]models.Zone, error) {
+	return database.ListZones()
+}
+
+// deleteZone removes the zone. Jobs already queued for it stay in its list
+// until an agent of that zone takes them.
+func deleteZone(name string) error {
+	return notFound(database.DeleteZone(name), ErrZoneNotFound)
+}
+
+func validateZone(z *models.Zone) error {
+	if !groupNameRegex.MatchString(z.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits, '.', '_' or '-'", ErrInvalidZone)
+	}
+	if len(z.CIDRs) == 0 {
+		return fmt.Errorf("%w: at least one CIDR is required", ErrInvalidZone)
+	}
+	for _, c := range z.CIDRs {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return fmt.Errorf("%w: invalid CIDR %q", ErrInvalidZone, c)
+		}
+	}
+	return nil
+}
+
+// zoneRouter assigns scan targets to zones by CIDR.
+type zoneRouter struct {
+	zones []zoneNets
+}
+
+type zoneNets struct {
+	name string
+	nets []*net.IPNet
+}
+
+func newZoneRouter() (*zoneRouter, error) {
+	zones, err := database.ListZones()
+	if err != nil {
+		return nil, err
+	}
+	r := &zoneRouter{}
+	for _, z := range zones {
+		zn := zoneNets{name: z.Name}
+		for _, c := range z.CIDRs {
+			if _, n, err := net.ParseCIDR(c); err == nil {
+				zn.nets = append(zn.nets, n)
+			}
+		}
+		r.zones = append(r.zones, zn)
+	}
+	return r, nil
+}
+
+// zoneOf returns the zone that scans target, or "" for the central workers.
+// IP and CIDR targets are matched directly (a CIDR must lie wholly inside the
+// zone); names are matched through the IPs the inventory resolved them to.
+// Zones are tried in name order and the first match wins.
+func (r *zoneRouter) zoneOf(target string) (string, error) {
+	if len(r.zones) == 0 {
+		return "", nil
+	}
+	var ips []net.IP
+	var prefix *net.IPNet
+	switch {
+	case net.ParseIP(target) != nil:
+		ips = []net.IP{net.ParseIP(target)}
+	default:
+		if _, n, err := net.ParseCIDR(target); err == nil {
+			prefix = n
+			break
+		}
+		h, err := Repos.Hosts.GetHost(target)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, s := range h.ResolvedIPs {
+			if ip := net.ParseIP(s); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+
+	for _, z := range r.zones {
+		for _, n := range z.nets {
+			if prefix != nil && n.Contains(prefix.IP) {
+				inner, _ := prefix.Mask.Size()
+				outer, _ := n.Mask.Size()
+				if inner >= outer {
+					return z.name, nil
+				}
+			}
+			for _, ip := range ips {
+				if n.Contains(ip) {
+					return z.name, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
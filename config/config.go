@@ -0,0 +1,379 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML or TOML file, environment variables and command line flags, in that
+// order of precedence (later wins).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config is the complete service configuration. Struct tags drive every
+// source: yaml/toml name the file keys, env the environment variable and flag
+// the command line flag; secret marks values redacted by Redacted.
+type Config struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Database  Database  `yaml:"database" toml:"database"`
+	Redis     Redis     `yaml:"redis" toml:"redis"`
+	Queue     Queue     `yaml:"queue" toml:"queue"`
+	Worker    Worker    `yaml:"worker" toml:"worker"`
+	Nmap      Nmap      `yaml:"nmap" toml:"nmap"`
+	Retention Retention `yaml:"retention" toml:"retention"`
+	Telemetry Telemetry `yaml:"telemetry" toml:"telemetry"`
+	Agents    Agents    `yaml:"agents" toml:"agents"`
+	Limits    Limits    `yaml:"limits" toml:"limits"`
+}
+
+type Server struct {
+	Listen         string   `yaml:"listen" toml:"listen" env:"LISTEN_ADDR" flag:"listen" usage:"HTTP listen address"`
+	TLSCertFile    string   `yaml:"tls_cert_file" toml:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"TLS certificate file; enables HTTPS together with --tls-key"`
+	TLSKeyFile     string   `yaml:"tls_key_file" toml:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key" usage:"TLS private key file"`
+	ShutdownGrace  Duration `yaml:"shutdown_grace" toml:"shutdown_grace" env:"SHUTDOWN_GRACE" flag:"shutdown-grace" usage:"time in-flight requests and scans get on shutdown"`
+	IdempotencyTTL Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long a POST /scan Idempotency-Key replays its response"`
+}
+
+// TLS reports whether HTTPS is configured.
+func (s Server) TLS() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// Database drivers: Postgres, or everything in process memory for single
+// binary dev and CI setups.
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+type Database struct {
+	Driver          string   `yaml:"driver" toml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"postgres, or memory to keep scans in process memory (nothing persists)"`
+	DSN             string   `yaml:"dsn" toml:"dsn" env:"DB_DSN" flag:"db-dsn" secret:"dsn" usage:"Postgres connection string"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections (0 = unlimited)"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle connections"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"recycle connections after this long (0 = never)"`
+	WriteBatch      int      `yaml:"write_batch" toml:"write_batch" env:"DB_WRITE_BATCH" flag:"db-write-batch" usage:"most results committed in one transaction"`
+	WriteBuffer     int      `yaml:"write_buffer" toml:"write_buffer" env:"DB_WRITE_BUFFER" flag:"db-write-buffer" usage:"results queued for writing before storing blocks"`
+}
+
+type Redis struct {
+	Addr          string `yaml:"addr" toml:"addr" env:"REDIS_ADDR" flag:"redis-addr" usage:"Redis host:port"`
+	Username      string `yaml:"username" toml:"username" env:"REDIS_USERNAME" flag:"redis-username" usage:"Redis ACL user"`
+	Password      string `yaml:"password" toml:"password" env:"REDIS_PASSWORD" flag:"redis-password" secret:"true" usage:"Redis password"`
+	DB            int    `yaml:"db" toml:"db" env:"REDIS_DB" flag:"redis-db" usage:"Redis database number"`
+	TLS           bool   `yaml:"tls" toml:"tls" env:"REDIS_TLS" flag:"redis-tls" usage:"connect to Redis over TLS"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify" toml:"tls_skip_verify" env:"REDIS_TLS_SKIP_VERIFY" flag:"redis-tls-skip-verify" usage:"do not verify the Redis server certificate"`
+}
+
+// Queue backends: Redis, shared by any number of processes, or process
+// memory for a single binary.
+const (
+	QueueRedis  = "redis"
+	QueueMemory = "memory"
+)
+
+type Queue struct {
+	Driver string `yaml:"driver" toml:"driver" env:"QUEUE_DRIVER" flag:"queue-driver" usage:"redis, or memory to queue jobs in process"`
+	WAL    string `yaml:"wal" toml:"wal" env:"QUEUE_WAL" flag:"queue-wal" usage:"memory queue: write-ahead file that keeps jobs across restarts (empty = none)"`
+}
+
+type Worker struct {
+	Count     int `yaml:"count" toml:"count" env:"WORKER_COUNT" flag:"workers" usage:"number of scan workers"`
+	BatchSize int `yaml:"batch_size" toml:"batch_size" env:"WORKER_BATCH_SIZE" flag:"worker-batch-size" usage:"jobs a worker scans in one nmap run"`
+}
+
+type Nmap struct {
+	Binary     string   `yaml:"binary" toml:"binary" env:"NMAP_BINARY" flag:"nmap-binary" usage:"nmap executable"`
+	Args       []string `yaml:"args" toml:"args" env:"NMAP_ARGS" flag:"nmap-args" split:"space" usage:"scan arguments; -oX - and the target are appended"`
+	Timeout    Duration `yaml:"timeout" toml:"timeout" env:"NMAP_TIMEOUT" flag:"nmap-timeout" usage:"kill an nmap run after this long (0 = no limit)"`
+	MaxRetries int      `yaml:"max_retries" toml:"max_retries" env:"NMAP_MAX_RETRIES" flag:"nmap-max-retries" usage:"attempts per host while nmap fails"`
+}
+
+// Retention covers the inventory's stale hosts and how long scan results keep
+// full detail. Past ResultsFullDetail a host keeps only its results that
+// differ from the one before, plus the first of every month, forever; zero
+// keeps everything. ResultsFullDetailByOwner overrides it for the hosts of an
+// inventory owner.
+type Retention struct {
+	HostStaleAfter           Duration       `yaml:"host_stale_after" toml:"host_stale_after" env:"HOST_STALE_AFTER" flag:"host-stale-after" usage:"mark inventory hosts stale after not being seen this long"`
+	SweepInterval            Duration       `yaml:"sweep_interval" toml:"sweep_interval" env:"HOST_SWEEP_INTERVAL" flag:"host-sweep-interval" usage:"how often the stale host sweep runs"`
+	ResultsFullDetail        Duration       `yaml:"results_full_detail" toml:"results_full_detail" env:"RESULTS_FULL_DETAIL" flag:"results-full-detail" usage:"keep every scan result this long, then only changes and monthly snapshots (0 = keep everything)"`
+	ResultsFullDetailByOwner OwnerDurations `yaml:"results_full_detail_by_owner" toml:"results_full_detail_by_owner" env:"RESULTS_FULL_DETAIL_BY_OWNER" flag:"results-full-detail-by-owner" usage:"per owner results_full_detail, e.g. team-a=720h,legal=0"`
+	PruneInterval            Duration       `yaml:"prune_interval" toml:"prune_interval" env:"RESULTS_PRUNE_INTERVAL" flag:"results-prune-interval" usage:"how often old scan results are pruned"`
+	PruneBatch               int            `yaml:"prune_batch" toml:"prune_batch" env:"RESULTS_PRUNE_BATCH" flag:"results-prune-batch" usage:"most results deleted in one statement"`
+	CollapseUnchanged        bool           `yaml:"collapse_unchanged" toml:"collapse_unchanged" env:"RESULTS_COLLAPSE_UNCHANGED" flag:"results-collapse-unchanged" usage:"record a result identical to the host's latest as seen again on that one instead of storing it"`
+}
+
+// FullDetailFor returns how long the results of owner's hosts keep full
+// detail; zero keeps everything.
+func (r Retention) FullDetailFor(owner string) time.Duration {
+	if d, ok := r.ResultsFullDetailByOwner[owner]; ok {
+		return d.D()
+	}
+	return r.ResultsFullDetail.D()
+}
+
+// PrunesResults reports whether any hosts' results are pruned.
+func (r Retention) PrunesResults() bool {
+	if r.ResultsFullDetail > 0 {
+		return true
+	}
+	for _, d := range r.ResultsFullDetailByOwner {
+		if d > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type Telemetry struct {
+	OTLPEndpoint     string   `yaml:"otlp_endpoint" toml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"OTLP/HTTP collector host:port"`
+	MetricsExporters []string `yaml:"metrics_exporters" toml:"metrics_exporters" env:"OTEL_METRICS_EXPORTER" flag:"metrics-exporters" usage:"comma separated: otlp, prometheus or none"`
+}
+
+// Agents configures remote scanner agents. The server uses the enrollment
+// token, lease timeout and offline threshold; a process in agent mode uses the
+// enrollment token and the agent_* settings.
+type Agents struct {
+	EnrollmentToken string   `yaml:"enrollment_token" toml:"enrollment_token" env:"AGENT_ENROLLMENT_TOKEN" flag:"agent-enrollment-token" secret:"true" usage:"shared token agents register with; empty disables registration"`
+	LeaseTimeout    Duration `yaml:"lease_timeout" toml:"lease_timeout" env:"AGENT_LEASE_TIMEOUT" flag:"agent-lease-timeout" usage:"requeue a job handed to an agent that stops reporting it for this long"`
+	OfflineAfter    Duration `yaml:"offline_after" toml:"offline_after" env:"AGENT_OFFLINE_AFTER" flag:"agent-offline-after" usage:"show an agent offline after not hearing from it this long"`
+
+	ServerURL         string   `yaml:"agent_server_url" toml:"agent_server_url" env:"AGENT_SERVER_URL" flag:"agent-server-url" usage:"agent mode: base URL of the API, e.g. https://scanner.example.com"`
+	Name              string   `yaml:"agent_name" toml:"agent_name" env:"AGENT_NAME" flag:"agent-name" usage:"agent mode: unique agent name (default: hostname)"`
+	Zone              string   `yaml:"agent_zone" toml:"agent_zone" env:"AGENT_ZONE" flag:"agent-zone" usage:"agent mode: network zone to take jobs for (empty: default queue)"`
+	Capacity          int      `yaml:"agent_capacity" toml:"agent_capacity" env:"AGENT_CAPACITY" flag:"agent-capacity" usage:"agent mode: concurrent scans"`
+	CAFile            string   `yaml:"agent_ca_file" toml:"agent_ca_file" env:"AGENT_CA_FILE" flag:"agent-ca-file" usage:"agent mode: PEM CA bundle to verify the server with"`
+	PollWait          Duration `yaml:"agent_poll_wait" toml:"agent_poll_wait" env:"AGENT_POLL_WAIT" flag:"agent-poll-wait" usage:"agent mode: how long one job poll waits on the server"`
+	HeartbeatInterval Duration `yaml:"agent_heartbeat_interval" toml:"agent_heartbeat_interval" env:"AGENT_HEARTBEAT_INTERVAL" flag:"agent-heartbeat-interval" usage:"agent mode: how often to report state and renew leases"`
+}
+
+// Limits keeps scanning polite: how many scans may run at once against one
+// host and within one subnet, and how many packets per second all scans
+// together may send. Zero disables a limit.
+type Limits struct {
+	PerHost      int      `yaml:"per_host" toml:"per_host" env:"LIMIT_PER_HOST" flag:"limit-per-host" usage:"concurrent scans of one host (0 = unlimited)"`
+	PerSubnet    int      `yaml:"per_subnet" toml:"per_subnet" env:"LIMIT_PER_SUBNET" flag:"limit-per-subnet" usage:"concurrent scans within one subnet (0 = unlimited)"`
+	SubnetBitsV4 int      `yaml:"subnet_bits_v4" toml:"subnet_bits_v4" env:"LIMIT_SUBNET_BITS_V4" flag:"limit-subnet-bits-v4" usage:"IPv4 prefix length that makes one subnet"`
+	SubnetBitsV6 int      `yaml:"subnet_bits_v6" toml:"subnet_bits_v6" env:"LIMIT_SUBNET_BITS_V6" flag:"limit-subnet-bits-v6" usage:"IPv6 prefix length that makes one subnet"`
+	MaxPPS       int      `yaml:"max_pps" toml:"max_pps" env:"LIMIT_MAX_PPS" flag:"limit-max-pps" usage:"packets per second shared by all running scans, passed to nmap as --max-rate (0 = unlimited)"`
+	LockTTL      Duration `yaml:"lock_ttl" toml:"lock_ttl" env:"LIMIT_LOCK_TTL" flag:"limit-lock-ttl" usage:"free a slot whose scan stopped renewing it for this long"`
+	DeferDelay   Duration `yaml:"defer_delay" toml:"defer_delay" env:"LIMIT_DEFER_DELAY" flag:"limit-defer-delay" usage:"how long a job whose target is busy waits before it is tried again"`
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.PerHost > 0 || l.PerSubnet > 0 || l.MaxPPS > 0
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Listen:         ":8080",
+			ShutdownGrace:  Duration(30 * time.Second),
+			IdempotencyTTL: Duration(24 * time.Hour),
+		},
+		Database: Database{
+			Driver:       DriverPostgres,
+			MaxOpenConns: 25,
+			MaxIdleConns: 5,
+			WriteBatch:   100,
+			WriteBuffer:  1000,
+		},
+		Redis: Redis{
+			Addr: "redis:6379",
+		},
+		Queue: Queue{
+			Driver: QueueRedis,
+		},
+		Worker: Worker{
+			Count:     5,
+			BatchSize: 1,
+		},
+		Nmap: Nmap{
+			Binary:     "nmap",
+			Args:       []string{"-Pn", "-sT", "--max-retries", "2"},
+			MaxRetries: 3,
+		},
+		Retention: Retention{
+			HostStaleAfter: Duration(7 * 24 * time.Hour),
+			SweepInterval:  Duration(time.Hour),
+			PruneInterval:  Duration(time.Hour),
+			PruneBatch:     1000,
+		},
+		Telemetry: Telemetry{
+			OTLPEndpoint:     "otel-collector:4318",
+			MetricsExporters: []string{"otlp"},
+		},
+		Agents: Agents{
+			LeaseTimeout:      Duration(2 * time.Minute),
+			OfflineAfter:      Duration(time.Minute),
+			Capacity:          2,
+			PollWait:          Duration(25 * time.Second),
+			HeartbeatInterval: Duration(15 * time.Second),
+		},
+		Limits: Limits{
+			PerHost:      1,
+			PerSubnet:    4,
+			SubnetBitsV4: 24,
+			SubnetBitsV6: 64,
+			LockTTL:      Duration(5 * time.Minute),
+			DeferDelay:   Duration(30 * time.Second),
+		},
+	}
+}
+
+var ErrInvalid = errors.New("invalid configuration")
+
+// Validate reports every problem at once.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Listen != "", "server.listen is required")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "server.tls_cert_file and server.tls_key_file must be set together")
+	check(c.Server.ShutdownGrace >= 0, "server.shutdown_grace must not be negative")
+	check(c.Server.IdempotencyTTL > 0, "server.idempotency_ttl must be positive")
+
+	check(c.Database.Driver == DriverPostgres || c.Database.Driver == DriverMemory,
+		"database.driver must be %s or %s, got %q", DriverPostgres, DriverMemory, c.Database.Driver)
+	check(c.Database.Driver != DriverPostgres || c.Database.DSN != "", "database.dsn is required (DB_DSN)")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns (%d) exceeds max_open_conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.WriteBatch >= 1, "database.write_batch must be at least 1")
+	check(c.Database.WriteBuffer >= 0, "database.write_buffer must not be negative")
+
+	check(c.Queue.Driver == QueueRedis || c.Queue.Driver == QueueMemory,
+		"queue.driver must be %s or %s, got %q", QueueRedis, QueueMemory, c.Queue.Driver)
+	check(c.Queue.WAL == "" || c.Queue.Driver == QueueMemory, "queue.wal needs queue.driver %s", QueueMemory)
+	check(c.Queue.Driver != QueueRedis || c.Redis.Addr != "", "redis.addr is required")
+	check(c.Redis.DB >= 0, "redis.db must not be negative")
+	check(!c.Redis.TLSSkipVerify || c.Redis.TLS, "redis.tls_skip_verify needs redis.tls")
+
+	check(c.Worker.Count >= 1, "worker.count must be at least 1")
+	check(c.Worker.BatchSize >= 1, "worker.batch_size must be at least 1")
+
+	check(c.Nmap.Binary != "", "nmap.binary is required")
+	check(c.Nmap.Timeout >= 0, "nmap.timeout must not be negative")
+	check(c.Nmap.MaxRetries >= 1, "nmap.max_retries must be at least 1")
+	for _, a := range c.Nmap.Args {
+		check(a != "-oX" && a != "-oA" && a != "-oN" && a != "-oG",
+			"nmap.args must not set an output format (%s); XML on stdout is added automatically", a)
+	}
+
+	check(c.Retention.HostStaleAfter > 0, "retention.host_stale_after must be positive")
+	check(c.Retention.SweepInterval > 0, "retention.sweep_interval must be positive")
+	check(c.Retention.ResultsFullDetail >= 0, "retention.results_full_detail must not be negative")
+	for owner, d := range c.Retention.ResultsFullDetailByOwner {
+		check(owner != "", "retention.results_full_detail_by_owner: owner must not be empty")
+		check(d >= 0, "retention.results_full_detail_by_owner: %s must not be negative", owner)
+	}
+	check(c.Retention.PruneInterval > 0, "retention.prune_interval must be positive")
+	check(c.Retention.PruneBatch >= 1, "retention.prune_batch must be at least 1")
+
+	for _, e := range c.Telemetry.MetricsExporters {
+		switch strings.TrimSpace(e) {
+		case "otlp", "prometheus", "none":
+		default:
+			problems = append(problems, fmt.Sprintf("telemetry.metrics_exporters: unknown exporter %q", e))
+		}
+	}
+
+	check(c.Agents.LeaseTimeout > 0, "agents.lease_timeout must be positive")
+	check(c.Agents.OfflineAfter > 0, "agents.offline_after must be positive")
+
+	check(c.Limits.PerHost >= 0, "limits.per_host must not be negative")
+	check(c.Limits.PerSubnet >= 0, "limits.per_subnet must not be negative")
+	check(c.Limits.SubnetBitsV4 >= 1 && c.Limits.SubnetBitsV4 <= 32, "limits.subnet_bits_v4 must be between 1 and 32")
+	check(c.Limits.SubnetBitsV6 >= 1 && c.Limits.SubnetBitsV6 <= 128, "limits.subnet_bits_v6 must be between 1 and 128")
+	check(c.Limits.MaxPPS >= 0, "limits.max_pps must not be negative")
+	check(c.Limits.LockTTL > 0, "limits.lock_ttl must be positive")
+	check(c.Limits.DeferDelay > 0, "limits.defer_delay must be positive")
+	if c.Limits.MaxPPS > 0 {
+		for _, a := range c.Nmap.Args {
+			check(a != "--max-rate", "nmap.args must not set --max-rate when limits.max_pps is set")
+		}
+	}
+
+	return invalid(problems)
+}
+
+// ValidateAgent checks the settings agent mode uses. An agent talks only to
+// the API, so it needs no database or Redis.
+func (c Config) ValidateAgent() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Agents.ServerURL != "", "agents.agent_server_url is required (AGENT_SERVER_URL)")
+	check(c.Agents.EnrollmentToken != "", "agents.enrollment_token is required (AGENT_ENROLLMENT_TOKEN)")
+	check(c.Agents.Capacity >= 1, "agents.agent_capacity must be at least 1")
+	check(c.Agents.PollWait > 0, "agents.agent_poll_wait must be positive")
+	check(c.Agents.HeartbeatInterval > 0, "agents.agent_heartbeat_interval must be positive")
+	check(c.Nmap.Binary != "", "nmap.binary is required")
+	check(c.Nmap.MaxRetries >= 1, "nmap.max_retries must be at least 1")
+
+	return invalid(problems)
+}
+
+func invalid(problems []string) error {
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n  %s", ErrInvalid, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as "30s" or "168h" in files, env and
+// flags.
+type Duration time.Duration
+
+func (d Duration) D() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// OwnerDurations maps inventory owners to durations. Files give it as a
+// table; the environment and flags as "owner=duration" pairs separated by
+// commas.
+type OwnerDurations map[string]Duration
+
+func (o *OwnerDurations) UnmarshalText(b []byte) error {
+	m := OwnerDurations{}
+	for _, pair := range strings.Split(string(b), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		owner, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not owner=duration", pair)
+		}
+		var d Duration
+		if err := d.UnmarshalText([]byte(strings.TrimSpace(v))); err != nil {
+			return err
+		}
+		m[strings.TrimSpace(owner)] = d
+	}
+	*o = m
+	return nil
+}
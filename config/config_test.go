@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "nmap-api.yaml", `
+server:
+  listen: ":9000"
+  shutdown_grace: 10s
+worker:
+  count: 8
+redis:
+  addr: file-redis:6379
+nmap:
+  args: ["-Pn", "-sS"]
+`)
+	t.Setenv("WORKER_COUNT", "12")
+	t.Setenv("DB_DSN", "postgres://app:s3cret@db:5432/nmapdb?sslmode=disable")
+
+	cfg, rest, err := Load("test", []string{"--config", path, "--workers", "20", "--nmap-args", "-Pn -sT -T4", "extra"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"extra"}, rest)
+	assert.Equal(t, ":9000", cfg.Server.Listen)                   // file over default
+	assert.Equal(t, 10*time.Second, cfg.Server.ShutdownGrace.D()) // file duration
+	assert.Equal(t, "file-redis:6379", cfg.Redis.Addr)
+	assert.Equal(t, 20, cfg.Worker.Count)                             // flag over env over file
+	assert.Equal(t, []string{"-Pn", "-sT", "-T4"}, cfg.Nmap.Args)     // flag, space separated
+	assert.Equal(t, "nmap", cfg.Nmap.Binary)                          // default kept
+	assert.Equal(t, 7*24*time.Hour, cfg.Retention.HostStaleAfter.D()) // default kept
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "nmap-api.toml", `
+[database]
+dsn = "host=db user=app password=s3cret dbname=nmapdb"
+max_open_conns = 10
+max_idle_conns = 2
+
+[telemetry]
+metrics_exporters = ["prometheus"]
+`)
+	t.Setenv(FileEnv, path)
+
+	cfg, _, err := Load("test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.Database.MaxOpenConns)
+	assert.Equal(t, []string{"prometheus"}, cfg.Telemetry.MetricsExporters)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_RejectsUnknownKeys(t *testing.T) {
+	path := writeFile(t, "nmap-api.yaml", "worker:\n  cuont: 3\n")
+	_, _, err := Load("test", []string{"--config", path})
+	assert.Error(t, err)
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Worker.Count = 0
+	cfg.Server.TLSCertFile = "cert.pem"
+	cfg.Database.MaxOpenConns = 2
+	cfg.Database.MaxIdleConns = 5
+	cfg.Nmap.Args = []string{"-oX", "out.xml"}
+
+	err := cfg.Validate()
+	require.True(t, errors.Is(err, ErrInvalid))
+	for _, want := range []string{"database.dsn", "worker.count", "tls_key_file", "max_idle_conns", "-oX"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "postgres://app:s3cret@db:5432/nmapdb?sslmode=disable"
+	cfg.Redis.Password = "hunter2"
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+	out := buf.String()
+	assert.NotContains(t, out, "s3cret")
+	assert.NotContains(t, out, "hunter2")
+	assert.Contains(t, out, "postgres://app:REDACTED@db:5432/nmapdb?sslmode=disable")
+	assert.Contains(t, out, "shutdown_grace: 30s")
+	assert.Equal(t, "hunter2", cfg.Redis.Password, "redaction must not modify the original")
+
+	assert.Equal(t, "host=db password=REDACTED dbname=x", redactDSN("host=db password=s3cret dbname=x"))
+	assert.Equal(t, "postgres://db/x?password=REDACTED", redactDSN("postgres://db/x?password=s3cret"))
+}
+
+func TestLoad_RetentionByOwner(t *testing.T) {
+	yamlPath := writeFile(t, "nmap-api.yaml", `
+retention:
+  results_full_detail: 2160h
+  results_full_detail_by_owner:
+    team-a: 720h
+    legal: 0s
+`)
+	tomlPath := writeFile(t, "nmap-api.toml", `
+[retention.results_full_detail_by_owner]
+team-a = "720h"
+legal = "0s"
+`)
+	want := OwnerDurations{"team-a": Duration(30 * 24 * time.Hour), "legal": 0}
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg, _, err := Load("test", []string{"--config", path})
+		require.NoError(t, err, path)
+		assert.Equal(t, want, cfg.Retention.ResultsFullDetailByOwner, path)
+	}
+
+	t.Setenv("RESULTS_FULL_DETAIL_BY_OWNER", "team-a=720h, legal=0s")
+	cfg, _, err := Load("test", []string{"--config", yamlPath})
+	require.NoError(t, err)
+	assert.Equal(t, want, cfg.Retention.ResultsFullDetailByOwner)
+	assert.Equal(t, 90*24*time.Hour, cfg.Retention.FullDetailFor("ops"))
+	assert.Equal(t, 30*24*time.Hour, cfg.Retention.FullDetailFor("team-a"))
+	assert.Zero(t, cfg.Retention.FullDetailFor("legal"), "an override of 0 keeps everything")
+	assert.True(t, cfg.Retention.PrunesResults())
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+	assert.Contains(t, buf.String(), "team-a: 720h0m0s")
+
+	t.Setenv("RESULTS_FULL_DETAIL_BY_OWNER", "team-a")
+	_, _, err = Load("test", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that points at the config file when
+// --config is not given.
+const FileEnv = "CONFIG_FILE"
+
+// Load builds the configuration from defaults, the config file, the
+// environment and args (flags), later sources overriding earlier ones. It
+// returns the arguments left after the flags. The result is not validated;
+// call Validate.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(FileEnv), "YAML (.yaml, .yml) or TOML (.toml) config file")
+	set := map[string]string{}
+	var order []string
+	for _, f := range fields(&cfg) {
+		if f.flag == "" {
+			continue
+		}
+		fs.Func(f.flag, f.usage, func(v string) error {
+			if _, seen := set[f.flag]; !seen {
+				order = append(order, f.flag)
+			}
+			set[f.flag] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(&cfg, *path); err != nil {
+			return cfg, nil, err
+		}
+	}
+	for _, f := range fields(&cfg) {
+		if v, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := f.set(v); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+	byFlag := map[string]field{}
+	for _, f := range fields(&cfg) {
+		byFlag[f.flag] = f
+	}
+	for _, name := range order {
+		if err := byFlag[name].set(set[name]); err != nil {
+			return cfg, nil, fmt.Errorf("--%s: %w", name, err)
+		}
+	}
+	return cfg, fs.Args(), nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported config format, use .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// field is one leaf setting reachable through the struct tags.
+type field struct {
+	env, flag, usage, secret, split string
+	v                               reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf, fv := t.Field(i), v.Field(i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(fv)
+				continue
+			}
+			out = append(out, field{
+				env:    sf.Tag.Get("env"),
+				flag:   sf.Tag.Get("flag"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret"),
+				split:  sf.Tag.Get("split"),
+				v:      fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+// set parses s into the field: text unmarshalers (Duration), strings, ints,
+// bools and string lists (comma separated, or space separated with
+// split:"space").
+func (f field) set(s string) error {
+	if u, ok := f.v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch f.v.Kind() {
+	case reflect.String:
+		f.v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case reflect.Slice:
+		var parts []string
+		if f.split == "space" {
+			parts = strings.Fields(s)
+		} else {
+			for _, p := range strings.Split(s, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					parts = append(parts, p)
+				}
+			}
+		}
+		f.v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.v.Type())
+	}
+	return nil
+}
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy safe to print: secrets are replaced and passwords
+// inside connection strings are masked.
+func (c Config) Redacted() Config {
+	out := c
+	out.Nmap.Args = append([]string(nil), c.Nmap.Args...)
+	out.Telemetry.MetricsExporters = append([]string(nil), c.Telemetry.MetricsExporters...)
+	for _, f := range fields(&out) {
+		switch {
+		case f.secret == "dsn":
+			f.v.SetString(redactDSN(f.v.String()))
+		case f.secret != "" && f.v.Kind() == reflect.String && f.v.String() != "":
+			f.v.SetString(redacted)
+		}
+	}
+	return out
+}
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// redactDSN masks the password of a URL ("postgres://u:p@h/db") or key/value
+// ("host=h password=p") connection string.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", redacted)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Print writes the configuration as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
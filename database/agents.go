@@ -0,0 +1,135 @@
+package databse
+
+import (
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+var (
+	CreateZone    = createZone
+	ReplaceZone   = replaceZone
+	GetZone       = getZone
+	ListZones     = listZones
+	DeleteZone    = deleteZone
+	RegisterAgent = registerAgent
+	GetAgentByKey = getAgentByKey
+	TouchAgent    = touchAgent
+	ListAgents    = listAgents
+	DeleteAgent   = deleteAgent
+)
+
+const (
+	zoneColumns  = `name, cidrs, description, created_at, updated_at`
+	agentColumns = `id, name, zone, version, capacity, running, remote_addr, registered_at, last_seen`
+)
+
+// createZone returns ErrConflict if a zone with the same name exists.
+func createZone(z models.Zone) error {
+	_, err := DB.Exec(`INSERT INTO zones (name, cidrs, description) VALUES ($1, $2, $3)`,
+		z.Name, pq.Array(nonNil(z.CIDRs)), z.Description)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// replaceZone returns sql.ErrNoRows if the zone does not exist.
+func replaceZone(z models.Zone) error {
+	return affectedOne(DB.Exec(`UPDATE zones SET cidrs = $2, description = $3, updated_at = now() WHERE name = $1`,
+		z.Name, pq.Array(nonNil(z.CIDRs)), z.Description))
+}
+
+func getZone(name string) (models.Zone, error) {
+	return scanZone(DB.QueryRow(`SELECT `+zoneColumns+` FROM zones WHERE name = $1`, name))
+}
+
+func listZones() ([]models.Zone, error) {
+	rows, err := DB.Query(`SELECT ` + zoneColumns + ` FROM zones ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []models.Zone{}
+	for rows.Next() {
+		z, err := scanZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+func deleteZone(name string) error {
+	return affectedOne(DB.Exec(`DELETE FROM zones WHERE name = $1`, name))
+}
+
+func scanZone(row rowScanner) (models.Zone, error) {
+	var z models.Zone
+	err := row.Scan(&z.Name, pq.Array(&z.CIDRs), &z.Description, &z.CreatedAt, &z.UpdatedAt)
+	return z, err
+}
+
+// registerAgent creates the agent, or re-registers an existing name with a
+// new token, and returns its id.
+func registerAgent(reg models.AgentRegistration, tokenHash, remoteAddr string) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		INSERT INTO agents (name, zone, version, capacity, token_hash, remote_addr)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			zone = EXCLUDED.zone, version = EXCLUDED.version, capacity = EXCLUDED.capacity,
+			token_hash = EXCLUDED.token_hash, remote_addr = EXCLUDED.remote_addr,
+			running = 0, registered_at = now(), last_seen = now()
+		RETURNING id
+	`, reg.Name, reg.Zone, reg.Version, reg.Capacity, tokenHash, remoteAddr).Scan(&id)
+	return id, err
+}
+
+// getAgentByKey looks an agent up by the hash of its token; sql.ErrNoRows
+// means the token is unknown or was replaced.
+func getAgentByKey(tokenHash string) (models.Agent, error) {
+	return scanAgent(DB.QueryRow(`SELECT `+agentColumns+` FROM agents WHERE token_hash = $1`, tokenHash))
+}
+
+// touchAgent records that the agent was heard from. A nil heartbeat only
+// bumps last_seen.
+func touchAgent(id int64, hb *models.AgentHeartbeat, remoteAddr string) error {
+	if hb == nil {
+		return affectedOne(DB.Exec(`UPDATE agents SET last_seen = now(), remote_addr = $2 WHERE id = $1`, id, remoteAddr))
+	}
+	return affectedOne(DB.Exec(`
+		UPDATE agents SET last_seen = now(), remote_addr = $2, version = $3, capacity = $4, running = $5
+		WHERE id = $1
+	`, id, remoteAddr, hb.Version, hb.Capacity, hb.Running))
+}
+
+func listAgents() ([]models.Agent, error) {
+	rows, err := DB.Query(`SELECT ` + agentColumns + ` FROM agents ORDER BY zone, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []models.Agent{}
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+func deleteAgent(id int64) error {
+	return affectedOne(DB.Exec(`DELETE FROM agents WHERE id = $1`, id))
+}
+
+func scanAgent(row rowScanner) (models.Agent, error) {
+	var a models.Agent
+	err := row.Scan(&a.ID, &a.Name, &a.Zone, &a.Version, &a.Capacity, &a.Running, &a.RemoteAddr, &a.RegisteredAt, &a.LastSeen)
+	return a, err
+}
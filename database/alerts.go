@@ -0,0 +1,263 @@
+package databse
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+var (
+	CreateAlertRule   = createAlertRule
+	ReplaceAlertRule  = replaceAlertRule
+	GetAlertRule      = getAlertRule
+	ListAlertRules    = listAlertRules
+	DeleteAlertRule   = deleteAlertRule
+	RecordAlert       = recordAlert
+	MarkAlertNotified = markAlertNotified
+	ListAlerts        = listAlerts
+	CreateSilence     = createSilence
+	ListSilences      = listSilences
+	DeleteSilence     = deleteSilence
+	CreateChannel     = createChannel
+	ListChannels      = listChannels
+	DeleteChannel     = deleteChannel
+)
+
+const alertRuleColumns = `id, name, condition, ports, threshold, group_name, severity,
+	dedup_window_seconds, channels, disabled, created_at, updated_at`
+
+// createAlertRule returns ErrConflict if the rule name is taken.
+func createAlertRule(r models.AlertRule) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		INSERT INTO alert_rules (name, condition, ports, threshold, group_name, severity, dedup_window_seconds, channels, disabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, r.Name, r.Condition, pq.Array(intsNonNil(r.Ports)), r.Threshold, r.Group, r.Severity,
+		r.DedupWindowSeconds, pq.Array(nonNil(r.Channels)), r.Disabled).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, ErrConflict
+	}
+	return id, err
+}
+
+func replaceAlertRule(r models.AlertRule) error {
+	res, err := DB.Exec(`
+		UPDATE alert_rules SET name = $2, condition = $3, ports = $4, threshold = $5, group_name = $6,
+			severity = $7, dedup_window_seconds = $8, channels = $9, disabled = $10, updated_at = now()
+		WHERE id = $1
+	`, r.ID, r.Name, r.Condition, pq.Array(intsNonNil(r.Ports)), r.Threshold, r.Group, r.Severity,
+		r.DedupWindowSeconds, pq.Array(nonNil(r.Channels)), r.Disabled)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return affectedOne(res, err)
+}
+
+func getAlertRule(id int64) (models.AlertRule, error) {
+	return scanAlertRule(DB.QueryRow(`SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1`, id))
+}
+
+func listAlertRules() ([]models.AlertRule, error) {
+	rows, err := DB.Query(`SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func deleteAlertRule(id int64) error {
+	return affectedOne(DB.Exec(`DELETE FROM alert_rules WHERE id = $1`, id))
+}
+
+func scanAlertRule(row rowScanner) (models.AlertRule, error) {
+	var (
+		r     models.AlertRule
+		ports pq.Int64Array
+	)
+	err := row.Scan(&r.ID, &r.Name, &r.Condition, &ports, &r.Threshold, &r.Group, &r.Severity,
+		&r.DedupWindowSeconds, pq.Array(&r.Channels), &r.Disabled, &r.CreatedAt, &r.UpdatedAt)
+	r.Ports = ints(ports)
+	return r, err
+}
+
+// recordAlert stores a fired alert unless one with the same fingerprint fired
+// within window, in which case that alert's count and evidence are updated.
+// The returned bool is true when a new alert was created.
+func recordAlert(a models.Alert, window time.Duration) (models.Alert, bool, error) {
+	err := DB.QueryRow(`
+		UPDATE alerts SET count = count + 1, last_fired_at = $2, scan_id = $3, message = $4
+		WHERE id = (
+			SELECT id FROM alerts WHERE fingerprint = $1 AND fired_at >= $5
+			ORDER BY fired_at DESC LIMIT 1
+		)
+		RETURNING id, fired_at, count, silenced
+	`, a.Fingerprint, a.FiredAt, a.ScanID, a.Message, a.FiredAt.Add(-window)).
+		Scan(&a.ID, &a.FiredAt, &a.Count, &a.Silenced)
+	if err == nil {
+		return a, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return a, false, err
+	}
+
+	a.LastFiredAt, a.Count = a.FiredAt, 1
+	err = DB.QueryRow(`
+		INSERT INTO alerts (rule_id, rule_name, severity, host, scan_id, message, ports, fingerprint, fired_at, last_fired_at, silenced)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9, $10)
+		RETURNING id
+	`, a.RuleID, a.RuleName, a.Severity, a.Host, a.ScanID, a.Message, pq.Array(intsNonNil(a.Ports)),
+		a.Fingerprint, a.FiredAt, a.Silenced).Scan(&a.ID)
+	return a, err == nil, err
+}
+
+func markAlertNotified(id int64, notifyErr string) error {
+	_, err := DB.Exec(`UPDATE alerts SET notified_at = now(), notify_error = $2 WHERE id = $1`, id, notifyErr)
+	return err
+}
+
+func listAlerts(f models.AlertFilter) ([]models.Alert, error) {
+	var (
+		args  []interface{}
+		conds []string
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.RuleID > 0 {
+		conds = append(conds, "rule_id = "+arg(f.RuleID))
+	}
+	if f.Host != "" {
+		conds = append(conds, "host = "+arg(f.Host))
+	}
+	if f.Severity != "" {
+		conds = append(conds, "severity = "+arg(f.Severity))
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "last_fired_at >= "+arg(f.Since))
+	}
+
+	query := `SELECT id, rule_id, rule_name, severity, host, scan_id::text, message, ports, fingerprint,
+		fired_at, last_fired_at, count, silenced, notified_at, notify_error FROM alerts`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY last_fired_at DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET " + arg(f.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		var (
+			a        models.Alert
+			ports    pq.Int64Array
+			notified sql.NullTime
+		)
+		if err := rows.Scan(&a.ID, &a.RuleID, &a.RuleName, &a.Severity, &a.Host, &a.ScanID, &a.Message, &ports,
+			&a.Fingerprint, &a.FiredAt, &a.LastFiredAt, &a.Count, &a.Silenced, &notified, &a.NotifyError); err != nil {
+			return nil, err
+		}
+		a.Ports = ints(ports)
+		if notified.Valid {
+			a.NotifiedAt = &notified.Time
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func createSilence(s models.AlertSilence) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		INSERT INTO alert_silences (rule_id, host, until, reason) VALUES ($1, $2, $3, $4) RETURNING id
+	`, s.RuleID, s.Host, s.Until, s.Reason).Scan(&id)
+	return id, err
+}
+
+// listSilences returns silences still in effect at now, or all of them if now is zero.
+func listSilences(now time.Time) ([]models.AlertSilence, error) {
+	query := `SELECT id, rule_id, host, until, reason, created_at FROM alert_silences`
+	var args []interface{}
+	if !now.IsZero() {
+		query += ` WHERE until > $1`
+		args = append(args, now)
+	}
+	rows, err := DB.Query(query+` ORDER BY until DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	silences := []models.AlertSilence{}
+	for rows.Next() {
+		var s models.AlertSilence
+		if err := rows.Scan(&s.ID, &s.RuleID, &s.Host, &s.Until, &s.Reason, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		silences = append(silences, s)
+	}
+	return silences, rows.Err()
+}
+
+func deleteSilence(id int64) error {
+	return affectedOne(DB.Exec(`DELETE FROM alert_silences WHERE id = $1`, id))
+}
+
+// createChannel returns ErrConflict if the channel name is taken.
+func createChannel(ch models.AlertChannel) error {
+	_, err := DB.Exec(`INSERT INTO alert_channels (name, type, config) VALUES ($1, $2, $3)`,
+		ch.Name, ch.Type, string(ch.Config))
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func listChannels() ([]models.AlertChannel, error) {
+	rows, err := DB.Query(`SELECT name, type, config, created_at FROM alert_channels ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []models.AlertChannel{}
+	for rows.Next() {
+		var ch models.AlertChannel
+		if err := rows.Scan(&ch.Name, &ch.Type, &ch.Config, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+func deleteChannel(name string) error {
+	return affectedOne(DB.Exec(`DELETE FROM alert_channels WHERE name = $1`, name))
+}
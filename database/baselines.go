@@ -0,0 +1,228 @@
+package databse
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+var (
+	CreateBaseline     = createBaseline
+	ReplaceBaseline    = replaceBaseline
+	GetBaseline        = getBaseline
+	ListBaselines      = listBaselines
+	DeleteBaseline     = deleteBaseline
+	RecordViolations   = recordViolations
+	ListViolations     = listViolations
+	GetViolation       = getViolation
+	SetViolationStatus = setViolationStatus
+)
+
+const baselineColumns = `id, scope, target, ports, description, created_at, updated_at`
+
+// createBaseline returns ErrConflict if the scope/target already has a baseline.
+func createBaseline(b models.Baseline) (int64, error) {
+	ports, err := json.Marshal(b.Ports)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = DB.QueryRow(`
+		INSERT INTO baselines (scope, target, ports, description) VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, b.Scope, b.Target, string(ports), b.Description).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, ErrConflict
+	}
+	return id, err
+}
+
+func replaceBaseline(b models.Baseline) error {
+	ports, err := json.Marshal(b.Ports)
+	if err != nil {
+		return err
+	}
+	return affectedOne(DB.Exec(`
+		UPDATE baselines SET ports = $2, description = $3, updated_at = now() WHERE id = $1
+	`, b.ID, string(ports), b.Description))
+}
+
+func getBaseline(id int64) (models.Baseline, error) {
+	return scanBaseline(DB.QueryRow(`SELECT `+baselineColumns+` FROM baselines WHERE id = $1`, id))
+}
+
+func listBaselines() ([]models.Baseline, error) {
+	rows, err := DB.Query(`SELECT ` + baselineColumns + ` FROM baselines ORDER BY scope, target`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	baselines := []models.Baseline{}
+	for rows.Next() {
+		b, err := scanBaseline(rows)
+		if err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+func deleteBaseline(id int64) error {
+	return affectedOne(DB.Exec(`DELETE FROM baselines WHERE id = $1`, id))
+}
+
+func scanBaseline(row rowScanner) (models.Baseline, error) {
+	var (
+		b     models.Baseline
+		ports []byte
+	)
+	if err := row.Scan(&b.ID, &b.Scope, &b.Target, &ports, &b.Description, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return b, err
+	}
+	err := json.Unmarshal(ports, &b.Ports)
+	return b, err
+}
+
+// recordViolations makes the unresolved violations of a baseline/host pair
+// match found: new ones are inserted, recurring ones get the latest evidence
+// and the rest are resolved. It returns the violations opened by this call.
+func recordViolations(baselineID int64, host, scanID string, seenAt time.Time, found []models.Violation) ([]models.Violation, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		ids    []int64
+		opened []models.Violation
+	)
+	for _, v := range found {
+		var (
+			id       int64
+			inserted bool
+		)
+		err := tx.QueryRow(`
+			INSERT INTO violations (baseline_id, host, kind, port, protocol, detail, scan_id, first_seen, last_seen)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+			ON CONFLICT (baseline_id, host, kind, port, protocol) WHERE status <> 'resolved'
+			DO UPDATE SET scan_id = EXCLUDED.scan_id, detail = EXCLUDED.detail,
+				last_seen = GREATEST(violations.last_seen, EXCLUDED.last_seen)
+			RETURNING id, (xmax = 0)
+		`, baselineID, host, v.Kind, v.Port, v.Protocol, v.Detail, scanID, seenAt).Scan(&id, &inserted)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		if inserted {
+			v.ID, v.BaselineID, v.Host, v.ScanID = id, baselineID, host, scanID
+			v.Status, v.FirstSeen, v.LastSeen = models.ViolationOpen, seenAt, seenAt
+			opened = append(opened, v)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE violations SET status = 'resolved', resolved_at = $3, note = 'cleared by scan ' || $4
+		WHERE baseline_id = $1 AND host = $2 AND status <> 'resolved' AND NOT (id = ANY($5))
+	`, baselineID, host, seenAt, scanID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return opened, tx.Commit()
+}
+
+const violationColumns = `id, baseline_id, host, kind, port, protocol, detail, status, scan_id::text,
+	first_seen, last_seen, acked_by, acked_at, resolved_at, note`
+
+func listViolations(f models.ViolationFilter) ([]models.Violation, error) {
+	var (
+		args  []interface{}
+		conds []string
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Host != "" {
+		conds = append(conds, "host = "+arg(f.Host))
+	}
+	if f.Status != "" {
+		conds = append(conds, "status = "+arg(f.Status))
+	}
+	if f.Kind != "" {
+		conds = append(conds, "kind = "+arg(f.Kind))
+	}
+	if f.BaselineID > 0 {
+		conds = append(conds, "baseline_id = "+arg(f.BaselineID))
+	}
+
+	query := `SELECT ` + violationColumns + ` FROM violations`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY last_seen DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET " + arg(f.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := []models.Violation{}
+	for rows.Next() {
+		v, err := scanViolation(rows)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+func getViolation(id int64) (models.Violation, error) {
+	return scanViolation(DB.QueryRow(`SELECT `+violationColumns+` FROM violations WHERE id = $1`, id))
+}
+
+// setViolationStatus acknowledges or resolves a violation. Resolved
+// violations are final; sql.ErrNoRows is returned for them too.
+func setViolationStatus(id int64, status string, a models.ViolationAction) error {
+	return affectedOne(DB.Exec(`
+		UPDATE violations SET
+			status      = $2,
+			acked_by    = CASE WHEN $2 = 'acknowledged' THEN $3 ELSE acked_by END,
+			acked_at    = CASE WHEN $2 = 'acknowledged' THEN now() ELSE acked_at END,
+			resolved_at = CASE WHEN $2 = 'resolved' THEN now() ELSE resolved_at END,
+			note        = CASE WHEN $4 <> '' THEN $4 ELSE note END
+		WHERE id = $1 AND status <> 'resolved'
+	`, id, status, a.By, a.Note))
+}
+
+func scanViolation(row rowScanner) (models.Violation, error) {
+	var (
+		v                 models.Violation
+		ackedAt, resolved sql.NullTime
+	)
+	err := row.Scan(&v.ID, &v.BaselineID, &v.Host, &v.Kind, &v.Port, &v.Protocol, &v.Detail, &v.Status,
+		&v.ScanID, &v.FirstSeen, &v.LastSeen, &v.AckedBy, &ackedAt, &resolved, &v.Note)
+	if ackedAt.Valid {
+		v.AckedAt = &ackedAt.Time
+	}
+	if resolved.Valid {
+		v.ResolvedAt = &resolved.Time
+	}
+	return v, err
+}
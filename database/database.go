@@ -2,31 +2,44 @@ package databse
 
 import (
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"log"
-	"strings"
 	"time"
 
+	"nmap-rest-api/config"
 	models "nmap-rest-api/models/v1"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-var (
-	DB              *sql.DB
-	SetScanStatus   = setScanStatus
-	StoreResult     = storeResult
-	GetScanStatuses = getScanStatuses
-)
+var DB *sql.DB
+
+// Open connects the configured backend and returns its repositories. The
+// memory driver needs no database; features without a repository then see
+// an empty store (see ErrNeedsPostgres).
+func Open(cfg config.Database) Repositories {
+	if cfg.Driver == config.DriverMemory {
+		log.Println("Storing scans in memory; they are lost on restart")
+		withoutPostgres()
+		return NewMemory()
+	}
+	InitDB(cfg)
+	return Postgres()
+}
 
-func InitDB(dsn string) {
+func InitDB(cfg config.Database) {
 	var err error
 
 	for i := 0; i < 10; i++ {
-		DB, err = sql.Open("postgres", dsn)
+		DB, err = sql.Open("postgres", cfg.DSN)
 		if err != nil {
 			log.Printf("Attempt %d: Failed to open DB: %v", i+1, err)
 		} else if err = DB.Ping(); err == nil {
+			DB.SetMaxOpenConns(cfg.MaxOpenConns)
+			DB.SetMaxIdleConns(cfg.MaxIdleConns)
+			DB.SetConnMaxLifetime(cfg.ConnMaxLifetime.D())
+			writes = newResultWriter(DB, cfg)
 			log.Println("Connected to Postgres")
 			return
 		} else {
@@ -38,14 +51,46 @@ func InitDB(dsn string) {
 	log.Fatalf("Could not connect to DB after retries: %v", err)
 }
 
-func storeResult(res models.ScanResult) error {
-	_, err := DB.Exec(`INSERT INTO scan_results (scan_id, host, scanned_at, open_ports) VALUES ($1, $2, $3, $4)`,
-		res.ScanID,
-		res.Host,
-		res.ScannedAt,
-		fmt.Sprintf("{%s}", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(res.OpenPorts)), ","), "[]")),
-	)
-	return err
+// storeResult stores res and finishes its host with status and reason in one
+// transaction, so a crash never leaves a stored result whose host still looks
+// in progress. Results are written through the batching writer once InitDB
+// started it.
+func storeResult(res models.ScanResult, status, reason string) error {
+	if writes != nil {
+		return writes.store(res, status, reason)
+	}
+	return inTx(DB, func(tx *sql.Tx) error { return insertResult(tx, res, status, reason) })
+}
+
+// portsJSON encodes port details for the ports JSONB column. An empty list is
+// stored as NULL so readers fall back to open_ports.
+func portsJSON(ports []models.PortInfo) (interface{}, error) {
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(ports)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Close writes the queued results, then closes the Postgres pool and the
+// Redis client.
+func Close() {
+	if writes != nil {
+		writes.close()
+	}
+	if DB != nil {
+		if err := DB.Close(); err != nil {
+			log.Printf("Failed to close Postgres: %v", err)
+		}
+	}
+	if RDB != nil {
+		if err := RDB.Close(); err != nil {
+			log.Printf("Failed to close Redis: %v", err)
+		}
+	}
 }
 
 func setScanStatus(scanID, host, status string) error {
@@ -65,8 +110,60 @@ func setScanStatus(scanID, host, status string) error {
 	return err
 }
 
+// finishScanHost sets a final status that needs an explanation, such as
+// skipped, partial or failed, with the reason.
+func finishScanHost(scanID, host, status, reason string) error {
+	_, err := DB.Exec(`
+		INSERT INTO scan_status (scan_id, host, status, reason, completed_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (scan_id, host)
+		DO UPDATE SET status = $3, reason = $4, completed_at = now()
+	`, scanID, host, status, reason)
+	return err
+}
+
+// findActiveJob returns the scan whose own job for host is pending or in
+// progress under the same not_before/not_after and ports, oldest first. ok is
+// false when there is none.
+func findActiveJob(host string, notBefore, notAfter *time.Time, ports string) (scanID string, ok bool, err error) {
+	err = DB.QueryRow(`
+		SELECT st.scan_id FROM scan_status st JOIN scans s ON s.scan_id = st.scan_id
+		WHERE st.host = $1 AND st.status IN ('pending', 'in_progress') AND st.attached_to IS NULL
+		  AND s.not_before IS NOT DISTINCT FROM $2 AND s.not_after IS NOT DISTINCT FROM $3 AND s.ports = $4
+		ORDER BY s.created_at LIMIT 1
+	`, host, notBefore, notAfter, ports).Scan(&scanID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	return scanID, err == nil, err
+}
+
+// attachScanHost records that scanID's host is covered by owner's job.
+func attachScanHost(scanID, host, owner string) error {
+	_, err := DB.Exec(`
+		INSERT INTO scan_status (scan_id, host, status, attached_to)
+		VALUES ($1, $2, 'pending', $3)
+		ON CONFLICT (scan_id, host) DO UPDATE SET attached_to = $3
+	`, scanID, host, owner)
+	return err
+}
+
+// setStatusTimes overwrites a host's start/completion times, e.g. with the
+// timestamps recorded in an imported nmap run.
+func setStatusTimes(scanID, host string, startedAt, completedAt time.Time) error {
+	return affectedOne(DB.Exec(`UPDATE scan_status SET started_at = $3, completed_at = $4 WHERE scan_id = $1 AND host = $2`,
+		scanID, host, startedAt, completedAt))
+}
+
+// getScanStatuses reports attached hosts with the status of the job they
+// attached to, and that job's scan as "attached_to".
 func getScanStatuses(scanID string) ([]map[string]string, error) {
-	rows, err := DB.Query(`SELECT host, status FROM scan_status WHERE scan_id = $1`, scanID)
+	rows, err := DB.Query(`
+		SELECT st.host, COALESCE(o.status, st.status), COALESCE(o.reason, st.reason), COALESCE(st.attached_to::text, '')
+		FROM scan_status st
+		LEFT JOIN scan_status o ON o.scan_id = st.attached_to AND o.host = st.host
+		WHERE st.scan_id = $1
+	`, scanID)
 	if err != nil {
 		return nil, err
 	}
@@ -74,13 +171,62 @@ func getScanStatuses(scanID string) ([]map[string]string, error) {
 
 	var statuses []map[string]string
 	for rows.Next() {
-		var host, status string
-		if err := rows.Scan(&host, &status); err == nil {
-			statuses = append(statuses, map[string]string{
+		var host, status, reason, attachedTo string
+		if err := rows.Scan(&host, &status, &reason, &attachedTo); err == nil {
+			s := map[string]string{
 				"host":   host,
 				"status": status,
-			})
+			}
+			if reason != "" {
+				s["reason"] = reason
+			}
+			if attachedTo != "" {
+				s["attached_to"] = attachedTo
+			}
+			statuses = append(statuses, s)
 		}
 	}
 	return statuses, nil
 }
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// affectedOne turns an Exec that touched no rows into sql.ErrNoRows.
+func affectedOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func ints(a pq.Int64Array) []int {
+	out := make([]int, 0, len(a))
+	for _, v := range a {
+		out = append(out, int(v))
+	}
+	return out
+}
+
+func intsNonNil(a []int) []int {
+	if a == nil {
+		return []int{}
+	}
+	return a
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
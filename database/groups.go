@@ -0,0 +1,171 @@
+package databse
+
+import (
+	"encoding/json"
+	"errors"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+var (
+	CreateGroup  = createGroup
+	ReplaceGroup = replaceGroup
+	GetGroup     = getGroup
+	ListGroups   = listGroups
+	DeleteGroup  = deleteGroup
+
+	ErrConflict = errors.New("already exists")
+)
+
+const groupColumns = `name, description, hosts, match, windows, created_at, updated_at`
+
+// createGroup returns ErrConflict if a group with the same name exists.
+func createGroup(g models.AssetGroup) error {
+	match, err := matchJSON(g.Match)
+	if err != nil {
+		return err
+	}
+	windows, err := windowsJSON(g.Windows)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`INSERT INTO asset_groups (name, description, hosts, match, windows) VALUES ($1, $2, $3, $4, $5)`,
+		g.Name, g.Description, pq.Array(nonNil(g.Hosts)), match, windows)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// replaceGroup returns sql.ErrNoRows if the group does not exist.
+func replaceGroup(g models.AssetGroup) error {
+	match, err := matchJSON(g.Match)
+	if err != nil {
+		return err
+	}
+	windows, err := windowsJSON(g.Windows)
+	if err != nil {
+		return err
+	}
+	return affectedOne(DB.Exec(`
+		UPDATE asset_groups SET description = $2, hosts = $3, match = $4, windows = $5, updated_at = now()
+		WHERE name = $1
+	`, g.Name, g.Description, pq.Array(nonNil(g.Hosts)), match, windows))
+}
+
+func getGroup(name string) (models.AssetGroup, error) {
+	return scanGroup(DB.QueryRow(`SELECT `+groupColumns+` FROM asset_groups WHERE name = $1`, name))
+}
+
+func listGroups() ([]models.AssetGroup, error) {
+	rows, err := DB.Query(`SELECT ` + groupColumns + ` FROM asset_groups ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.AssetGroup{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func deleteGroup(name string) error {
+	return affectedOne(DB.Exec(`DELETE FROM asset_groups WHERE name = $1`, name))
+}
+
+func scanGroup(row rowScanner) (models.AssetGroup, error) {
+	var (
+		g              models.AssetGroup
+		match, windows []byte
+	)
+	if err := row.Scan(&g.Name, &g.Description, pq.Array(&g.Hosts), &match, &windows, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return g, err
+	}
+	if err := json.Unmarshal(windows, &g.Windows); err != nil {
+		return g, err
+	}
+	if len(match) > 0 && string(match) != "null" {
+		g.Match = &models.GroupMatch{}
+		if err := json.Unmarshal(match, g.Match); err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+func matchJSON(m *models.GroupMatch) (interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func windowsJSON(w []models.MaintenanceWindow) (string, error) {
+	if w == nil {
+		w = []models.MaintenanceWindow{}
+	}
+	b, err := json.Marshal(w)
+	return string(b), err
+}
+
+// createScan stores the scan record written when a scan is queued or
+// imported. A zero CreatedAt means now, an empty Source "api" and an empty
+// Priority "normal".
+func createScan(s models.Scan) error {
+	groups, err := json.Marshal(s.Groups)
+	if err != nil {
+		return err
+	}
+	if s.Groups == nil {
+		groups = []byte("{}")
+	}
+	var createdAt interface{}
+	if !s.CreatedAt.IsZero() {
+		createdAt = s.CreatedAt
+	}
+	source := s.Source
+	if source == "" {
+		source = "api"
+	}
+	priority := s.Priority
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	_, err = DB.Exec(`
+		INSERT INTO scans (scan_id, requested_hosts, groups, hosts, created_at, source, priority, not_before, not_after, ports, shard_size)
+		VALUES ($1, $2, $3, $4, COALESCE($5, now()), $6, $7, $8, $9, $10, $11)
+	`, s.ScanID, pq.Array(nonNil(s.RequestedHosts)), string(groups), pq.Array(nonNil(s.Hosts)), createdAt, source, priority,
+		s.NotBefore, s.NotAfter, s.Ports, s.ShardSize)
+	return err
+}
+
+// getScan returns sql.ErrNoRows for unknown scans, including scans queued
+// before scan records existed.
+func getScan(scanID string) (models.Scan, error) {
+	var (
+		s      models.Scan
+		groups []byte
+	)
+	err := DB.QueryRow(`SELECT scan_id, created_at, requested_hosts, groups, hosts, source, priority, not_before, not_after,
+		ports, shard_size
+		FROM scans WHERE scan_id = $1`, scanID).
+		Scan(&s.ScanID, &s.CreatedAt, pq.Array(&s.RequestedHosts), &groups, pq.Array(&s.Hosts), &s.Source, &s.Priority,
+			&s.NotBefore, &s.NotAfter, &s.Ports, &s.ShardSize)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(groups, &s.Groups)
+	return s, err
+}
@@ -0,0 +1,188 @@
+package databse
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+const hostColumns = `host, first_seen, last_scanned, last_seen_up, COALESCE(last_scan_id::text, ''),
+	open_ports, ports, resolved_ips, tags, owner, metadata, lifecycle`
+
+// upsertHost folds a stored scan result into the hosts inventory. Results older
+// than the host's last scan (e.g. imports) only widen first_seen/last_seen_up.
+func upsertHost(res models.ScanResult) error {
+	ports, err := portsJSON(res.Ports)
+	if err != nil {
+		return err
+	}
+	var seenUp interface{}
+	if res.HostState == "up" || (res.HostState == "" && len(res.OpenPorts) > 0) {
+		seenUp = res.ScannedAt
+	}
+	openPorts := res.OpenPorts
+	if openPorts == nil {
+		openPorts = []int{}
+	}
+	addrs := res.Addresses
+	if addrs == nil {
+		addrs = []string{}
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO hosts (host, first_seen, last_scanned, last_seen_up, last_scan_id, open_ports, ports, resolved_ips)
+		VALUES ($1, $2, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (host) DO UPDATE SET
+			first_seen   = LEAST(hosts.first_seen, EXCLUDED.first_seen),
+			last_seen_up = GREATEST(hosts.last_seen_up, EXCLUDED.last_seen_up),
+			lifecycle    = CASE WHEN EXCLUDED.last_seen_up > COALESCE(hosts.last_seen_up, '-infinity')
+			                    THEN 'active' ELSE hosts.lifecycle END,
+			last_scanned = GREATEST(hosts.last_scanned, EXCLUDED.last_scanned),
+			last_scan_id = CASE WHEN EXCLUDED.last_scanned >= hosts.last_scanned THEN EXCLUDED.last_scan_id ELSE hosts.last_scan_id END,
+			open_ports   = CASE WHEN EXCLUDED.last_scanned >= hosts.last_scanned THEN EXCLUDED.open_ports ELSE hosts.open_ports END,
+			ports        = CASE WHEN EXCLUDED.last_scanned >= hosts.last_scanned THEN EXCLUDED.ports ELSE hosts.ports END,
+			resolved_ips = CASE WHEN EXCLUDED.last_scanned >= hosts.last_scanned AND cardinality(EXCLUDED.resolved_ips) > 0
+			                    THEN EXCLUDED.resolved_ips ELSE hosts.resolved_ips END
+	`, res.Host, res.ScannedAt, seenUp, res.ScanID, pq.Array(openPorts), ports, pq.Array(addrs))
+	return err
+}
+
+func listHosts(f models.HostFilter) ([]models.Host, error) {
+	var (
+		args  []interface{}
+		conds []string
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Tag != "" {
+		conds = append(conds, arg(f.Tag)+" = ANY(tags)")
+	}
+	if f.Owner != "" {
+		conds = append(conds, "owner = "+arg(f.Owner))
+	}
+	if f.Lifecycle != "" {
+		conds = append(conds, "lifecycle = "+arg(f.Lifecycle))
+	}
+	if f.Port > 0 {
+		conds = append(conds, arg(f.Port)+" = ANY(open_ports)")
+	}
+	if f.Search != "" {
+		conds = append(conds, "host ILIKE "+arg("%"+f.Search+"%"))
+	}
+
+	query := `SELECT ` + hostColumns + ` FROM hosts`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY host"
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET " + arg(f.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hosts := []models.Host{}
+	for rows.Next() {
+		h, err := scanHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// getHost returns sql.ErrNoRows when the host was never scanned.
+func getHost(host string) (models.Host, error) {
+	row := DB.QueryRow(`SELECT `+hostColumns+` FROM hosts WHERE host = $1`, host)
+	return scanHost(row)
+}
+
+func updateHostMeta(host string, upd models.HostUpdate) error {
+	var (
+		args = []interface{}{host}
+		sets []string
+	)
+	if upd.Tags != nil {
+		args = append(args, pq.Array(*upd.Tags))
+		sets = append(sets, fmt.Sprintf("tags = $%d", len(args)))
+	}
+	if upd.Owner != nil {
+		args = append(args, *upd.Owner)
+		sets = append(sets, fmt.Sprintf("owner = $%d", len(args)))
+	}
+	if upd.Metadata != nil {
+		b, err := json.Marshal(*upd.Metadata)
+		if err != nil {
+			return err
+		}
+		args = append(args, string(b))
+		sets = append(sets, fmt.Sprintf("metadata = $%d", len(args)))
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	return affectedOne(DB.Exec(`UPDATE hosts SET `+strings.Join(sets, ", ")+` WHERE host = $1`, args...))
+}
+
+// markStaleHosts flags active hosts that have not been seen up since before.
+func markStaleHosts(before time.Time) (int64, error) {
+	res, err := DB.Exec(`
+		UPDATE hosts SET lifecycle = 'stale'
+		WHERE lifecycle = 'active' AND COALESCE(last_seen_up, first_seen) < $1
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanHost(row rowScanner) (models.Host, error) {
+	var (
+		h           models.Host
+		lastSeenUp  sql.NullTime
+		openPorts   pq.Int64Array
+		ports, meta []byte
+	)
+	err := row.Scan(&h.Host, &h.FirstSeen, &h.LastScanned, &lastSeenUp, &h.LastScanID,
+		&openPorts, &ports, pq.Array(&h.ResolvedIPs), pq.Array(&h.Tags), &h.Owner, &meta, &h.Lifecycle)
+	if err != nil {
+		return h, err
+	}
+	if h.ResolvedIPs == nil {
+		h.ResolvedIPs = []string{}
+	}
+	if h.Tags == nil {
+		h.Tags = []string{}
+	}
+	if lastSeenUp.Valid {
+		h.LastSeenUp = &lastSeenUp.Time
+	}
+	h.OpenPorts = ints(openPorts)
+	if len(ports) > 0 {
+		if err := json.Unmarshal(ports, &h.Ports); err != nil {
+			return h, err
+		}
+	}
+	if err := json.Unmarshal(meta, &h.Metadata); err != nil {
+		return h, err
+	}
+	return h, nil
+}
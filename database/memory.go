@@ -0,0 +1,617 @@
+package databse
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+// memory keeps scans, statuses, results and hosts in process memory. It
+// implements every repository with the Postgres semantics, for dev, CI and
+// single-binary deployments; nothing survives a restart.
+type memory struct {
+	mu       sync.RWMutex
+	scans    map[string]models.Scan
+	statuses map[string][]*memStatus // by scan, in insertion order
+	results  []models.ScanResult
+	hosts    map[string]*models.Host
+}
+
+type memStatus struct {
+	host        string
+	status      string
+	reason      string
+	attachedTo  string
+	startedAt   *time.Time
+	completedAt *time.Time
+}
+
+// NewMemory returns empty repositories held in memory.
+func NewMemory() Repositories {
+	m := &memory{
+		scans:    map[string]models.Scan{},
+		statuses: map[string][]*memStatus{},
+		hosts:    map[string]*models.Host{},
+	}
+	return Repositories{Scans: m, Statuses: m, Results: m, Hosts: m}
+}
+
+func (m *memory) CreateScan(s models.Scan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.scans[s.ScanID]; ok {
+		return fmt.Errorf("scan %s: %w", s.ScanID, ErrConflict)
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	if s.Source == "" {
+		s.Source = "api"
+	}
+	if s.Priority == "" {
+		s.Priority = models.PriorityNormal
+	}
+	s.RequestedHosts, s.Hosts = nonNil(s.RequestedHosts), nonNil(s.Hosts)
+	m.scans[s.ScanID] = s
+	return nil
+}
+
+func (m *memory) GetScan(scanID string) (models.Scan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.scans[scanID]
+	if !ok {
+		return s, sql.ErrNoRows
+	}
+	return s, nil
+}
+
+// status returns the host's status row, creating it when create is set.
+func (m *memory) status(scanID, host string, create bool) *memStatus {
+	for _, st := range m.statuses[scanID] {
+		if st.host == host {
+			return st
+		}
+	}
+	if !create {
+		return nil
+	}
+	st := &memStatus{host: host, status: "pending"}
+	m.statuses[scanID] = append(m.statuses[scanID], st)
+	return st
+}
+
+func (m *memory) SetScanStatus(scanID, host, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status(scanID, host, true)
+	st.status = status
+	now := time.Now()
+	switch status {
+	case "in_progress":
+		st.startedAt = &now
+	case "done", "failed":
+		st.completedAt = &now
+	}
+	return nil
+}
+
+func (m *memory) SetStatusTimes(scanID, host string, startedAt, completedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status(scanID, host, false)
+	if st == nil {
+		return sql.ErrNoRows
+	}
+	st.startedAt, st.completedAt = &startedAt, &completedAt
+	return nil
+}
+
+func (m *memory) FinishScanHost(scanID, host, status, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finish(scanID, host, status, reason)
+	return nil
+}
+
+func (m *memory) finish(scanID, host, status, reason string) {
+	st := m.status(scanID, host, true)
+	now := time.Now()
+	st.status, st.reason, st.completedAt = status, reason, &now
+}
+
+func (m *memory) FindActiveJob(host string, notBefore, notAfter *time.Time, ports string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var (
+		owner  string
+		oldest time.Time
+	)
+	for scanID, sts := range m.statuses {
+		s := m.scans[scanID]
+		if !sameTime(s.NotBefore, notBefore) || !sameTime(s.NotAfter, notAfter) || s.Ports != ports {
+			continue
+		}
+		for _, st := range sts {
+			if st.host == host && st.attachedTo == "" && (st.status == "pending" || st.status == "in_progress") &&
+				(owner == "" || s.CreatedAt.Before(oldest)) {
+				owner, oldest = scanID, s.CreatedAt
+			}
+		}
+	}
+	return owner, owner != "", nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (m *memory) AttachScanHost(scanID, host, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status(scanID, host, true).attachedTo = owner
+	return nil
+}
+
+func (m *memory) GetScanStatuses(scanID string) ([]map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var statuses []map[string]string
+	for _, st := range m.statuses[scanID] {
+		shown := st
+		if st.attachedTo != "" {
+			if o := m.status(st.attachedTo, st.host, false); o != nil {
+				shown = o
+			}
+		}
+		s := map[string]string{"host": st.host, "status": shown.status}
+		if shown.reason != "" {
+			s["reason"] = shown.reason
+		}
+		if st.attachedTo != "" {
+			s["attached_to"] = st.attachedTo
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func (m *memory) StoreResult(res models.ScanResult, status, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res.OpenPorts = intsNonNil(res.OpenPorts)
+	res.Addresses, res.Error = nil, ""
+	m.results = append(m.results, res)
+	m.finish(res.ScanID, res.Host, status, reason)
+	return nil
+}
+
+func (m *memory) GetPreviousResult(host string, before time.Time) (models.ScanResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var (
+		prev  models.ScanResult
+		found bool
+	)
+	for _, r := range m.results {
+		if r.Host == host && r.ScannedAt.Before(before) && len(r.Incomplete) == 0 && (!found || r.ScannedAt.After(prev.ScannedAt)) {
+			prev, found = r, true
+		}
+	}
+	if !found {
+		return prev, sql.ErrNoRows
+	}
+	return prev, nil
+}
+
+func (m *memory) StreamResults(f models.ResultFilter, fn func(models.ScanResult) error) error {
+	m.mu.RLock()
+	var matched []models.ScanResult
+	for _, r := range m.results {
+		if (f.ScanID == "" || r.ScanID == f.ScanID) && (f.Host == "" || r.Host == f.Host) &&
+			(f.Since.IsZero() || !r.ScannedAt.Before(f.Since)) {
+			matched = append(matched, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].Host != matched[j].Host {
+			return matched[i].Host < matched[j].Host
+		}
+		if f.ScanID != "" {
+			return matched[i].ScannedAt.Before(matched[j].ScannedAt)
+		}
+		return matched[i].ScannedAt.After(matched[j].ScannedAt)
+	})
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	for _, r := range matched {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memory) CollapseResult(res models.ScanResult) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := -1
+	for i, r := range m.results {
+		if r.Host == res.Host && (latest < 0 || !r.ScannedAt.Before(m.results[latest].ScannedAt)) {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return false, nil
+	}
+	l := &m.results[latest]
+	if !l.ScannedAt.Before(res.ScannedAt) || len(l.Incomplete) > 0 || !sameFindings(*l, res) || len(l.SeenAgain) >= maxSeenAgain {
+		return false, nil
+	}
+	// Results handed out earlier share the old array.
+	l.SeenAgain = append(slices.Clip(l.SeenAgain), res.ScannedAt)
+	m.finish(res.ScanID, res.Host, "done", collapsedReason(l.ScanID))
+	return true, nil
+}
+
+func (m *memory) ResultHosts(after string, limit int) ([]ResultHost, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := map[string]bool{}
+	var hosts []ResultHost
+	for _, r := range m.results {
+		if r.Host <= after || seen[r.Host] {
+			continue
+		}
+		seen[r.Host] = true
+		h := ResultHost{Host: r.Host}
+		if inv, ok := m.hosts[r.Host]; ok {
+			h.Owner = inv.Owner
+		}
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	if len(hosts) > limit {
+		hosts = hosts[:limit]
+	}
+	return hosts, nil
+}
+
+// PruneResults mirrors retainedResults: it walks the host's history oldest
+// first, results stored earlier first on equal times.
+func (m *memory) PruneResults(host string, before time.Time, limit int, dryRun bool) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var history []int
+	for i, r := range m.results {
+		if r.Host == host {
+			history = append(history, i)
+		}
+	}
+	sort.SliceStable(history, func(a, b int) bool {
+		return m.results[history[a]].ScannedAt.Before(m.results[history[b]].ScannedAt)
+	})
+	redundant := map[int]bool{}
+	for k := 1; k < len(history); k++ {
+		if !dryRun && len(redundant) == limit {
+			break
+		}
+		prev, r := m.results[history[k-1]], m.results[history[k]]
+		sameMonth := prev.ScannedAt.Year() == r.ScannedAt.Year() && prev.ScannedAt.Month() == r.ScannedAt.Month()
+		if r.ScannedAt.Before(before) && sameMonth && sameFindings(prev, r) && len(r.SeenAgain) == 0 {
+			redundant[history[k]] = true
+		}
+	}
+	if !dryRun && len(redundant) > 0 {
+		kept := make([]models.ScanResult, 0, len(m.results)-len(redundant))
+		for i, r := range m.results {
+			if !redundant[i] {
+				kept = append(kept, r)
+			}
+		}
+		m.results = kept
+	}
+	return int64(len(redundant)), nil
+}
+
+func (m *memory) SearchOpenPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error) {
+	hits := []models.PortSearchHit{}
+	err := m.StreamOpenPorts(q, func(h models.PortSearchHit) error {
+		hits = append(hits, h)
+		return nil
+	})
+	return hits, err
+}
+
+func (m *memory) StreamOpenPorts(q models.PortSearchQuery, fn func(models.PortSearchHit) error) error {
+	type portKey struct {
+		host     string
+		port     int
+		protocol string
+	}
+	m.mu.RLock()
+	latest := map[string]models.ScanResult{}
+	var since time.Time
+	if q.SeenWithin > 0 {
+		since = utils.Now().Add(-q.SeenWithin)
+	}
+	best := map[portKey]models.PortSearchHit{}
+	for _, r := range m.results {
+		if q.SeenWithin > 0 {
+			if r.ScannedAt.Before(since) {
+				continue
+			}
+			// Most recent evidence of each (host, port) inside the window.
+			for _, p := range resultPorts(r) {
+				k := portKey{r.Host, p.Port, p.Protocol}
+				if h, ok := best[k]; matchesPort(q, p) && (!ok || r.ScannedAt.After(h.ScannedAt)) {
+					best[k] = models.PortSearchHit{Host: r.Host, ScanID: r.ScanID, ScannedAt: r.ScannedAt, PortInfo: p}
+				}
+			}
+		} else if l, ok := latest[r.Host]; !ok || r.ScannedAt.After(l.ScannedAt) {
+			// Only the latest scan of each host counts.
+			latest[r.Host] = r
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, r := range latest {
+		for _, p := range resultPorts(r) {
+			if matchesPort(q, p) {
+				best[portKey{r.Host, p.Port, p.Protocol}] = models.PortSearchHit{Host: r.Host, ScanID: r.ScanID, ScannedAt: r.ScannedAt, PortInfo: p}
+			}
+		}
+	}
+	hits := make([]models.PortSearchHit, 0, len(best))
+	for _, h := range best {
+		hits = append(hits, h)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	for _, h := range hits {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultPorts mirrors portsExpr: results without port details count their
+// open ports as tcp.
+func resultPorts(r models.ScanResult) []models.PortInfo {
+	if len(r.Ports) > 0 {
+		return r.Ports
+	}
+	ports := make([]models.PortInfo, len(r.OpenPorts))
+	for i, p := range r.OpenPorts {
+		ports[i] = models.PortInfo{Port: p, Protocol: "tcp", State: "open"}
+	}
+	return ports
+}
+
+func matchesPort(q models.PortSearchQuery, p models.PortInfo) bool {
+	return p.State == "open" &&
+		(q.Port == 0 || p.Port == q.Port) &&
+		(q.Protocol == "" || p.Protocol == strings.ToLower(q.Protocol)) &&
+		(q.Service == "" || strings.EqualFold(p.Service, q.Service)) &&
+		(q.Product == "" || strings.Contains(strings.ToLower(p.Product), strings.ToLower(q.Product)))
+}
+
+func (m *memory) UpsertHost(res models.ScanResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var seenUp *time.Time
+	if res.HostState == "up" || (res.HostState == "" && len(res.OpenPorts) > 0) {
+		t := res.ScannedAt
+		seenUp = &t
+	}
+	h, ok := m.hosts[res.Host]
+	if !ok {
+		m.hosts[res.Host] = &models.Host{
+			Host: res.Host, FirstSeen: res.ScannedAt, LastScanned: res.ScannedAt, LastSeenUp: seenUp,
+			LastScanID: res.ScanID, OpenPorts: intsNonNil(res.OpenPorts), Ports: res.Ports,
+			ResolvedIPs: nonNil(res.Addresses), Tags: []string{}, Metadata: map[string]string{},
+			Lifecycle: models.HostActive,
+		}
+		return nil
+	}
+	if res.ScannedAt.Before(h.FirstSeen) {
+		h.FirstSeen = res.ScannedAt
+	}
+	if seenUp != nil && (h.LastSeenUp == nil || seenUp.After(*h.LastSeenUp)) {
+		h.LastSeenUp = seenUp
+		h.Lifecycle = models.HostActive
+	}
+	if !res.ScannedAt.Before(h.LastScanned) {
+		h.LastScanned, h.LastScanID = res.ScannedAt, res.ScanID
+		h.OpenPorts, h.Ports = intsNonNil(res.OpenPorts), res.Ports
+		if len(res.Addresses) > 0 {
+			h.ResolvedIPs = res.Addresses
+		}
+	}
+	return nil
+}
+
+func (m *memory) ListHosts(f models.HostFilter) ([]models.Host, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hosts := []models.Host{}
+	for _, h := range m.hosts {
+		if (f.Tag == "" || contains(h.Tags, f.Tag)) && (f.Owner == "" || h.Owner == f.Owner) &&
+			(f.Lifecycle == "" || h.Lifecycle == f.Lifecycle) && (f.Port == 0 || containsInt(h.OpenPorts, f.Port)) &&
+			(f.Search == "" || strings.Contains(strings.ToLower(h.Host), strings.ToLower(f.Search))) {
+			hosts = append(hosts, copyHost(h))
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	if f.Offset > 0 {
+		if f.Offset >= len(hosts) {
+			return []models.Host{}, nil
+		}
+		hosts = hosts[f.Offset:]
+	}
+	if f.Limit > 0 && len(hosts) > f.Limit {
+		hosts = hosts[:f.Limit]
+	}
+	return hosts, nil
+}
+
+func (m *memory) GetHost(host string) (models.Host, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.hosts[host]
+	if !ok {
+		return models.Host{}, sql.ErrNoRows
+	}
+	return copyHost(h), nil
+}
+
+func (m *memory) UpdateHostMeta(host string, upd models.HostUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hosts[host]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if upd.Tags != nil {
+		h.Tags = append([]string{}, *upd.Tags...)
+	}
+	if upd.Owner != nil {
+		h.Owner = *upd.Owner
+	}
+	if upd.Metadata != nil {
+		h.Metadata = map[string]string{}
+		for k, v := range *upd.Metadata {
+			h.Metadata[k] = v
+		}
+	}
+	return nil
+}
+
+func (m *memory) MarkStaleHosts(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, h := range m.hosts {
+		seen := h.FirstSeen
+		if h.LastSeenUp != nil {
+			seen = *h.LastSeenUp
+		}
+		if h.Lifecycle == models.HostActive && seen.Before(before) {
+			h.Lifecycle = models.HostStale
+			n++
+		}
+	}
+	return n, nil
+}
+
+// copyHost keeps callers from mutating the stored host.
+func copyHost(h *models.Host) models.Host {
+	c := *h
+	c.Tags = append([]string{}, h.Tags...)
+	c.ResolvedIPs = append([]string{}, h.ResolvedIPs...)
+	c.Metadata = map[string]string{}
+	for k, v := range h.Metadata {
+		c.Metadata[k] = v
+	}
+	return c
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNeedsPostgres is returned by features that only the Postgres backend
+// stores, when running on the memory repositories.
+var ErrNeedsPostgres = errors.New("not available without Postgres (database.driver is memory)")
+
+// withoutPostgres points the features that have no repository at an empty
+// store: listings are empty, lookups, replacements and deletions find
+// nothing and creations fail with ErrNeedsPostgres. Scans, statuses,
+// results and hosts keep working from memory.
+func withoutPostgres() {
+	CreateZone = func(models.Zone) error { return ErrNeedsPostgres }
+	ReplaceZone = func(models.Zone) error { return sql.ErrNoRows }
+	GetZone = func(string) (models.Zone, error) { return models.Zone{}, sql.ErrNoRows }
+	ListZones = func() ([]models.Zone, error) { return []models.Zone{}, nil }
+	DeleteZone = func(string) error { return sql.ErrNoRows }
+	RegisterAgent = func(models.AgentRegistration, string, string) (int64, error) { return 0, ErrNeedsPostgres }
+	GetAgentByKey = func(string) (models.Agent, error) { return models.Agent{}, sql.ErrNoRows }
+	TouchAgent = func(int64, *models.AgentHeartbeat, string) error { return sql.ErrNoRows }
+	ListAgents = func() ([]models.Agent, error) { return []models.Agent{}, nil }
+	DeleteAgent = func(int64) error { return sql.ErrNoRows }
+
+	CreateAlertRule = func(models.AlertRule) (int64, error) { return 0, ErrNeedsPostgres }
+	ReplaceAlertRule = func(models.AlertRule) error { return sql.ErrNoRows }
+	GetAlertRule = func(int64) (models.AlertRule, error) { return models.AlertRule{}, sql.ErrNoRows }
+	ListAlertRules = func() ([]models.AlertRule, error) { return []models.AlertRule{}, nil }
+	DeleteAlertRule = func(int64) error { return sql.ErrNoRows }
+	RecordAlert = func(models.Alert, time.Duration) (models.Alert, bool, error) {
+		return models.Alert{}, false, ErrNeedsPostgres
+	}
+	MarkAlertNotified = func(int64, string) error { return sql.ErrNoRows }
+	ListAlerts = func(models.AlertFilter) ([]models.Alert, error) { return []models.Alert{}, nil }
+	CreateSilence = func(models.AlertSilence) (int64, error) { return 0, ErrNeedsPostgres }
+	ListSilences = func(time.Time) ([]models.AlertSilence, error) { return []models.AlertSilence{}, nil }
+	DeleteSilence = func(int64) error { return sql.ErrNoRows }
+	CreateChannel = func(models.AlertChannel) error { return ErrNeedsPostgres }
+	ListChannels = func() ([]models.AlertChannel, error) { return []models.AlertChannel{}, nil }
+	DeleteChannel = func(string) error { return sql.ErrNoRows }
+
+	CreateBaseline = func(models.Baseline) (int64, error) { return 0, ErrNeedsPostgres }
+	ReplaceBaseline = func(models.Baseline) error { return sql.ErrNoRows }
+	GetBaseline = func(int64) (models.Baseline, error) { return models.Baseline{}, sql.ErrNoRows }
+	ListBaselines = func() ([]models.Baseline, error) { return []models.Baseline{}, nil }
+	DeleteBaseline = func(int64) error { return sql.ErrNoRows }
+	RecordViolations = func(int64, string, string, time.Time, []models.Violation) ([]models.Violation, error) {
+		return nil, ErrNeedsPostgres
+	}
+	ListViolations = func(models.ViolationFilter) ([]models.Violation, error) { return []models.Violation{}, nil }
+	GetViolation = func(int64) (models.Violation, error) { return models.Violation{}, sql.ErrNoRows }
+	SetViolationStatus = func(int64, string, models.ViolationAction) error { return sql.ErrNoRows }
+
+	CreateGroup = func(models.AssetGroup) error { return ErrNeedsPostgres }
+	ReplaceGroup = func(models.AssetGroup) error { return sql.ErrNoRows }
+	GetGroup = func(string) (models.AssetGroup, error) { return models.AssetGroup{}, sql.ErrNoRows }
+	ListGroups = func() ([]models.AssetGroup, error) { return []models.AssetGroup{}, nil }
+	DeleteGroup = func(string) error { return sql.ErrNoRows }
+}
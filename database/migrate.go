@@ -0,0 +1,15 @@
+package databse
+
+import (
+	_ "embed"
+)
+
+//go:embed nmapdb.sql
+var schema string
+
+// Migrate applies the schema. Every statement in nmapdb.sql is idempotent,
+// so it is safe to run on every deploy.
+func Migrate() error {
+	_, err := DB.Exec(schema)
+	return err
+}
@@ -9,7 +9,7 @@ CREATE TABLE IF NOT EXISTS scan_results (
 CREATE TABLE IF NOT EXISTS scan_status (
   scan_id UUID NOT NULL,
   host TEXT NOT NULL,
-  status TEXT NOT NULL DEFAULT 'pending', -- pending | in_progress | done | failed
+  status TEXT NOT NULL DEFAULT 'pending', -- scheduled | pending | in_progress | done | partial | failed | skipped
   started_at TIMESTAMP,
   completed_at TIMESTAMP,
   PRIMARY KEY (scan_id, host)
@@ -24,4 +24,200 @@ CREATE TABLE IF NOT EXISTS scan_status (
 --   started_at TIMESTAMP,
 --   completed_at TIMESTAMP,
 --   PRIMARY KEY (scan_id, host)
--- );"
\ No newline at end of file
+-- );"
+-- Per-port details (port, protocol, state, service, product, version).
+-- Rows written before this column existed only have open_ports (tcp).
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS ports JSONB;
+
+CREATE INDEX IF NOT EXISTS scan_results_host_scanned_at_idx ON scan_results (host, scanned_at DESC);
+CREATE INDEX IF NOT EXISTS scan_results_ports_idx ON scan_results USING GIN (ports jsonb_path_ops);
+
+-- Whether nmap reported the host up or down, and the addresses it resolved to.
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS host_state TEXT;
+
+-- Derived inventory, one row per scanned host, refreshed after every stored result.
+CREATE TABLE IF NOT EXISTS hosts (
+  host          TEXT PRIMARY KEY,
+  first_seen    TIMESTAMP NOT NULL,
+  last_scanned  TIMESTAMP NOT NULL,
+  last_seen_up  TIMESTAMP,
+  last_scan_id  UUID,
+  open_ports    INTEGER[] NOT NULL DEFAULT '{}',
+  ports         JSONB,
+  resolved_ips  TEXT[] NOT NULL DEFAULT '{}',
+  tags          TEXT[] NOT NULL DEFAULT '{}',
+  owner         TEXT NOT NULL DEFAULT '',
+  metadata      JSONB NOT NULL DEFAULT '{}',
+  lifecycle     TEXT NOT NULL DEFAULT 'active' -- active | stale
+);
+
+CREATE INDEX IF NOT EXISTS hosts_tags_idx ON hosts USING GIN (tags);
+CREATE INDEX IF NOT EXISTS hosts_open_ports_idx ON hosts USING GIN (open_ports);
+
+-- One row per POST /scan, recording exactly which targets were queued and
+-- what each requested asset group resolved to at scan time.
+CREATE TABLE IF NOT EXISTS scans (
+  scan_id         UUID PRIMARY KEY,
+  created_at      TIMESTAMP NOT NULL DEFAULT now(),
+  requested_hosts TEXT[] NOT NULL DEFAULT '{}',
+  groups          JSONB NOT NULL DEFAULT '{}', -- group name -> resolved hosts
+  hosts           TEXT[] NOT NULL DEFAULT '{}'
+);
+
+-- Where the scan came from: 'api' for queued scans, 'import:<file>' for
+-- uploaded nmap XML.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'api';
+
+-- Named asset groups. Members are the static hosts plus any inventory host
+-- matching the dynamic criteria in match (tags, cidrs, name_patterns).
+CREATE TABLE IF NOT EXISTS asset_groups (
+  name        TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  hosts       TEXT[] NOT NULL DEFAULT '{}',
+  match       JSONB,
+  created_at  TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Expected ports for a host or an asset group ("web tier: 80,443 only").
+CREATE TABLE IF NOT EXISTS baselines (
+  id          BIGSERIAL PRIMARY KEY,
+  scope       TEXT NOT NULL, -- host | group
+  target      TEXT NOT NULL,
+  ports       JSONB NOT NULL DEFAULT '[]',
+  description TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE (scope, target)
+);
+
+-- Deviations of a scan result from its baseline. At most one unresolved
+-- violation exists per (baseline, host, kind, port, protocol).
+CREATE TABLE IF NOT EXISTS violations (
+  id           BIGSERIAL PRIMARY KEY,
+  baseline_id  BIGINT NOT NULL REFERENCES baselines(id) ON DELETE CASCADE,
+  host         TEXT NOT NULL,
+  kind         TEXT NOT NULL, -- unexpected_open | expected_missing | unexpected_service
+  port         INTEGER NOT NULL,
+  protocol     TEXT NOT NULL,
+  detail       TEXT NOT NULL DEFAULT '',
+  status       TEXT NOT NULL DEFAULT 'open', -- open | acknowledged | resolved
+  scan_id      UUID NOT NULL,
+  first_seen   TIMESTAMP NOT NULL,
+  last_seen    TIMESTAMP NOT NULL,
+  acked_by     TEXT NOT NULL DEFAULT '',
+  acked_at     TIMESTAMP,
+  resolved_at  TIMESTAMP,
+  note         TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS violations_unresolved_idx
+  ON violations (baseline_id, host, kind, port, protocol) WHERE status <> 'resolved';
+CREATE INDEX IF NOT EXISTS violations_host_idx ON violations (host, status);
+
+-- Alert rules evaluated by the worker after every stored result.
+CREATE TABLE IF NOT EXISTS alert_rules (
+  id                   BIGSERIAL PRIMARY KEY,
+  name                 TEXT NOT NULL UNIQUE,
+  condition            TEXT NOT NULL, -- new_port_opened | port_open | host_down | new_ports_exceed | violation_opened
+  ports                INTEGER[] NOT NULL DEFAULT '{}',
+  threshold            INTEGER NOT NULL DEFAULT 0,
+  group_name           TEXT NOT NULL DEFAULT '',
+  severity             TEXT NOT NULL DEFAULT 'medium',
+  dedup_window_seconds INTEGER NOT NULL DEFAULT 3600,
+  channels             TEXT[] NOT NULL DEFAULT '{}',
+  disabled             BOOLEAN NOT NULL DEFAULT false,
+  created_at           TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at           TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Fired alerts. Repeats with the same fingerprint inside the rule's dedup
+-- window bump count/last_fired_at instead of creating a new row.
+CREATE TABLE IF NOT EXISTS alerts (
+  id            BIGSERIAL PRIMARY KEY,
+  rule_id       BIGINT NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+  rule_name     TEXT NOT NULL,
+  severity      TEXT NOT NULL,
+  host          TEXT NOT NULL,
+  scan_id       UUID NOT NULL,
+  message       TEXT NOT NULL,
+  ports         INTEGER[] NOT NULL DEFAULT '{}',
+  fingerprint   TEXT NOT NULL,
+  fired_at      TIMESTAMP NOT NULL,
+  last_fired_at TIMESTAMP NOT NULL,
+  count         INTEGER NOT NULL DEFAULT 1,
+  silenced      BOOLEAN NOT NULL DEFAULT false,
+  notified_at   TIMESTAMP,
+  notify_error  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS alerts_fingerprint_idx ON alerts (fingerprint, fired_at DESC);
+CREATE INDEX IF NOT EXISTS alerts_fired_at_idx ON alerts (fired_at DESC);
+
+CREATE TABLE IF NOT EXISTS alert_silences (
+  id         BIGSERIAL PRIMARY KEY,
+  rule_id    BIGINT NOT NULL DEFAULT 0, -- 0 silences every rule
+  host       TEXT NOT NULL DEFAULT '',  -- glob, empty silences every host
+  until      TIMESTAMP NOT NULL,
+  reason     TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS alert_channels (
+  name       TEXT PRIMARY KEY,
+  type       TEXT NOT NULL, -- webhook | smtp | syslog
+  config     JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Network zones: targets inside a zone's CIDRs are queued for the remote
+-- agents registered in that zone instead of the central workers.
+CREATE TABLE IF NOT EXISTS zones (
+  name        TEXT PRIMARY KEY,
+  cidrs       TEXT[] NOT NULL DEFAULT '{}',
+  description TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Remote scanner agents. Only the SHA-256 of an agent's bearer token is kept.
+CREATE TABLE IF NOT EXISTS agents (
+  id            BIGSERIAL PRIMARY KEY,
+  name          TEXT NOT NULL UNIQUE,
+  zone          TEXT NOT NULL DEFAULT '',
+  version       TEXT NOT NULL DEFAULT '',
+  capacity      INTEGER NOT NULL DEFAULT 1,
+  running       INTEGER NOT NULL DEFAULT 0,
+  token_hash    TEXT NOT NULL UNIQUE,
+  remote_addr   TEXT NOT NULL DEFAULT '',
+  registered_at TIMESTAMP NOT NULL DEFAULT now(),
+  last_seen     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal';
+
+-- Scheduling: a scan's hosts may only be scanned between not_before and
+-- not_after, and inside the maintenance windows of the groups they belong to.
+-- Hosts that could not be scanned in time are 'skipped' with a reason.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS not_before TIMESTAMP;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS not_after TIMESTAMP;
+ALTER TABLE asset_groups ADD COLUMN IF NOT EXISTS windows JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE scan_status ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+
+-- Coalescing: a host of a scan that attached to another scan's identical
+-- pending or running job has no job of its own; it reports that job's status.
+ALTER TABLE scan_status ADD COLUMN IF NOT EXISTS attached_to UUID;
+CREATE INDEX IF NOT EXISTS idx_scan_status_active ON scan_status (host)
+  WHERE status IN ('pending', 'in_progress') AND attached_to IS NULL;
+
+-- Port sharding: a scan may name its ports and split each host's range into
+-- shards run as separate jobs. A host whose shards partly failed is
+-- 'partial' and its merged result lists the missing ranges in incomplete.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS ports TEXT NOT NULL DEFAULT '';
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS shard_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS incomplete TEXT[];
+
+-- Result retention: with retention.collapse_unchanged a result identical to
+-- the host's latest one is not stored; its time is appended to that one's
+-- seen_again_at instead.
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS seen_again_at TIMESTAMP[];
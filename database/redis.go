@@ -2,19 +2,27 @@ package databse
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 
+	"nmap-rest-api/config"
+
 	"github.com/redis/go-redis/v9"
 )
 
 var RDB *redis.Client
 
-func InitRedis(ctx context.Context) {
-	RDB = redis.NewClient(&redis.Options{
-		Addr:     "redis:6379",
-		Password: "", // no auth
-		DB:       0,
-	})
+func InitRedis(ctx context.Context, cfg config.Redis) {
+	opts := &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.TLSSkipVerify}
+	}
+	RDB = redis.NewClient(opts)
 	if err := RDB.Ping(ctx).Err(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
@@ -0,0 +1,159 @@
+package databse
+
+import (
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// ScanRepository stores the record of each queued or imported scan.
+type ScanRepository interface {
+	// CreateScan stores s. A zero CreatedAt means now, an empty Source "api"
+	// and an empty Priority "normal".
+	CreateScan(s models.Scan) error
+	// GetScan returns sql.ErrNoRows for unknown scans.
+	GetScan(scanID string) (models.Scan, error)
+}
+
+// StatusRepository tracks each host of a scan from queued to finished.
+type StatusRepository interface {
+	SetScanStatus(scanID, host, status string) error
+	// SetStatusTimes overwrites a host's start/completion times; sql.ErrNoRows
+	// when the host has no status.
+	SetStatusTimes(scanID, host string, startedAt, completedAt time.Time) error
+	// FinishScanHost sets a final status that needs an explanation, such as
+	// skipped, partial or failed, with the reason.
+	FinishScanHost(scanID, host, status, reason string) error
+	// FindActiveJob returns the scan whose own job for host is pending or in
+	// progress under the same not_before/not_after and ports, oldest first.
+	FindActiveJob(host string, notBefore, notAfter *time.Time, ports string) (scanID string, ok bool, err error)
+	// AttachScanHost records that scanID's host is covered by owner's job.
+	AttachScanHost(scanID, host, owner string) error
+	// GetScanStatuses reports attached hosts with the status of the job they
+	// attached to, and that job's scan as "attached_to".
+	GetScanStatuses(scanID string) ([]map[string]string, error)
+}
+
+// ResultRepository stores scan results and answers queries over them.
+type ResultRepository interface {
+	// StoreResult stores res and finishes its host with status and reason
+	// atomically.
+	StoreResult(res models.ScanResult, status, reason string) error
+	// GetPreviousResult returns the host's latest complete result scanned
+	// before before; sql.ErrNoRows when there is none.
+	GetPreviousResult(host string, before time.Time) (models.ScanResult, error)
+	// StreamResults calls fn for every result matching f. Results of a scan
+	// are ordered by host, a host's history newest first. An error from fn
+	// stops the iteration.
+	StreamResults(f models.ResultFilter, fn func(models.ScanResult) error) error
+	SearchOpenPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error)
+	// StreamOpenPorts calls fn for every hit, ordered by host then port.
+	StreamOpenPorts(q models.PortSearchQuery, fn func(models.PortSearchHit) error) error
+	// CollapseResult records res as seen again on the host's latest result
+	// instead of storing it, and finishes res's host done, when that result
+	// is complete, older and found the same ports and state. It reports
+	// whether it did; otherwise nothing is written.
+	CollapseResult(res models.ScanResult) (bool, error)
+	// ResultHosts pages through the hosts that have results, by name: up to
+	// limit hosts after after.
+	ResultHosts(after string, limit int) ([]ResultHost, error)
+	// PruneResults deletes up to limit of host's results scanned before
+	// before that found the same as the result before them, except the
+	// first of each calendar month. A dry run counts all of them instead.
+	PruneResults(host string, before time.Time, limit int, dryRun bool) (int64, error)
+}
+
+// ResultHost is a host with stored results and its inventory owner, if any.
+type ResultHost struct {
+	Host  string
+	Owner string
+}
+
+// HostRepository is the host inventory built from stored results.
+type HostRepository interface {
+	// UpsertHost folds a stored result into the inventory. Results older than
+	// the host's last scan only widen first_seen/last_seen_up.
+	UpsertHost(res models.ScanResult) error
+	ListHosts(f models.HostFilter) ([]models.Host, error)
+	// GetHost returns sql.ErrNoRows when the host was never scanned.
+	GetHost(host string) (models.Host, error)
+	// UpdateHostMeta returns sql.ErrNoRows for unknown hosts.
+	UpdateHostMeta(host string, upd models.HostUpdate) error
+	// MarkStaleHosts flags active hosts not seen up since before.
+	MarkStaleHosts(before time.Time) (int64, error)
+}
+
+// Repositories are the stores handlers, business logic and workers persist
+// through; main picks Postgres or Memory and hands them out.
+type Repositories struct {
+	Scans    ScanRepository
+	Statuses StatusRepository
+	Results  ResultRepository
+	Hosts    HostRepository
+}
+
+// Postgres returns the repositories backed by the pool InitDB opened.
+func Postgres() Repositories {
+	return Repositories{Scans: pgScans{}, Statuses: pgStatuses{}, Results: pgResults{}, Hosts: pgHosts{}}
+}
+
+type pgScans struct{}
+
+func (pgScans) CreateScan(s models.Scan) error             { return createScan(s) }
+func (pgScans) GetScan(scanID string) (models.Scan, error) { return getScan(scanID) }
+
+type pgStatuses struct{}
+
+func (pgStatuses) SetScanStatus(scanID, host, status string) error {
+	return setScanStatus(scanID, host, status)
+}
+func (pgStatuses) SetStatusTimes(scanID, host string, startedAt, completedAt time.Time) error {
+	return setStatusTimes(scanID, host, startedAt, completedAt)
+}
+func (pgStatuses) FinishScanHost(scanID, host, status, reason string) error {
+	return finishScanHost(scanID, host, status, reason)
+}
+func (pgStatuses) FindActiveJob(host string, notBefore, notAfter *time.Time, ports string) (string, bool, error) {
+	return findActiveJob(host, notBefore, notAfter, ports)
+}
+func (pgStatuses) AttachScanHost(scanID, host, owner string) error {
+	return attachScanHost(scanID, host, owner)
+}
+func (pgStatuses) GetScanStatuses(scanID string) ([]map[string]string, error) {
+	return getScanStatuses(scanID)
+}
+
+type pgResults struct{}
+
+func (pgResults) StoreResult(res models.ScanResult, status, reason string) error {
+	return storeResult(res, status, reason)
+}
+func (pgResults) GetPreviousResult(host string, before time.Time) (models.ScanResult, error) {
+	return getPreviousResult(host, before)
+}
+func (pgResults) StreamResults(f models.ResultFilter, fn func(models.ScanResult) error) error {
+	return streamResults(f, fn)
+}
+func (pgResults) SearchOpenPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error) {
+	return searchOpenPorts(q)
+}
+func (pgResults) StreamOpenPorts(q models.PortSearchQuery, fn func(models.PortSearchHit) error) error {
+	return streamOpenPorts(q, fn)
+}
+func (pgResults) CollapseResult(res models.ScanResult) (bool, error) { return collapseResult(res) }
+func (pgResults) ResultHosts(after string, limit int) ([]ResultHost, error) {
+	return resultHosts(after, limit)
+}
+func (pgResults) PruneResults(host string, before time.Time, limit int, dryRun bool) (int64, error) {
+	return pruneResults(host, before, limit, dryRun)
+}
+
+type pgHosts struct{}
+
+func (pgHosts) UpsertHost(res models.ScanResult) error               { return upsertHost(res) }
+func (pgHosts) ListHosts(f models.HostFilter) ([]models.Host, error) { return listHosts(f) }
+func (pgHosts) GetHost(host string) (models.Host, error)             { return getHost(host) }
+func (pgHosts) UpdateHostMeta(host string, upd models.HostUpdate) error {
+	return updateHostMeta(host, upd)
+}
+func (pgHosts) MarkStaleHosts(before time.Time) (int64, error) { return markStaleHosts(before) }
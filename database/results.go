@@ -0,0 +1,105 @@
+package databse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+const resultColumns = `scan_id::text, host, scanned_at, open_ports, ports, COALESCE(host_state, ''), incomplete, seen_again_at::text[]`
+
+// getPreviousResult returns the host's latest complete result scanned before
+// before; partial results would report their missing ports as closed.
+func getPreviousResult(host string, before time.Time) (models.ScanResult, error) {
+	return scanResult(DB.QueryRow(`
+		SELECT `+resultColumns+`
+		FROM scan_results
+		WHERE host = $1 AND scanned_at < $2 AND incomplete IS NULL
+		ORDER BY scanned_at DESC
+		LIMIT 1
+	`, host, before))
+}
+
+// streamResults calls fn for every result matching f without buffering the
+// result set. Results of a scan are ordered by host, a host's history newest
+// first. An error from fn stops the iteration.
+func streamResults(f models.ResultFilter, fn func(models.ScanResult) error) error {
+	var (
+		args  []interface{}
+		conds []string
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.ScanID != "" {
+		conds = append(conds, "scan_id::text = "+arg(f.ScanID))
+	}
+	if f.Host != "" {
+		conds = append(conds, "host = "+arg(f.Host))
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "scanned_at >= "+arg(f.Since))
+	}
+
+	query := `SELECT ` + resultColumns + ` FROM scan_results`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if f.ScanID != "" {
+		query += " ORDER BY host, scanned_at"
+	} else {
+		query += " ORDER BY host, scanned_at DESC"
+	}
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanResult(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanResult(row rowScanner) (models.ScanResult, error) {
+	var (
+		res     models.ScanResult
+		open    pq.Int64Array
+		details []byte
+		seen    []string
+	)
+	if err := row.Scan(&res.ScanID, &res.Host, &res.ScannedAt, &open, &details, &res.HostState, pq.Array(&res.Incomplete), pq.Array(&seen)); err != nil {
+		return res, err
+	}
+	res.OpenPorts = ints(open)
+	for _, s := range seen {
+		t, err := pq.ParseTimestamp(nil, s)
+		if err != nil {
+			return res, err
+		}
+		res.SeenAgain = append(res.SeenAgain, t)
+	}
+	if len(details) > 0 {
+		if err := json.Unmarshal(details, &res.Ports); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package databse
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// maxSeenAgain bounds a result's seen_again_at; once full, the next identical
+// result is stored and collects the following ones.
+const maxSeenAgain = 1000
+
+// retainedResults ranks host $1's results oldest first. A result is
+// redundant when it found the same as the one before it, is not the first of
+// its calendar month (so never the host's first) and has not collected
+// collapsed results in seen_again_at, whose history would go with it.
+const retainedResults = `
+	WITH ranked AS (
+		SELECT id, scanned_at,
+			row_number() OVER (PARTITION BY date_trunc('month', scanned_at) ORDER BY scanned_at, id) > 1
+			AND open_ports IS NOT DISTINCT FROM lag(open_ports) OVER w
+			AND ports IS NOT DISTINCT FROM lag(ports) OVER w
+			AND host_state IS NOT DISTINCT FROM lag(host_state) OVER w
+			AND incomplete IS NOT DISTINCT FROM lag(incomplete) OVER w
+			AND COALESCE(cardinality(seen_again_at), 0) = 0 AS redundant
+		FROM scan_results
+		WHERE host = $1
+		WINDOW w AS (ORDER BY scanned_at, id)
+	)`
+
+// resultHosts returns up to limit hosts with results after after, by name,
+// with their inventory owner.
+func resultHosts(after string, limit int) ([]ResultHost, error) {
+	rows, err := DB.Query(`
+		SELECT r.host, COALESCE(h.owner, '')
+		FROM (SELECT DISTINCT host FROM scan_results WHERE host > $1 ORDER BY host LIMIT $2) r
+		LEFT JOIN hosts h ON h.host = r.host
+		ORDER BY r.host
+	`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hosts []ResultHost
+	for rows.Next() {
+		var h ResultHost
+		if err := rows.Scan(&h.Host, &h.Owner); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// pruneResults deletes up to limit of host's redundant results scanned before
+// before in one statement, or counts all of them on a dry run. Deleting a
+// result that matches its predecessor leaves what the next one is compared
+// with unchanged, so repeated passes converge.
+func pruneResults(host string, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var n int64
+		err := DB.QueryRow(retainedResults+`
+			SELECT count(*) FROM ranked WHERE redundant AND scanned_at < $2
+		`, host, before).Scan(&n)
+		return n, err
+	}
+	res, err := DB.Exec(retainedResults+`
+		DELETE FROM scan_results
+		WHERE id IN (SELECT id FROM ranked WHERE redundant AND scanned_at < $2 LIMIT $3)
+	`, host, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// collapseResult appends res.ScannedAt to the seen_again_at of the host's
+// latest result if that one is complete, older and found the same, and
+// finishes res's host done, in one transaction.
+func collapseResult(res models.ScanResult) (bool, error) {
+	row, err := resultRow(res)
+	if err != nil {
+		return false, err
+	}
+	collapsed := false
+	err = inTx(DB, func(tx *sql.Tx) error {
+		var into string
+		err := tx.QueryRow(`
+			UPDATE scan_results SET seen_again_at = array_append(seen_again_at, $2)
+			WHERE id = (SELECT id FROM scan_results WHERE host = $1 ORDER BY scanned_at DESC, id DESC LIMIT 1)
+			  AND scanned_at < $2 AND incomplete IS NULL
+			  AND open_ports = $3 AND ports IS NOT DISTINCT FROM $4::jsonb AND host_state IS NOT DISTINCT FROM $5
+			  AND COALESCE(cardinality(seen_again_at), 0) < $6
+			RETURNING scan_id::text
+		`, res.Host, res.ScannedAt, row[3], row[4], row[5], maxSeenAgain).Scan(&into)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		collapsed = true
+		return finishInTx(tx, res.ScanID, res.Host, "done", collapsedReason(into))
+	})
+	return collapsed, err
+}
+
+// collapsedReason explains where a collapsed host's result went.
+func collapsedReason(into string) string {
+	return "unchanged since scan " + into + "; recorded there as seen again"
+}
+
+// sameFindings reports whether two results found the same ports and state,
+// as the retention queries compare them.
+func sameFindings(a, b models.ScanResult) bool {
+	return slices.Equal(a.OpenPorts, b.OpenPorts) && slices.Equal(a.Ports, b.Ports) &&
+		a.HostState == b.HostState && slices.Equal(a.Incomplete, b.Incomplete)
+}
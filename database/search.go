@@ -0,0 +1,102 @@
+package databse
+
+import (
+	"fmt"
+	"strings"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/utils"
+)
+
+// portsExpr expands a scan_results row into one record per open port. Rows
+// written before the ports column existed fall back to open_ports as tcp.
+const portsExpr = `
+	jsonb_to_recordset(COALESCE(r.ports, (
+		SELECT COALESCE(jsonb_agg(jsonb_build_object('port', p, 'protocol', 'tcp', 'state', 'open')), '[]'::jsonb)
+		FROM unnest(r.open_ports) AS p
+	))) AS p(port INT, protocol TEXT, state TEXT, service TEXT, product TEXT, version TEXT)`
+
+// likeEscaper makes user input match itself literally in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func searchOpenPorts(q models.PortSearchQuery) ([]models.PortSearchHit, error) {
+	hits := []models.PortSearchHit{}
+	err := streamOpenPorts(q, func(h models.PortSearchHit) error {
+		hits = append(hits, h)
+		return nil
+	})
+	return hits, err
+}
+
+// streamOpenPorts calls fn for every hit, ordered by host then port, without
+// buffering the result set. An error from fn stops the iteration.
+func streamOpenPorts(q models.PortSearchQuery, fn func(models.PortSearchHit) error) error {
+	var (
+		args  []interface{}
+		conds = []string{"p.state = 'open'"}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Port > 0 {
+		conds = append(conds, "p.port = "+arg(q.Port))
+	}
+	if q.Protocol != "" {
+		conds = append(conds, "p.protocol = "+arg(strings.ToLower(q.Protocol)))
+	}
+	if q.Service != "" {
+		conds = append(conds, "lower(p.service) = "+arg(strings.ToLower(q.Service)))
+	}
+	if q.Product != "" {
+		conds = append(conds, "p.product ILIKE "+arg("%"+likeEscaper.Replace(q.Product)+"%")+` ESCAPE '\'`)
+	}
+
+	var query string
+	if q.SeenWithin > 0 {
+		// Most recent evidence of each (host, port) inside the window.
+		since := arg(utils.Now().Add(-q.SeenWithin))
+		query = `
+			SELECT DISTINCT ON (r.host, p.port, p.protocol)
+				r.host, r.scan_id, r.scanned_at, p.port, p.protocol, p.state,
+				COALESCE(p.service, ''), COALESCE(p.product, ''), COALESCE(p.version, '')
+			FROM scan_results r, ` + portsExpr + `
+			WHERE r.scanned_at >= ` + since + ` AND ` + strings.Join(conds, " AND ") + `
+			ORDER BY r.host, p.port, p.protocol, r.scanned_at DESC`
+	} else {
+		// Only the latest scan of each host counts.
+		query = `
+			WITH latest AS (
+				SELECT DISTINCT ON (host) scan_id, host, scanned_at, open_ports, ports
+				FROM scan_results
+				ORDER BY host, scanned_at DESC
+			)
+			SELECT r.host, r.scan_id, r.scanned_at, p.port, p.protocol, p.state,
+				COALESCE(p.service, ''), COALESCE(p.product, ''), COALESCE(p.version, '')
+			FROM latest r, ` + portsExpr + `
+			WHERE ` + strings.Join(conds, " AND ") + `
+			ORDER BY r.host, p.port, p.protocol`
+	}
+	if q.Limit > 0 {
+		query += " LIMIT " + arg(q.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h models.PortSearchHit
+		if err := rows.Scan(&h.Host, &h.ScanID, &h.ScannedAt, &h.Port, &h.Protocol, &h.State,
+			&h.Service, &h.Product, &h.Version); err != nil {
+			return err
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,205 @@
+package databse
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+
+	"github.com/lib/pq"
+)
+
+// ErrWriterClosed is returned for results handed to a writer after Close.
+var ErrWriterClosed = errors.New("result writer is closed")
+
+// writes is the writer StoreResult hands results to; InitDB starts it.
+var writes *resultWriter
+
+// pendingResult is a result waiting for its batch to commit.
+type pendingResult struct {
+	res    models.ScanResult
+	status string
+	reason string
+	done   chan error
+}
+
+// resultWriter commits results together with their status in batches. It
+// groups whatever is queued when it becomes free, so a lone result is written
+// at once and results arriving under load share a transaction. Callers block
+// while the queue is full and until their batch committed.
+type resultWriter struct {
+	db      *sql.DB
+	queue   chan pendingResult
+	batch   int
+	mu      sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+}
+
+func newResultWriter(db *sql.DB, cfg config.Database) *resultWriter {
+	w := &resultWriter{
+		db:      db,
+		queue:   make(chan pendingResult, cfg.WriteBuffer),
+		batch:   cfg.WriteBatch,
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// store queues res and waits until it and its status are committed.
+func (w *resultWriter) store(res models.ScanResult, status, reason string) error {
+	p := pendingResult{res: res, status: status, reason: reason, done: make(chan error, 1)}
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrWriterClosed
+	}
+	w.queue <- p
+	w.mu.RUnlock()
+	return <-p.done
+}
+
+// close writes what is queued and stops the writer.
+func (w *resultWriter) close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.stopped
+}
+
+func (w *resultWriter) run() {
+	defer close(w.stopped)
+	for first := range w.queue {
+		batch := []pendingResult{first}
+	fill:
+		for len(batch) < w.batch {
+			select {
+			case p, ok := <-w.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, p)
+			default:
+				break fill
+			}
+		}
+		w.flush(batch)
+	}
+}
+
+// flush commits batch in one transaction. When that fails, each result is
+// retried on its own so one bad row fails only its caller.
+func (w *resultWriter) flush(batch []pendingResult) {
+	if len(batch) > 1 {
+		err := inTx(w.db, func(tx *sql.Tx) error { return copyResults(tx, batch) })
+		if err == nil {
+			for _, p := range batch {
+				p.done <- nil
+			}
+			return
+		}
+		log.Printf("Batch of %d results failed, writing them one by one: %v", len(batch), err)
+	}
+	for _, p := range batch {
+		p.done <- inTx(w.db, func(tx *sql.Tx) error { return insertResult(tx, p.res, p.status, p.reason) })
+	}
+}
+
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// resultRow returns res as the values of the scan_results columns written.
+func resultRow(res models.ScanResult) ([]interface{}, error) {
+	ports, err := portsJSON(res.Ports)
+	if err != nil {
+		return nil, err
+	}
+	var hostState, incomplete interface{}
+	if res.HostState != "" {
+		hostState = res.HostState
+	}
+	if len(res.Incomplete) > 0 {
+		incomplete = pq.Array(res.Incomplete)
+	}
+	return []interface{}{res.ScanID, res.Host, res.ScannedAt, pq.Array(intsNonNil(res.OpenPorts)), ports, hostState, incomplete}, nil
+}
+
+// insertResult stores one result and finishes its host with status.
+func insertResult(tx *sql.Tx, res models.ScanResult, status, reason string) error {
+	row, err := resultRow(res)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO scan_results (scan_id, host, scanned_at, open_ports, ports, host_state, incomplete)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, row...)
+	if err != nil {
+		return err
+	}
+	return finishInTx(tx, res.ScanID, res.Host, status, reason)
+}
+
+// finishInTx sets the host's final status as part of tx.
+func finishInTx(tx *sql.Tx, scanID, host, status, reason string) error {
+	_, err := tx.Exec(`
+		INSERT INTO scan_status (scan_id, host, status, reason, completed_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (scan_id, host)
+		DO UPDATE SET status = $3, reason = $4, completed_at = now()
+	`, scanID, host, status, reason)
+	return err
+}
+
+// copyResults stores a batch of results with COPY and finishes their hosts
+// in one multi-row upsert.
+func copyResults(tx *sql.Tx, batch []pendingResult) error {
+	stmt, err := tx.Prepare(pq.CopyIn("scan_results", "scan_id", "host", "scanned_at", "open_ports", "ports", "host_state", "incomplete"))
+	if err != nil {
+		return err
+	}
+	var scanIDs, hosts, statuses, reasons []string
+	for _, p := range batch {
+		row, err := resultRow(p.res)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+		scanIDs = append(scanIDs, p.res.ScanID)
+		hosts = append(hosts, p.res.Host)
+		statuses = append(statuses, p.status)
+		reasons = append(reasons, p.reason)
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO scan_status (scan_id, host, status, reason, completed_at)
+		SELECT s, h, st, r, now() FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[]) AS t (s, h, st, r)
+		ON CONFLICT (scan_id, host)
+		DO UPDATE SET status = EXCLUDED.status, reason = EXCLUDED.reason, completed_at = now()
+	`, pq.Array(scanIDs), pq.Array(hosts), pq.Array(statuses), pq.Array(reasons))
+	return err
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+var csvHeader = []string{"scan_id", "host", "scanned_at", "host_state", "port", "protocol", "state", "service", "product", "version"}
+
+// csvWriter writes one row per port; hosts without open ports get a single
+// row with empty port columns.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSV(w io.Writer) (*csvWriter, error) {
+	cw := csv.NewWriter(w)
+	return &csvWriter{w: cw}, cw.Write(csvHeader)
+}
+
+func (c *csvWriter) Write(res models.ScanResult) error {
+	base := []string{res.ScanID, res.Host, res.ScannedAt.UTC().Format(time.RFC3339), res.HostState}
+	ports := portsOf(res)
+	if len(ports) == 0 {
+		if err := c.w.Write(append(base, "", "", "", "", "", "")); err != nil {
+			return err
+		}
+	}
+	for _, p := range ports {
+		row := append(append([]string{}, base...),
+			strconv.Itoa(p.Port), p.Protocol, p.State, p.Service, p.Product, p.Version)
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,77 @@
+// Package export streams stored scan results in interchange formats: CSV,
+// JSON Lines, nmap XML and SARIF. Writers emit each result as soon as it is
+// written so exports never have to fit in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXML   = "xml"
+	FormatSARIF = "sarif"
+)
+
+// Writer serialises a stream of results. Close must be called to complete
+// formats that have a trailer (XML, SARIF).
+type Writer interface {
+	Write(res models.ScanResult) error
+	Close() error
+}
+
+// New returns a writer for format.
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSV(w)
+	case FormatJSONL:
+		return newJSONL(w), nil
+	case FormatXML:
+		return newXML(w, time.Now())
+	case FormatSARIF:
+		return newSARIF(w)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// ContentType is the HTTP media type of format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXML:
+		return "application/xml"
+	case FormatSARIF:
+		return "application/sarif+json"
+	}
+	return "application/octet-stream"
+}
+
+// Extension is the file name extension used for downloads of format.
+func Extension(format string) string {
+	if format == FormatSARIF {
+		return "sarif.json"
+	}
+	return format
+}
+
+// portsOf returns the detailed ports of res, synthesising open tcp ports for
+// results stored before per-port details existed.
+func portsOf(res models.ScanResult) []models.PortInfo {
+	if len(res.Ports) > 0 {
+		return res.Ports
+	}
+	ports := make([]models.PortInfo, 0, len(res.OpenPorts))
+	for _, p := range res.OpenPorts {
+		ports = append(ports, models.PortInfo{Port: p, Protocol: "tcp", State: "open"})
+	}
+	return ports
+}
@@ -0,0 +1,108 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"nmap-rest-api/export"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/nmap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var results = []models.ScanResult{
+	{
+		ScanID:    "scan-1",
+		Host:      "10.0.0.5",
+		ScannedAt: time.Date(2025, 5, 9, 7, 0, 0, 0, time.UTC),
+		HostState: "up",
+		OpenPorts: []int{22, 443},
+		Ports: []models.PortInfo{
+			{Port: 22, Protocol: "tcp", State: "open", Service: "ssh", Product: "OpenSSH"},
+			{Port: 443, Protocol: "tcp", State: "open", Service: "https"},
+		},
+	},
+	{
+		// stored before per-port details existed
+		ScanID:    "scan-1",
+		Host:      "scanme.nmap.org",
+		ScannedAt: time.Date(2025, 5, 9, 7, 1, 0, 0, time.UTC),
+		OpenPorts: []int{80},
+	},
+}
+
+func write(t *testing.T, format string) string {
+	var buf bytes.Buffer
+	w, err := export.New(format, &buf)
+	require.NoError(t, err)
+	for _, r := range results {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(write(t, export.FormatCSV))).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 4) // header + 2 ports + 1 legacy port
+	assert.Equal(t, "scan_id", rows[0][0])
+	assert.Equal(t, []string{"scan-1", "10.0.0.5", "2025-05-09T07:00:00Z", "up", "22", "tcp", "open", "ssh", "OpenSSH", ""}, rows[1])
+	assert.Equal(t, "80", rows[3][4])
+}
+
+func TestJSONL(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(write(t, export.FormatJSONL)), "\n")
+	require.Len(t, lines, 2)
+
+	var got models.ScanResult
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, results[0].Ports, got.Ports)
+}
+
+func TestXML_RoundTripsThroughParser(t *testing.T) {
+	run, err := nmap.Parse(strings.NewReader(write(t, export.FormatXML)))
+	require.NoError(t, err)
+	require.Len(t, run.Hosts, 2)
+
+	assert.Equal(t, "10.0.0.5", run.Hosts[0].Address())
+	assert.Equal(t, "OpenSSH", run.Hosts[0].PortInfos()[0].Product)
+	assert.Equal(t, "scanme.nmap.org", run.Hosts[1].Hostname())
+	assert.Equal(t, 80, run.Hosts[1].PortInfos()[0].Port)
+	assert.Equal(t, results[0].ScannedAt, run.Hosts[0].ScannedAt())
+}
+
+func TestSARIF(t *testing.T) {
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				PartialFingerprints map[string]string `json:"partialFingerprints"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(write(t, export.FormatSARIF)), &log))
+
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	require.Len(t, log.Runs[0].Results, 3)
+	assert.Equal(t, "open-port", log.Runs[0].Results[0].RuleID)
+	assert.Equal(t, "Port 22/tcp is open on 10.0.0.5 (ssh OpenSSH)", log.Runs[0].Results[0].Message.Text)
+	assert.Equal(t, "scanme.nmap.org:80/tcp", log.Runs[0].Results[2].PartialFingerprints["openPort/v1"])
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, err := export.New("pdf", &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,25 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	models "nmap-rest-api/models/v1"
+)
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONL(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{enc: json.NewEncoder(w)}
+}
+
+// Write emits res as a single line; json.Encoder terminates each value with \n.
+func (j *jsonlWriter) Write(res models.ScanResult) error {
+	return j.enc.Encode(res)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
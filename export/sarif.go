@@ -0,0 +1,101 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	models "nmap-rest-api/models/v1"
+)
+
+const sarifRuleID = "open-port"
+
+// sarifWriter emits a SARIF 2.1.0 log with a single run. Every open port is a
+// result located at the host, so code-scanning dashboards can track it.
+type sarifWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newSARIF(w io.Writer) (*sarifWriter, error) {
+	driver := map[string]interface{}{
+		"name":           "nmap-rest-api",
+		"informationUri": "https://nmap.org",
+		"rules": []map[string]interface{}{{
+			"id":               sarifRuleID,
+			"name":             "OpenPort",
+			"shortDescription": map[string]string{"text": "Network port open"},
+			"fullDescription":  map[string]string{"text": "A network port was found open by an nmap scan."},
+			"defaultConfiguration": map[string]string{
+				"level": "note",
+			},
+		}},
+	}
+	driverJSON, err := json.Marshal(driver)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(w, `{"$schema":"https://json.schemastore.org/sarif-2.1.0.json","version":"2.1.0","runs":[{"tool":{"driver":%s},"results":[`, driverJSON)
+	return &sarifWriter{w: w}, err
+}
+
+func (s *sarifWriter) Write(res models.ScanResult) error {
+	for _, p := range portsOf(res) {
+		if p.State != "" && p.State != "open" {
+			continue
+		}
+		b, err := json.Marshal(sarifResult(res, p))
+		if err != nil {
+			return err
+		}
+		if s.count > 0 {
+			if _, err := io.WriteString(s.w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := s.w.Write(b); err != nil {
+			return err
+		}
+		s.count++
+	}
+	return nil
+}
+
+func (s *sarifWriter) Close() error {
+	_, err := io.WriteString(s.w, "]}]}\n")
+	return err
+}
+
+func sarifResult(res models.ScanResult, p models.PortInfo) map[string]interface{} {
+	service := strings.TrimSpace(strings.Join([]string{p.Service, p.Product, p.Version}, " "))
+	text := fmt.Sprintf("Port %d/%s is open on %s", p.Port, p.Protocol, res.Host)
+	if service != "" {
+		text += " (" + service + ")"
+	}
+	return map[string]interface{}{
+		"ruleId":  sarifRuleID,
+		"level":   "note",
+		"message": map[string]string{"text": text},
+		"locations": []map[string]interface{}{{
+			"physicalLocation": map[string]interface{}{
+				"artifactLocation": map[string]string{"uri": fmt.Sprintf("hosts/%s/%s/%d", res.Host, p.Protocol, p.Port)},
+			},
+			"logicalLocations": []map[string]string{{
+				"name":               fmt.Sprintf("%d/%s", p.Port, p.Protocol),
+				"fullyQualifiedName": fmt.Sprintf("%s:%d/%s", res.Host, p.Port, p.Protocol),
+				"kind":               "port",
+			}},
+		}},
+		"partialFingerprints": map[string]string{
+			"openPort/v1": fmt.Sprintf("%s:%d/%s", res.Host, p.Port, p.Protocol),
+		},
+		"properties": map[string]interface{}{
+			"scan_id":    res.ScanID,
+			"scanned_at": res.ScannedAt,
+			"service":    p.Service,
+			"product":    p.Product,
+			"version":    p.Version,
+		},
+	}
+}
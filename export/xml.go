@@ -0,0 +1,29 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/nmap"
+)
+
+type xmlWriter struct {
+	nw *nmap.Writer
+}
+
+func newXML(w io.Writer, start time.Time) (*xmlWriter, error) {
+	nw, err := nmap.NewWriter(w, "nmap-rest-api export", start)
+	if err != nil {
+		return nil, err
+	}
+	return &xmlWriter{nw: nw}, nil
+}
+
+func (x *xmlWriter) Write(res models.ScanResult) error {
+	return x.nw.WriteResult(res)
+}
+
+func (x *xmlWriter) Close() error {
+	return x.nw.Close()
+}
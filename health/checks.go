@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+
+	database "nmap-rest-api/database"
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/worker"
+)
+
+// Postgres pings the database pool.
+func Postgres(ctx context.Context) (string, error) {
+	if database.DB == nil {
+		return "", errors.New("not connected")
+	}
+	return "", database.DB.PingContext(ctx)
+}
+
+// Redis pings the queue.
+func Redis(ctx context.Context) (string, error) {
+	if database.RDB == nil {
+		return "", errors.New("not connected")
+	}
+	return "", database.RDB.Ping(ctx).Err()
+}
+
+// Collector returns a check that opens a TCP connection to the OTLP
+// collector at addr (host:port).
+func Collector(addr string) Check {
+	return func(ctx context.Context) (string, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return addr, nil
+	}
+}
+
+var nmapVersion = regexp.MustCompile(`Nmap version (\S+)`)
+
+// Nmap returns a check that runs `<binary> --version` and reports the
+// installed version.
+func Nmap(binary string) Check {
+	return func(ctx context.Context) (string, error) {
+		out, err := exec.CommandContext(ctx, binary, "--version").CombinedOutput()
+		if err != nil {
+			return "", err
+		}
+		m := nmapVersion.FindSubmatch(out)
+		if m == nil {
+			return "", errors.New("unrecognised nmap --version output")
+		}
+		return string(m[1]), nil
+	}
+}
+
+// Workers reports the worker pool by state. It fails when no worker is
+// running or every worker is backing off after queue errors.
+func Workers(ctx context.Context) (string, error) {
+	workers := worker.Workers()
+	counts := map[string]int{}
+	for _, w := range workers {
+		counts[w.State]++
+	}
+	detail := fmt.Sprintf("%d idle, %d waiting, %d scanning, %d storing",
+		counts[models.WorkerIdle], counts[models.WorkerWaiting], counts[models.WorkerScanning], counts[models.WorkerStoring])
+	switch {
+	case len(workers) == 0:
+		return detail, errors.New("no workers running")
+	case counts[models.WorkerIdle] == len(workers):
+		return detail, errors.New("all workers are backing off")
+	}
+	return detail, nil
+}
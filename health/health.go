@@ -0,0 +1,96 @@
+// Package health runs the dependency checks behind the readiness endpoint.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// Check probes one dependency. detail is shown on success, e.g. a version.
+type Check func(ctx context.Context) (detail string, err error)
+
+type check struct {
+	name     string
+	critical bool
+	fn       Check
+}
+
+// Timeout bounds each check so one hung dependency cannot stall the probe.
+var Timeout = 2 * time.Second
+
+var (
+	mu       sync.Mutex
+	checks   []check
+	draining atomic.Bool
+)
+
+// Register adds a check to the readiness report. Only failing critical checks
+// make the service unready; the others are reported for visibility.
+func Register(name string, critical bool, fn Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks = append(checks, check{name: name, critical: critical, fn: fn})
+}
+
+// SetDraining marks the process as shutting down; readiness fails from then
+// on so load balancers stop routing to it.
+func SetDraining(v bool) {
+	draining.Store(v)
+}
+
+// Draining reports whether SetDraining(true) was called.
+func Draining() bool {
+	return draining.Load()
+}
+
+// Run executes every registered check concurrently and returns the report.
+func Run(ctx context.Context) models.HealthReport {
+	mu.Lock()
+	list := append([]check(nil), checks...)
+	mu.Unlock()
+
+	report := models.HealthReport{Status: models.HealthOK, Checks: make([]models.HealthCheck, len(list))}
+	var wg sync.WaitGroup
+	for i, c := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range report.Checks {
+		if c.Critical && c.Status != models.HealthOK {
+			report.Status = models.HealthFail
+		}
+	}
+	if Draining() {
+		report.Status = models.HealthDraining
+	}
+	return report
+}
+
+func run(ctx context.Context, c check) models.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := c.fn(ctx)
+	res := models.HealthCheck{
+		Name:      c.name,
+		Status:    models.HealthOK,
+		Critical:  c.critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:    detail,
+	}
+	if err != nil {
+		res.Status = models.HealthFail
+		res.Error = err.Error()
+	}
+	return res
+}
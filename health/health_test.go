@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		checks = nil
+		SetDraining(false)
+	})
+}
+
+func TestRun_NonCriticalFailureStaysReady(t *testing.T) {
+	reset(t)
+	Register("postgres", true, func(ctx context.Context) (string, error) { return "", nil })
+	Register("otlp_collector", false, func(ctx context.Context) (string, error) { return "", errors.New("refused") })
+
+	report := Run(context.Background())
+	assert.Equal(t, models.HealthOK, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "postgres", report.Checks[0].Name)
+	assert.Equal(t, models.HealthFail, report.Checks[1].Status)
+	assert.Equal(t, "refused", report.Checks[1].Error)
+}
+
+func TestRun_CriticalTimeoutFails(t *testing.T) {
+	reset(t)
+	old := Timeout
+	Timeout = 10 * time.Millisecond
+	defer func() { Timeout = old }()
+	Register("redis", true, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	report := Run(context.Background())
+	assert.Equal(t, models.HealthFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	assert.GreaterOrEqual(t, report.Checks[0].LatencyMS, 10.0)
+}
+
+func TestRun_Draining(t *testing.T) {
+	reset(t)
+	Register("nmap", true, func(ctx context.Context) (string, error) { return "7.94", nil })
+	SetDraining(true)
+
+	report := Run(context.Background())
+	assert.Equal(t, models.HealthDraining, report.Status)
+	assert.Equal(t, "7.94", report.Checks[0].Detail)
+}
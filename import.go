@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	businessv1 "nmap-rest-api/business/v1"
+	database "nmap-rest-api/database"
+)
+
+// runImport implements `nmap-rest-api import [flags] FILE...`: each nmap XML
+// file is stored as its own scan, exactly like an upload to POST /import.
+// Only the database is needed; Redis and the workers are not started.
+func runImport(args []string) {
+	cfg, files := loadConfig("nmap-rest-api import", args)
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: nmap-rest-api import [--config FILE] [flags] FILE.xml [FILE.xml...]")
+		os.Exit(2)
+	}
+	needsPostgres(cfg, "import")
+	database.InitDB(cfg.Database)
+
+	ctx := context.Background()
+	failed := false
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			failed = true
+			continue
+		}
+		sum, err := businessv1.ImportNmapXML(ctx, f, name)
+		f.Close()
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: scan %s, %d hosts imported", name, sum.ScanID, len(sum.Hosts)-len(sum.Failed))
+		if len(sum.Failed) > 0 {
+			fmt.Printf(", %d failed", len(sum.Failed))
+			failed = true
+		}
+		fmt.Println()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -2,45 +2,298 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	apiv1 "nmap-rest-api/api/v1"
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
 	database "nmap-rest-api/database"
+	"nmap-rest-api/health"
 	"nmap-rest-api/router"
+	"nmap-rest-api/scheduler"
 	"nmap-rest-api/telemetry"
 	"nmap-rest-api/worker"
 )
 
+// mode selects which parts a process runs. Separate processes coordinate
+// only through Postgres (scans, statuses, results) and Redis (job queue,
+// scheduler lock).
+type mode struct {
+	api       bool // HTTP API
+	workers   bool // scan worker pool
+	scheduler bool // periodic maintenance
+}
+
+var modes = map[string]mode{
+	"all":       {api: true, workers: true, scheduler: true},
+	"serve":     {api: true},
+	"worker":    {workers: true},
+	"scheduler": {scheduler: true},
+}
+
+const usage = `usage: nmap-rest-api [MODE] [--config FILE] [flags]
+
+Modes:
+  all        API, workers and scheduler in one process (default)
+  serve      HTTP API only
+  worker     scan workers only; serves /healthz, /readyz, /metrics
+  scheduler  periodic maintenance only; safe to run several
+  agent      remote scanner: takes jobs for its zone from the API over HTTP(S)
+  migrate    apply the database schema and exit
+  import     import nmap XML files: import [flags] FILE.xml...
+  config     print the effective configuration: config print [flags]
+`
+
 func main() {
-	// Set up tracing first
-	telemetry.InitTracer()
-	ctx := context.Background()
+	name, args := "all", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	switch name {
+	case "import":
+		runImport(args)
+	case "config":
+		runConfig(args)
+	case "agent":
+		runAgent(args)
+	case "migrate":
+		runMigrate(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		m, ok := modes[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown mode %q\n\n%s", name, usage)
+			os.Exit(2)
+		}
+		run(name, m, args)
+	}
+}
 
-	dsn := os.Getenv("DB_DSN")
-	if dsn == "" {
-		log.Fatal("DB_DSN environment variable is not set")
+func run(name string, m mode, args []string) {
+	cfg, _ := loadConfig("nmap-rest-api "+name, args)
+	if name != "all" {
+		needsPostgres(cfg, name+" mode")
+		needsRedis(cfg, name+" mode")
 	}
-	database.InitDB(dsn)
+	log.Printf("Starting in %s mode", name)
 
-	// connecting through redis
-	database.InitRedis(ctx)
+	// Set up tracing first
+	telemetry.InitTracer(cfg.Telemetry)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	repos := database.Open(cfg.Database)
+	businessv1.Repos = repos
+	apiv1.Repos = repos
+	businessv1.Agents = cfg.Agents
+	businessv1.Limits = cfg.Limits
+	businessv1.IdempotencyTTL = cfg.Server.IdempotencyTTL
+	businessv1.Retention = cfg.Retention
+
+	// Job queue: shared through Redis, or in this process
+	var queue *businessv1.MemoryQueue
+	if cfg.Queue.Driver == config.QueueMemory {
+		q, err := businessv1.NewMemoryQueue(cfg.Queue.WAL)
+		if err != nil {
+			log.Fatalf("Failed to open the job queue: %v", err)
+		}
+		businessv1.Queue, queue = q.Stores(), q
+	} else {
+		database.InitRedis(ctx, cfg.Redis)
+	}
 
 	// Metrics
-	telemetry.InitMetrics(ctx, func() int64 {
-		len, err := database.RDB.LLen(ctx, "scan_jobs").Result()
+	telemetry.InitMetrics(ctx, cfg.Telemetry, func() int64 {
+		len, err := businessv1.QueueLength(context.Background(), "")
 		if err != nil {
-			log.Printf("Failed to get Redis queue length: %v", err)
+			log.Printf("Failed to get queue length: %v", err)
 			return 0
 		}
 		return len
 	})
 
+	// Readiness checks
+	if cfg.Database.Driver == config.DriverPostgres {
+		health.Register("postgres", true, health.Postgres)
+	}
+	if cfg.Queue.Driver == config.QueueRedis {
+		health.Register("redis", true, health.Redis)
+	}
+	health.Register("otlp_collector", false, health.Collector(cfg.Telemetry.OTLPEndpoint))
+
 	// Start async workers
-	// TODO: Instead of 5 we can any number of worker coming from config
-	worker.StartWorkerPool(5, ctx)
+	var pool *worker.Pool
+	if m.workers {
+		worker.Nmap = cfg.Nmap
+		worker.BatchSize = cfg.Worker.BatchSize
+		pool = worker.StartWorkerPool(cfg.Worker.Count, ctx)
+		health.Register("nmap", true, health.Nmap(cfg.Nmap.Binary))
+		health.Register("workers", true, health.Workers)
+	}
+
+	// Periodic maintenance, e.g. marking inventory hosts stale
+	var maintenance <-chan struct{}
+	if m.scheduler {
+		maintenance = scheduler.Start(ctx, scheduler.Tasks(cfg))
+	}
+
+	// HTTP server: the full API, or just probes and metrics
+	r := router.SetupProbeRouter()
+	if m.api {
+		r = router.SetupRouter()
+	}
+	srv := &http.Server{Addr: cfg.Server.Listen, Handler: r}
+	go func() {
+		var err error
+		if cfg.Server.TLS() {
+			log.Println("HTTP server running on", cfg.Server.Listen, "(TLS)")
+			err = srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			log.Println("HTTP server running on", cfg.Server.Listen)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("HTTP server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(srv, pool, maintenance, queue, cfg.Server.ShutdownGrace.D())
+}
+
+// runMigrate implements `nmap-rest-api migrate [flags]`.
+func runMigrate(args []string) {
+	cfg, _ := loadConfig("nmap-rest-api migrate", args)
+	needsPostgres(cfg, "migrate")
+	database.InitDB(cfg.Database)
+	defer database.Close()
+	if err := database.Migrate(); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	log.Println("Database schema is up to date")
+}
+
+// needsPostgres exits unless the database is Postgres: the memory driver's
+// store lives and dies with the all-in-one process, so nothing else can
+// share it.
+func needsPostgres(cfg config.Config, what string) {
+	if cfg.Database.Driver != config.DriverPostgres {
+		log.Fatalf("%s needs database.driver %s, not %s", what, config.DriverPostgres, cfg.Database.Driver)
+	}
+}
+
+// needsRedis exits unless jobs are queued in Redis, for the same reason.
+func needsRedis(cfg config.Config, what string) {
+	if cfg.Queue.Driver != config.QueueRedis {
+		log.Fatalf("%s needs queue.driver %s, not %s", what, config.QueueRedis, cfg.Queue.Driver)
+	}
+}
+
+// loadConfig loads and validates the configuration, exiting on errors. It
+// returns the arguments left after the flags.
+func loadConfig(name string, args []string) (config.Config, []string) {
+	cfg, rest, err := config.Load(name, args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	return cfg, rest
+}
+
+// runConfig implements `nmap-rest-api config print [flags]`: it prints the
+// effective configuration with secrets redacted, followed by any validation
+// problems.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: nmap-rest-api config print [--config FILE] [flags]")
+		os.Exit(2)
+	}
+	cfg, _, err := config.Load("nmap-rest-api config print", args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// shutdown drains the process: readiness fails, the HTTP server stops
+// accepting requests and the workers stop taking jobs, in-flight requests and
+// scans get the grace period to finish (unfinished scans are requeued), as do
+// the scheduler (closing maintenance) and alert deliveries, then telemetry is
+// flushed and connections are closed.
+func shutdown(srv *http.Server, pool *worker.Pool, maintenance <-chan struct{}, queue *businessv1.MemoryQueue, grace time.Duration) {
+	log.Printf("Shutting down, grace period %s", grace)
+	health.SetDraining(true)
 
-	// HTTP server
-	r := router.SetupRouter()
-	log.Println("API Server running on :8080")
-	r.Run(":8080")
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server shutdown: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if pool != nil {
+			pool.Shutdown(ctx)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if maintenance == nil {
+			return
+		}
+		select {
+		case <-maintenance:
+		case <-ctx.Done():
+			log.Println("Shutdown grace period over, scheduler still running")
+		}
+	}()
+	wg.Wait()
+	// Requests and scans that just finished may have fired alerts.
+	if !businessv1.WaitForDispatches(ctx) {
+		log.Println("Shutdown grace period over, alert deliveries still running")
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	telemetry.ShutdownTracer(flushCtx)
+	telemetry.ShutdownMetrics(flushCtx)
+	if queue != nil {
+		// After the workers, which requeue the scans they cancelled.
+		if err := queue.Close(); err != nil {
+			log.Printf("Closing the job queue: %v", err)
+		}
+	}
+	database.Close()
+	log.Println("Shutdown complete")
 }
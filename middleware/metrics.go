@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"nmap-rest-api/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Metrics counts requests and records their latency per route. The route is
+// the registered pattern (e.g. /hosts/:host), not the raw path, to keep label
+// cardinality bounded.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := c.Request.Context()
+		telemetry.HTTPRequests.Add(ctx, 1, metric.WithAttributes(
+			telemetry.LabelMethod.String(c.Request.Method),
+			telemetry.LabelRoute.String(route),
+			telemetry.LabelCode.String(strconv.Itoa(c.Writer.Status())),
+		))
+		telemetry.HTTPDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			telemetry.LabelMethod.String(c.Request.Method),
+			telemetry.LabelRoute.String(route),
+		))
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Zone is a network segment reachable only by the agents registered in it.
+// Targets inside any of its CIDRs (by IP, or by an inventory host's resolved
+// IPs) are queued for those agents instead of the central workers.
+type Zone struct {
+	Name        string    `json:"name"`
+	CIDRs       []string  `json:"cidrs"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Agent is a remote scanner that pulls jobs for its zone over HTTP. An empty
+// Zone serves the default queue, like a central worker.
+type Agent struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Zone         string    `json:"zone,omitempty"`
+	Version      string    `json:"version"`
+	Capacity     int       `json:"capacity"`
+	Running      int       `json:"running"`
+	RemoteAddr   string    `json:"remote_addr,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	// Online is true while the agent has been heard from recently.
+	Online bool `json:"online"`
+}
+
+// AgentRegistration is sent by an agent on start, authenticated with the
+// enrollment token. Registering an existing name replaces its token.
+type AgentRegistration struct {
+	Name     string `json:"name"`
+	Zone     string `json:"zone,omitempty"`
+	Version  string `json:"version"`
+	Capacity int    `json:"capacity"`
+}
+
+// AgentCredentials is the registration response. Token authenticates every
+// later request as "Authorization: Bearer <token>"; it is shown only once.
+type AgentCredentials struct {
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+}
+
+// AgentHeartbeat reports an agent's state. Jobs lists the jobs it is still
+// scanning; their leases are renewed.
+type AgentHeartbeat struct {
+	Version  string    `json:"version"`
+	Capacity int       `json:"capacity"`
+	Running  int       `json:"running"`
+	Jobs     []ScanJob `json:"jobs,omitempty"`
+}
+
+// AgentResult is a finished job uploaded by an agent.
+type AgentResult struct {
+	Job    ScanJob    `json:"job"`
+	Result ScanResult `json:"result"`
+}
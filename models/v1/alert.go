@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+
+	// ConditionNewPortOpened fires when a port is open that was closed in the
+	// host's previous result.
+	ConditionNewPortOpened = "new_port_opened"
+	// ConditionPortOpen fires when any of the rule's Ports is open.
+	ConditionPortOpen = "port_open"
+	// ConditionHostDown fires when a host that was up is now down.
+	ConditionHostDown = "host_down"
+	// ConditionNewPortsExceed fires when more than Threshold ports opened at once.
+	ConditionNewPortsExceed = "new_ports_exceed"
+	// ConditionViolationOpened fires when a scan opens a baseline violation.
+	ConditionViolationOpened = "violation_opened"
+)
+
+// AlertRule is evaluated against every stored scan result. Group, when set,
+// limits the rule to members of that asset group. Repeats of the same alert
+// within DedupWindowSeconds are folded into the first one.
+type AlertRule struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Condition          string    `json:"condition"`
+	Ports              []int     `json:"ports,omitempty"`
+	Threshold          int       `json:"threshold,omitempty"`
+	Group              string    `json:"group,omitempty"`
+	Severity           string    `json:"severity"`
+	DedupWindowSeconds int       `json:"dedup_window_seconds"`
+	Channels           []string  `json:"channels,omitempty"`
+	Disabled           bool      `json:"disabled,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Alert is a fired rule, linked to the scan that provided the evidence.
+type Alert struct {
+	ID          int64      `json:"id"`
+	RuleID      int64      `json:"rule_id"`
+	RuleName    string     `json:"rule_name"`
+	Severity    string     `json:"severity"`
+	Host        string     `json:"host"`
+	ScanID      string     `json:"scan_id"`
+	Message     string     `json:"message"`
+	Ports       []int      `json:"ports,omitempty"`
+	Fingerprint string     `json:"fingerprint"`
+	FiredAt     time.Time  `json:"fired_at"`
+	LastFiredAt time.Time  `json:"last_fired_at"`
+	Count       int        `json:"count"`
+	Silenced    bool       `json:"silenced"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+	NotifyError string     `json:"notify_error,omitempty"`
+}
+
+// AlertFilter narrows GET /alerts. Zero values mean "no filter".
+type AlertFilter struct {
+	RuleID   int64
+	Host     string
+	Severity string
+	Since    time.Time
+	Limit    int
+	Offset   int
+}
+
+// AlertSilence suppresses notifications until Until for alerts of RuleID (0
+// for any rule) on hosts matching the Host glob (empty for any host).
+// Silenced alerts are still stored.
+type AlertSilence struct {
+	ID        int64     `json:"id"`
+	RuleID    int64     `json:"rule_id,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	Until     time.Time `json:"until"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertChannel is a named notifier destination. Config is type specific, see
+// the notify package.
+type AlertChannel struct {
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+const (
+	BaselineScopeHost  = "host"
+	BaselineScopeGroup = "group"
+
+	ViolationUnexpectedOpen    = "unexpected_open"
+	ViolationExpectedMissing   = "expected_missing"
+	ViolationUnexpectedService = "unexpected_service"
+
+	ViolationOpen         = "open"
+	ViolationAcknowledged = "acknowledged"
+	ViolationResolved     = "resolved"
+)
+
+// Baseline lists the ports a host, or every member of a group, may expose.
+// Any other open port is a violation, as is a missing non-optional port.
+type Baseline struct {
+	ID          int64          `json:"id"`
+	Scope       string         `json:"scope"`
+	Target      string         `json:"target"`
+	Ports       []BaselinePort `json:"ports"`
+	Description string         `json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// BaselinePort is one allowed port. Service, when set, must match what nmap
+// reports; Optional ports may be closed without a violation.
+type BaselinePort struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Service  string `json:"service,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Violation is a deviation of a host's scan results from its baseline.
+type Violation struct {
+	ID         int64      `json:"id"`
+	BaselineID int64      `json:"baseline_id"`
+	Host       string     `json:"host"`
+	Kind       string     `json:"kind"`
+	Port       int        `json:"port"`
+	Protocol   string     `json:"protocol"`
+	Detail     string     `json:"detail,omitempty"`
+	Status     string     `json:"status"`
+	ScanID     string     `json:"scan_id"`
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `json:"last_seen"`
+	AckedBy    string     `json:"acked_by,omitempty"`
+	AckedAt    *time.Time `json:"acked_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// ViolationFilter narrows GET /violations. Zero values mean "no filter".
+type ViolationFilter struct {
+	Host       string
+	Status     string
+	Kind       string
+	BaselineID int64
+	Limit      int
+	Offset     int
+}
+
+// ViolationAction acknowledges or resolves a violation.
+type ViolationAction struct {
+	By   string `json:"by"`
+	Note string `json:"note,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// AssetGroup is a named set of scan targets. Its members are the static Hosts
+// plus every active inventory host matching Match.
+type AssetGroup struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Hosts       []string    `json:"hosts,omitempty"`
+	Match       *GroupMatch `json:"match,omitempty"`
+	// Windows restrict when members may be scanned, whichever scan targets
+	// them.
+	Windows   []MaintenanceWindow `json:"windows,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// GroupMatch selects inventory hosts dynamically. A host must satisfy every
+// non-empty criterion: carry all Tags, sit in any of CIDRs (by name or
+// resolved IP) and match any of NamePatterns (shell globs, e.g. "web-*").
+type GroupMatch struct {
+	Tags         []string `json:"tags,omitempty"`
+	CIDRs        []string `json:"cidrs,omitempty"`
+	NamePatterns []string `json:"name_patterns,omitempty"`
+}
+
+const (
+	WindowAllow = "allow"
+	WindowDeny  = "deny"
+)
+
+// MaintenanceWindow is a daily time range in which scanning a group's
+// members is allowed or denied. A host may be scanned when no deny window of
+// its groups contains the time and, for every group with allow windows, one
+// of them does. End before Start wraps past midnight; Start equal to End is
+// the whole day. Days (mon..sun) is the day the range starts on; empty means
+// every day.
+type MaintenanceWindow struct {
+	Group    string   `json:"group,omitempty"` // set on queued jobs
+	Mode     string   `json:"mode" enums:"allow,deny"`
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start" example:"09:00"`
+	End      string   `json:"end" example:"18:00"`
+	Timezone string   `json:"timezone,omitempty" example:"Europe/Berlin"` // IANA name, default UTC
+}
+
+// Scan is the record of a submitted scan and how its targets were resolved.
+type Scan struct {
+	ScanID         string              `json:"scan_id"`
+	CreatedAt      time.Time           `json:"created_at"`
+	RequestedHosts []string            `json:"requested_hosts"`
+	Groups         map[string][]string `json:"groups,omitempty"`
+	Hosts          []string            `json:"hosts"`
+	Source         string              `json:"source,omitempty"`
+	Priority       string              `json:"priority,omitempty"`
+	NotBefore      *time.Time          `json:"not_before,omitempty"`
+	NotAfter       *time.Time          `json:"not_after,omitempty"`
+	Ports          string              `json:"ports,omitempty"`
+	ShardSize      int                 `json:"shard_size,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	HostActive = "active"
+	HostStale  = "stale"
+)
+
+// Host is the inventory entry derived from a host's scan history.
+type Host struct {
+	Host        string            `json:"host"`
+	FirstSeen   time.Time         `json:"first_seen"`
+	LastScanned time.Time         `json:"last_scanned"`
+	LastSeenUp  *time.Time        `json:"last_seen_up,omitempty"`
+	LastScanID  string            `json:"last_scan_id"`
+	OpenPorts   []int             `json:"open_ports"`
+	Ports       []PortInfo        `json:"ports,omitempty"`
+	ResolvedIPs []string          `json:"resolved_ips"`
+	Tags        []string          `json:"tags"`
+	Owner       string            `json:"owner"`
+	Metadata    map[string]string `json:"metadata"`
+	Lifecycle   string            `json:"lifecycle"`
+}
+
+// HostFilter narrows GET /hosts. Zero values mean "no filter".
+type HostFilter struct {
+	Tag       string
+	Owner     string
+	Lifecycle string
+	Port      int
+	Search    string
+	Limit     int
+	Offset    int
+}
+
+// HostUpdate carries user-managed inventory metadata. Nil fields are left unchanged.
+type HostUpdate struct {
+	Tags     *[]string          `json:"tags,omitempty"`
+	Owner    *string            `json:"owner,omitempty"`
+	Metadata *map[string]string `json:"metadata,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ImportSummary describes the scan record created from an uploaded nmap XML
+// file.
+type ImportSummary struct {
+	ScanID    string    `json:"scan_id"`
+	Source    string    `json:"source"`
+	StartedAt time.Time `json:"started_at"`
+	Hosts     []string  `json:"hosts"`
+	Failed    []string  `json:"failed,omitempty"`
+	Skipped   int       `json:"skipped,omitempty"` // hosts without address or name
+}
@@ -0,0 +1,18 @@
+package models
+
+// RetentionReport is what one pass of result retention deleted or, in a dry
+// run, would delete.
+type RetentionReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Results int64            `json:"results"` // results deleted, or that would be
+	Owners  []OwnerRetention `json:"owners"`  // owners with results to delete, by name
+}
+
+// OwnerRetention is the share of one inventory owner's hosts in a
+// RetentionReport; Owner is empty for hosts without one.
+type OwnerRetention struct {
+	Owner      string `json:"owner"`
+	FullDetail string `json:"full_detail"` // how long these hosts keep every result, e.g. "2160h0m0s"
+	Hosts      int    `json:"hosts"`
+	Results    int64  `json:"results"`
+}
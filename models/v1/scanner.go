@@ -1,16 +1,75 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Queue priorities, most urgent first. Jobs of a higher priority always start
+// before those of a lower one; within a priority, scans take turns.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// Priorities lists the priorities in the order the queue serves them.
+var Priorities = []string{PriorityHigh, PriorityNormal, PriorityLow}
 
 type ScanRequest struct {
-	Hosts []string `json:"hosts"`
+	Hosts  []string `json:"hosts"`
+	Groups []string `json:"groups,omitempty"`
+	// Priority is high, normal (the default) or low.
+	Priority string `json:"priority,omitempty" enums:"high,normal,low"`
+	// NotBefore delays the scan; hosts still waiting at NotAfter are skipped.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	// Coalesce attaches hosts that already have an identical pending or
+	// running job to that job instead of queueing another nmap run.
+	Coalesce bool `json:"coalesce,omitempty"`
+	// Ports overrides the ports nmap scans, e.g. "1-65535" or "22,80,8000-8100".
+	Ports string `json:"ports,omitempty" example:"1-65535"`
+	// ShardSize splits Ports into jobs of at most this many ports each, run
+	// in parallel and merged into one result per host; 0 does not split.
+	ShardSize int `json:"shard_size,omitempty" example:"4096"`
+}
+
+// IdempotentResponse is the response recorded for an Idempotency-Key and
+// replayed to retries of the same request.
+type IdempotentResponse struct {
+	Fingerprint string          `json:"fingerprint"`
+	Status      int             `json:"status,omitempty"` // 0 while the first request is in flight
+	Body        json.RawMessage `json:"body,omitempty"`
 }
 
 type ScanResult struct {
-	ScanID    string    `json:"scan_id"`
-	Host      string    `json:"host"`
-	ScannedAt time.Time `json:"scanned_at"`
-	OpenPorts []int     `json:"open_ports"`
+	ScanID    string     `json:"scan_id"`
+	Host      string     `json:"host"`
+	ScannedAt time.Time  `json:"scanned_at"`
+	OpenPorts []int      `json:"open_ports"`
+	Ports     []PortInfo `json:"ports,omitempty"`
+	HostState string     `json:"host_state,omitempty"`
+	Addresses []string   `json:"addresses,omitempty"`
+	// Incomplete lists the port ranges of a sharded scan whose shards failed;
+	// the result says nothing about those ports.
+	Incomplete []string `json:"incomplete,omitempty"`
+	// SeenAgain lists when later scans found exactly this again, with
+	// retention.collapse_unchanged; they stored no result of their own.
+	SeenAgain []time.Time `json:"seen_again_at,omitempty"`
+	// Error is why nmap produced no result; such a result is not stored and
+	// its error becomes the host's failure reason.
+	Error string `json:"error,omitempty"`
+}
+
+// PortInfo is a single port observation with the service nmap reported on it.
+type PortInfo struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Service  string `json:"service,omitempty"`
+	Product  string `json:"product,omitempty"`
+	Version  string `json:"version,omitempty"`
 }
 
 type PortDiff struct {
@@ -23,4 +82,55 @@ type PortDiff struct {
 type ScanJob struct {
 	ScanID string `json:"scan_id"`
 	Host   string `json:"host"`
+	// TraceContext holds the W3C traceparent, tracestate and baggage of the
+	// request that queued the job, so the worker's spans join its trace.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// EnqueuedAt is when the job was pushed, in Unix nanoseconds; the worker
+	// uses it to measure queue wait.
+	EnqueuedAt int64 `json:"enqueued_at,omitempty"`
+	// Zone is the network zone whose agents scan the host; empty means the
+	// central workers.
+	Zone string `json:"zone,omitempty"`
+	// Priority is the queue level the job waits in; empty means normal.
+	Priority string `json:"priority,omitempty"`
+	// NotBefore, NotAfter and Windows limit when the job may run; Windows are
+	// those of the asset groups the host belonged to when it was queued.
+	NotBefore *time.Time          `json:"not_before,omitempty"`
+	NotAfter  *time.Time          `json:"not_after,omitempty"`
+	Windows   []MaintenanceWindow `json:"windows,omitempty"`
+	// MaxRate is the packets per second the scan may send, granted from the
+	// global budget when the job was claimed; 0 means unlimited.
+	MaxRate int `json:"max_rate,omitempty"`
+	// Ports is the nmap port list; empty means nmap's default ports.
+	Ports string `json:"ports,omitempty"`
+	// Shard is the job's index among the Shards jobs one host's port range
+	// was split into; Shards is 0 for an unsplit job.
+	Shard  int `json:"shard,omitempty"`
+	Shards int `json:"shards,omitempty"`
+}
+
+// RunKey identifies a job among the running ones: its scan and host, plus the
+// shard index when the host's ports were split.
+func (j ScanJob) RunKey() string {
+	if j.Shards > 1 {
+		return j.ScanID + "|" + j.Host + "|" + strconv.Itoa(j.Shard)
+	}
+	return j.ScanID + "|" + j.Host
+}
+
+// QueuePosition is where a scan's waiting jobs stand in one queue (the
+// central workers' or a zone's). Positions count the jobs that start before,
+// assuming no more urgent work arrives; estimates extrapolate the queue's
+// throughput over the last ten minutes and are absent while it is unknown.
+type QueuePosition struct {
+	Zone     string `json:"zone,omitempty"`
+	Priority string `json:"priority"`
+	// Queued is how many of the scan's jobs are still waiting.
+	Queued int `json:"queued"`
+	// Position is the number of jobs ahead of the scan's next job.
+	Position int `json:"position"`
+	// LastPosition is the number of jobs ahead of its last waiting job.
+	LastPosition       int        `json:"last_position"`
+	EstimatedStart     *time.Time `json:"estimated_start,omitempty"`
+	EstimatedLastStart *time.Time `json:"estimated_last_start,omitempty"`
 }
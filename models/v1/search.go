@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// PortSearchQuery filters open ports across all scanned hosts.
+// A zero SeenWithin means "as of each host's latest scan".
+type PortSearchQuery struct {
+	Port       int           `json:"port,omitempty"`
+	Protocol   string        `json:"protocol,omitempty"`
+	Service    string        `json:"service,omitempty"`
+	Product    string        `json:"product,omitempty"`
+	SeenWithin time.Duration `json:"seen_within,omitempty"`
+	Limit      int           `json:"limit,omitempty"`
+}
+
+// PortSearchHit is a host exposing a matching port, with the scan that observed it.
+type PortSearchHit struct {
+	Host      string    `json:"host"`
+	ScanID    string    `json:"scan_id"`
+	ScannedAt time.Time `json:"scanned_at"`
+	PortInfo
+}
+
+// ResultFilter selects stored scan results. Zero values mean "no filter".
+type ResultFilter struct {
+	ScanID string
+	Host   string
+	Since  time.Time
+	Limit  int
+}
+
+// ExportQuery selects what GET /export streams: every result of a scan, a
+// host's history, or the evidence of a port search. Exactly one is set.
+type ExportQuery struct {
+	ScanID string
+	Host   string
+	Search *PortSearchQuery
+	Limit  int
+}
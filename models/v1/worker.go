@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Worker states, in the order a job moves a worker through them.
+const (
+	WorkerIdle     = "idle"     // not polling, e.g. backing off after a queue error
+	WorkerWaiting  = "waiting"  // blocked on the queue for the next job
+	WorkerScanning = "scanning" // nmap is running
+	WorkerStoring  = "storing"  // persisting the result
+	WorkerStopped  = "stopped"  // exited during shutdown
+)
+
+// WorkerStatus is a snapshot of one worker goroutine.
+type WorkerStatus struct {
+	ID        int        `json:"id"`
+	State     string     `json:"state"`
+	Since     time.Time  `json:"since"` // when the worker entered State
+	ScanID    string     `json:"scan_id,omitempty"`
+	Host      string     `json:"host,omitempty"`
+	BatchSize int        `json:"batch_size,omitempty"` // jobs in the current nmap run, when more than one
+	StartedAt *time.Time `json:"started_at,omitempty"` // when the current job started
+	NmapPID   int        `json:"nmap_pid,omitempty"`
+	Jobs      int64      `json:"jobs"` // jobs finished since start
+}
@@ -0,0 +1,115 @@
+package nmap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// Writer streams stored results as nmap XML, one <host> at a time, so the
+// output can be fed to existing nmap tooling such as ndiff.
+type Writer struct {
+	w     io.Writer
+	enc   *xml.Encoder
+	start time.Time
+	up    int
+	down  int
+}
+
+// NewWriter writes the XML prolog and the opening <nmaprun> element.
+func NewWriter(w io.Writer, args string, start time.Time) (*Writer, error) {
+	_, err := fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+		"<!DOCTYPE nmaprun>\n"+
+		"<nmaprun scanner=\"nmap\" args=\"%s\" start=\"%d\" startstr=\"%s\" version=\"7.94\" xmloutputversion=\"1.05\">\n",
+		xmlEscape(args), start.Unix(), start.UTC().Format(time.ANSIC))
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, enc: xml.NewEncoder(w), start: start}, nil
+}
+
+// WriteResult appends one stored result as a <host> element.
+func (wr *Writer) WriteResult(res models.ScanResult) error {
+	h := HostFromResult(res)
+	if h.Status.State == "up" {
+		wr.up++
+	} else {
+		wr.down++
+	}
+	if err := wr.enc.EncodeElement(h, xml.StartElement{Name: xml.Name{Local: "host"}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(wr.w, "\n")
+	return err
+}
+
+// Close writes <runstats> and closes the <nmaprun> element.
+func (wr *Writer) Close() error {
+	end := time.Now()
+	_, err := fmt.Fprintf(wr.w, "<runstats><finished time=\"%d\" timestr=\"%s\" elapsed=\"%.2f\" exit=\"success\"/>"+
+		"<hosts up=\"%d\" down=\"%d\" total=\"%d\"/></runstats>\n</nmaprun>\n",
+		end.Unix(), end.UTC().Format(time.ANSIC), end.Sub(wr.start).Seconds(), wr.up, wr.down, wr.up+wr.down)
+	return err
+}
+
+// HostFromResult converts a stored result back into nmap's host structure.
+// Results stored before per-port details existed are emitted as open tcp ports.
+func HostFromResult(res models.ScanResult) Host {
+	state := res.HostState
+	if state == "" {
+		state = "up"
+	}
+	h := Host{
+		StartTime: res.ScannedAt.Unix(),
+		EndTime:   res.ScannedAt.Unix(),
+		Status:    Status{State: state, Reason: "stored"},
+	}
+
+	if ip := net.ParseIP(res.Host); ip != nil {
+		addrType := "ipv4"
+		if ip.To4() == nil {
+			addrType = "ipv6"
+		}
+		h.Addresses = []Address{{Addr: res.Host, AddrType: addrType}}
+	} else {
+		h.Hostnames = []Hostname{{Name: res.Host, Type: "user"}}
+		for _, a := range res.Addresses {
+			addrType := "ipv4"
+			if ip := net.ParseIP(a); ip != nil && ip.To4() == nil {
+				addrType = "ipv6"
+			}
+			h.Addresses = append(h.Addresses, Address{Addr: a, AddrType: addrType})
+		}
+	}
+
+	ports := res.Ports
+	if len(ports) == 0 {
+		for _, p := range res.OpenPorts {
+			ports = append(ports, models.PortInfo{Port: p, Protocol: "tcp", State: "open"})
+		}
+	}
+	for _, p := range ports {
+		pState := p.State
+		if pState == "" {
+			pState = "open"
+		}
+		h.Ports = append(h.Ports, Port{
+			Protocol: p.Protocol,
+			PortID:   p.Port,
+			State:    State{State: pState, Reason: "stored"},
+			Service:  Service{Name: p.Service, Product: p.Product, Version: p.Version},
+		})
+	}
+	return h
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
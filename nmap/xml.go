@@ -0,0 +1,238 @@
+package nmap
+
+import (
+	"encoding/xml"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// Run mirrors the root <nmaprun> element of nmap's XML output (-oX).
+type Run struct {
+	XMLName  xml.Name `xml:"nmaprun"`
+	Scanner  string   `xml:"scanner,attr"`
+	Args     string   `xml:"args,attr"`
+	Start    int64    `xml:"start,attr"`
+	Version  string   `xml:"version,attr"`
+	Hosts    []Host   `xml:"host"`
+	RunStats RunStats `xml:"runstats"`
+}
+
+type RunStats struct {
+	Finished struct {
+		Time    int64  `xml:"time,attr"`
+		Elapsed string `xml:"elapsed,attr"`
+		Exit    string `xml:"exit,attr"`
+	} `xml:"finished"`
+}
+
+type Host struct {
+	StartTime int64      `xml:"starttime,attr"`
+	EndTime   int64      `xml:"endtime,attr"`
+	Status    Status     `xml:"status"`
+	Addresses []Address  `xml:"address"`
+	Hostnames []Hostname `xml:"hostnames>hostname"`
+	Ports     []Port     `xml:"ports>port"`
+	Extra     []Extra    `xml:"ports>extraports"`
+}
+
+// Extra summarises ports nmap collapsed into a single <extraports> line.
+type Extra struct {
+	State string `xml:"state,attr"`
+	Count int    `xml:"count,attr"`
+}
+
+type Status struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr,omitempty"`
+}
+
+type Address struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type Hostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type Port struct {
+	Protocol string  `xml:"protocol,attr"`
+	PortID   int     `xml:"portid,attr"`
+	State    State   `xml:"state"`
+	Service  Service `xml:"service"`
+}
+
+type State struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr,omitempty"`
+}
+
+type Service struct {
+	Name    string `xml:"name,attr,omitempty"`
+	Product string `xml:"product,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+}
+
+// Parse decodes nmap XML output.
+func Parse(r io.Reader) (*Run, error) {
+	var run Run
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Split assigns the run's hosts to the targets they were scanned for, in
+// targets order. A host belongs to a target naming its user supplied hostname
+// (case-insensitively) or its address, and to a CIDR target containing its
+// address; it may belong to several. Targets nmap reported nothing for get
+// no hosts.
+func (r Run) Split(targets []string) [][]Host {
+	out := make([][]Host, len(targets))
+	nets := make([]*net.IPNet, len(targets))
+	for i, t := range targets {
+		if _, n, err := net.ParseCIDR(t); err == nil {
+			nets[i] = n
+		}
+	}
+	for _, h := range r.Hosts {
+		addr := h.Address()
+		ip := net.ParseIP(addr)
+		name := ""
+		for _, n := range h.Hostnames {
+			if n.Type == "user" {
+				name = n.Name
+			}
+		}
+		for i, t := range targets {
+			switch {
+			case nets[i] != nil:
+				if ip == nil || !nets[i].Contains(ip) {
+					continue
+				}
+			case name != "" && strings.EqualFold(name, t):
+			case ip != nil && ip.Equal(net.ParseIP(t)):
+			default:
+				continue
+			}
+			out[i] = append(out[i], h)
+		}
+	}
+	return out
+}
+
+// StartedAt returns when the run started, or the zero time if unknown.
+func (r Run) StartedAt() time.Time {
+	if r.Start > 0 {
+		return time.Unix(r.Start, 0).UTC()
+	}
+	return time.Time{}
+}
+
+// FinishedAt returns when the run finished, or the zero time if unknown.
+func (r Run) FinishedAt() time.Time {
+	if r.RunStats.Finished.Time > 0 {
+		return time.Unix(r.RunStats.Finished.Time, 0).UTC()
+	}
+	return time.Time{}
+}
+
+// Address returns the first IPv4/IPv6 address reported for the host.
+func (h Host) Address() string {
+	for _, a := range h.Addresses {
+		if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+			return a.Addr
+		}
+	}
+	return ""
+}
+
+// Hostname returns the user supplied hostname, falling back to the PTR record.
+func (h Host) Hostname() string {
+	for _, n := range h.Hostnames {
+		if n.Type == "user" {
+			return n.Name
+		}
+	}
+	if len(h.Hostnames) > 0 {
+		return h.Hostnames[0].Name
+	}
+	return ""
+}
+
+// Target returns the name the host was scanned as: the user supplied
+// hostname if there is one, otherwise its address. PTR names are ignored so
+// imported results line up with scans of the same IP.
+func (h Host) Target() string {
+	for _, n := range h.Hostnames {
+		if n.Type == "user" {
+			return n.Name
+		}
+	}
+	return h.Address()
+}
+
+// StartedAt returns when nmap started the host, or the zero time if unknown.
+func (h Host) StartedAt() time.Time {
+	if h.StartTime > 0 {
+		return time.Unix(h.StartTime, 0).UTC()
+	}
+	return time.Time{}
+}
+
+// Up reports whether the host actually responded. With -Pn nmap marks every
+// target up (reason "user-set"), so any open or closed port counts as evidence.
+func (h Host) Up() bool {
+	if h.Status.State != "up" {
+		return false
+	}
+	if h.Status.Reason != "user-set" {
+		return true
+	}
+	for _, p := range h.Ports {
+		if p.State.State == "open" || p.State.State == "closed" {
+			return true
+		}
+	}
+	for _, e := range h.Extra {
+		if e.State == "closed" && e.Count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// PortInfos converts every port nmap reported as open into the API model.
+func (h Host) PortInfos() []models.PortInfo {
+	var ports []models.PortInfo
+	for _, p := range h.Ports {
+		if p.State.State != "open" {
+			continue
+		}
+		ports = append(ports, models.PortInfo{
+			Port:     p.PortID,
+			Protocol: p.Protocol,
+			State:    p.State.State,
+			Service:  p.Service.Name,
+			Product:  p.Service.Product,
+			Version:  p.Service.Version,
+		})
+	}
+	return ports
+}
+
+// ScannedAt returns when nmap finished the host, or the zero time if unknown.
+func (h Host) ScannedAt() time.Time {
+	if h.EndTime > 0 {
+		return time.Unix(h.EndTime, 0).UTC()
+	}
+	if h.StartTime > 0 {
+		return time.Unix(h.StartTime, 0).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,86 @@
+package nmap_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"nmap-rest-api/nmap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_OpenPortsWithServices(t *testing.T) {
+	f, err := os.Open("testdata/scanme.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	run, err := nmap.Parse(f)
+	require.NoError(t, err)
+	require.Len(t, run.Hosts, 1)
+
+	h := run.Hosts[0]
+	assert.Equal(t, "45.33.32.156", h.Address())
+	assert.Equal(t, "scanme.nmap.org", h.Hostname())
+	assert.Equal(t, "scanme.nmap.org", h.Target())
+	assert.Equal(t, time.Unix(1715238042, 0).UTC(), h.ScannedAt())
+	assert.True(t, h.Up())
+
+	ports := h.PortInfos()
+	require.Len(t, ports, 2) // filtered port 25 is dropped
+	assert.Equal(t, 22, ports[0].Port)
+	assert.Equal(t, "tcp", ports[0].Protocol)
+	assert.Equal(t, "ssh", ports[0].Service)
+	assert.Equal(t, "OpenSSH", ports[0].Product)
+	assert.Equal(t, 80, ports[1].Port)
+	assert.Equal(t, "Apache httpd", ports[1].Product)
+}
+
+func TestParse_InvalidXML(t *testing.T) {
+	_, err := nmap.Parse(strings.NewReader("Starting Nmap 7.94"))
+	assert.Error(t, err)
+}
+
+func TestHost_UpIgnoresUserSetWithoutResponses(t *testing.T) {
+	run, err := nmap.Parse(strings.NewReader(`<nmaprun><host>
+		<status state="up" reason="user-set"/>
+		<ports><extraports state="filtered" count="1000"/></ports>
+	</host></nmaprun>`))
+	require.NoError(t, err)
+	require.Len(t, run.Hosts, 1)
+	assert.False(t, run.Hosts[0].Up())
+}
+
+func TestHost_TargetPrefersUserHostname(t *testing.T) {
+	run, err := nmap.Parse(strings.NewReader(`<nmaprun start="1715238000"><host>
+		<address addr="10.0.0.5" addrtype="ipv4"/>
+		<hostnames><hostname name="db.internal" type="PTR"/></hostnames>
+	</host></nmaprun>`))
+	require.NoError(t, err)
+	require.Len(t, run.Hosts, 1)
+	assert.Equal(t, "10.0.0.5", run.Hosts[0].Target())
+	assert.Equal(t, time.Unix(1715238000, 0).UTC(), run.StartedAt())
+	assert.True(t, run.FinishedAt().IsZero())
+}
+
+func TestRun_SplitAssignsHostsToTargets(t *testing.T) {
+	f, err := os.Open("testdata/batch.xml")
+	require.NoError(t, err)
+	defer f.Close()
+	run, err := nmap.Parse(f)
+	require.NoError(t, err)
+
+	split := run.Split([]string{"web.example.com", "10.0.0.7", "10.0.1.0/30", "gone.example.com"})
+
+	require.Len(t, split, 4)
+	require.Len(t, split[0], 1)
+	assert.Equal(t, "10.0.0.5", split[0][0].Address())
+	require.Len(t, split[1], 1)
+	assert.Equal(t, "10.0.0.7", split[1][0].Address())
+	require.Len(t, split[2], 2)
+	assert.Equal(t, "10.0.1.1", split[2][0].Address())
+	assert.Equal(t, "10.0.1.2", split[2][1].Address())
+	assert.Empty(t, split[3])
+}
@@ -0,0 +1,66 @@
+// Package notify delivers fired alerts to external channels. Channel types
+// register a Factory; the alert engine builds notifiers from stored channel
+// configuration by type name.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// Notifier sends a single alert to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, alert models.Alert) error
+}
+
+// Factory builds a Notifier from a channel's JSON configuration.
+type Factory func(config json.RawMessage) (Notifier, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a channel type available to New. It is meant to be called
+// from init functions.
+func Register(channelType string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[channelType] = f
+}
+
+// New builds a notifier for a configured channel.
+func New(ch models.AlertChannel) (Notifier, error) {
+	mu.RLock()
+	f, ok := factories[ch.Type]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown channel type %q", ch.Type)
+	}
+	return f(ch.Config)
+}
+
+// Subject is the one-line summary used by text based channels.
+func Subject(a models.Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(a.Severity), a.RuleName, a.Host)
+}
+
+// Body is the plain text description used by text based channels.
+func Body(a models.Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", a.Message)
+	fmt.Fprintf(&b, "Rule:     %s (#%d)\n", a.RuleName, a.RuleID)
+	fmt.Fprintf(&b, "Severity: %s\n", a.Severity)
+	fmt.Fprintf(&b, "Host:     %s\n", a.Host)
+	fmt.Fprintf(&b, "Scan:     %s\n", a.ScanID)
+	fmt.Fprintf(&b, "Fired at: %s\n", a.FiredAt.UTC().Format("2006-01-02T15:04:05Z"))
+	if len(a.Ports) > 0 {
+		fmt.Fprintf(&b, "Ports:    %v\n", a.Ports)
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+	"nmap-rest-api/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAlert = models.Alert{
+	ID:       7,
+	RuleID:   3,
+	RuleName: "telnet open",
+	Severity: models.SeverityHigh,
+	Host:     "10.0.0.5",
+	ScanID:   "scan-1",
+	Message:  "10.0.0.5 has forbidden port(s) [23] open",
+	Ports:    []int{23},
+	FiredAt:  time.Date(2025, 5, 9, 7, 0, 0, 0, time.UTC),
+}
+
+func channel(t *testing.T, typ string, cfg interface{}) notify.Notifier {
+	raw, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	n, err := notify.New(models.AlertChannel{Name: "test", Type: typ, Config: raw})
+	require.NoError(t, err)
+	return n
+}
+
+func TestWebhook_PostsAlertJSON(t *testing.T) {
+	var got models.Alert
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := channel(t, "webhook", notify.WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}})
+
+	require.NoError(t, n.Notify(context.Background(), testAlert))
+	assert.Equal(t, "Bearer x", auth)
+	assert.Equal(t, testAlert.ScanID, got.ScanID)
+	assert.Equal(t, []int{23}, got.Ports)
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n := channel(t, "webhook", notify.WebhookConfig{URL: srv.URL})
+	assert.Error(t, n.Notify(context.Background(), testAlert))
+}
+
+// fakeSMTP accepts a single message and returns its DATA section.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	data := make(chan string, 1)
+
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				data <- b.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestSMTP_SendsMail(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n := channel(t, "smtp", notify.SMTPConfig{Addr: addr, From: "nmap@example.com", To: []string{"soc@example.com"}})
+
+	require.NoError(t, n.Notify(context.Background(), testAlert))
+
+	select {
+	case msg := <-data:
+		assert.Contains(t, msg, "Subject: [HIGH] telnet open: 10.0.0.5")
+		assert.Contains(t, msg, "To: soc@example.com")
+		assert.Contains(t, msg, "Scan:     scan-1")
+	case <-time.After(2 * time.Second):
+		t.Fatal("fake SMTP server received no message")
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	n := channel(t, "syslog", notify.SyslogConfig{Addr: pc.LocalAddr().String()})
+	require.NoError(t, n.Notify(context.Background(), testAlert))
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	l, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:l])
+	assert.True(t, strings.HasPrefix(msg, "<131>1 2025-05-09T07:00:00Z "), msg) // local0.err
+	assert.Contains(t, msg, "[HIGH] telnet open: 10.0.0.5 scan_id=scan-1")
+}
+
+func TestNew_UnknownTypeAndBadConfig(t *testing.T) {
+	_, err := notify.New(models.AlertChannel{Type: "pager", Config: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+
+	_, err = notify.New(models.AlertChannel{Type: "smtp", Config: json.RawMessage(`{"addr":"localhost:25"}`)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// SMTPConfig mails alerts through Addr (host:port). Username/Password enable
+// PLAIN auth, which net/smtp only allows over TLS or to localhost.
+type SMTPConfig struct {
+	Addr     string   `json:"addr"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+type smtpNotifier struct {
+	cfg SMTPConfig
+}
+
+func init() {
+	Register("smtp", func(raw json.RawMessage) (Notifier, error) {
+		var cfg SMTPConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Addr == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("smtp: addr, from and to are required")
+		}
+		return &smtpNotifier{cfg: cfg}, nil
+	})
+}
+
+func (s *smtpNotifier) Notify(ctx context.Context, a models.Alert) error {
+	host, _, err := net.SplitHostPort(s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.cfg.From,
+		"To: " + strings.Join(s.cfg.To, ", "),
+		"Subject: " + Subject(a),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		strings.ReplaceAll(Body(a), "\n", "\r\n"),
+	}, "\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.cfg.Addr, auth, s.cfg.From, s.cfg.To, []byte(msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
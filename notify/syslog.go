@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// SyslogConfig sends RFC 5424 messages over udp or tcp to Addr (host:port).
+type SyslogConfig struct {
+	Network string `json:"network,omitempty"` // udp (default) or tcp
+	Addr    string `json:"addr"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+type syslogNotifier struct {
+	cfg SyslogConfig
+}
+
+// facility local0, see RFC 5424 section 6.2.1.
+const syslogFacility = 16
+
+func init() {
+	Register("syslog", func(raw json.RawMessage) (Notifier, error) {
+		cfg := SyslogConfig{Network: "udp", Tag: "nmap-api"}
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Addr == "" {
+			return nil, fmt.Errorf("syslog: addr is required")
+		}
+		if cfg.Network != "udp" && cfg.Network != "tcp" {
+			return nil, fmt.Errorf("syslog: network must be udp or tcp")
+		}
+		return &syslogNotifier{cfg: cfg}, nil
+	})
+}
+
+func (s *syslogNotifier) Notify(ctx context.Context, a models.Alert) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.cfg.Network, s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	hostname, _ := os.Hostname()
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s scan_id=%s",
+		syslogFacility*8+syslogSeverity(a.Severity),
+		a.FiredAt.UTC().Format(time.RFC3339),
+		nilValue(hostname), nilValue(s.cfg.Tag), os.Getpid(),
+		Subject(a), a.ScanID)
+	if s.cfg.Network == "tcp" {
+		// octet counting framing, RFC 6587
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	_, err = conn.Write([]byte(msg))
+	return err
+}
+
+func syslogSeverity(severity string) int {
+	switch strings.ToLower(severity) {
+	case models.SeverityCritical:
+		return 2
+	case models.SeverityHigh:
+		return 3
+	case models.SeverityMedium:
+		return 4
+	case models.SeverityLow:
+		return 5
+	default:
+		return 6
+	}
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	models "nmap-rest-api/models/v1"
+)
+
+// WebhookConfig posts the alert as JSON to URL with optional extra headers.
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type webhook struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+func init() {
+	Register("webhook", func(raw json.RawMessage) (Notifier, error) {
+		var cfg WebhookConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook: url is required")
+		}
+		return &webhook{cfg: cfg, client: http.DefaultClient}, nil
+	})
+}
+
+func (w *webhook) Notify(ctx context.Context, a models.Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s returned %s", w.cfg.URL, resp.Status)
+	}
+	return nil
+}
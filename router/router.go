@@ -3,6 +3,7 @@ package router
 import (
 	apiv1 "nmap-rest-api/api/v1"
 	"nmap-rest-api/middleware"
+	"nmap-rest-api/telemetry"
 
 	_ "nmap-rest-api/docs" // or replace with your actual module name
 
@@ -13,13 +14,76 @@ import (
 )
 
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
-	r.Use(middleware.Logger())
+	r := SetupProbeRouter()
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	r.Use(otelgin.Middleware("nmap-api"))
 	r.POST("/scan", apiv1.HandleScanRequest)
 	r.GET("/results/:host", apiv1.GetScanResults)
 	r.GET("/scan/status/:scan_id", apiv1.GetScanStatus)
 	r.GET("/diff/:host", apiv1.GetScanDiff)
+	r.POST("/import", apiv1.ImportNmapXML)
+	r.GET("/ports/:port/hosts", apiv1.GetHostsByPort)
+	r.GET("/search", apiv1.SearchPorts)
+	r.GET("/export", apiv1.ExportResults)
+	r.GET("/hosts", apiv1.ListHosts)
+	r.GET("/hosts/:host", apiv1.GetHost)
+	r.PATCH("/hosts/:host", apiv1.UpdateHost)
+	r.POST("/groups", apiv1.CreateGroup)
+	r.GET("/groups", apiv1.ListGroups)
+	r.GET("/groups/:name", apiv1.GetGroup)
+	r.PUT("/groups/:name", apiv1.ReplaceGroup)
+	r.DELETE("/groups/:name", apiv1.DeleteGroup)
+	r.GET("/groups/:name/hosts", apiv1.ResolveGroup)
+	r.POST("/baselines", apiv1.CreateBaseline)
+	r.GET("/baselines", apiv1.ListBaselines)
+	r.POST("/baselines/from-host/:host", apiv1.CreateBaselineFromHost)
+	r.GET("/baselines/:id", apiv1.GetBaseline)
+	r.PUT("/baselines/:id", apiv1.ReplaceBaseline)
+	r.DELETE("/baselines/:id", apiv1.DeleteBaseline)
+	r.GET("/violations", apiv1.ListViolations)
+	r.POST("/violations/:id/ack", apiv1.AcknowledgeViolation)
+	r.POST("/violations/:id/resolve", apiv1.ResolveViolation)
+	r.POST("/alert-rules", apiv1.CreateAlertRule)
+	r.GET("/alert-rules", apiv1.ListAlertRules)
+	r.GET("/alert-rules/:id", apiv1.GetAlertRule)
+	r.PUT("/alert-rules/:id", apiv1.ReplaceAlertRule)
+	r.DELETE("/alert-rules/:id", apiv1.DeleteAlertRule)
+	r.GET("/alerts", apiv1.ListAlerts)
+	r.POST("/alert-silences", apiv1.CreateSilence)
+	r.GET("/alert-silences", apiv1.ListSilences)
+	r.DELETE("/alert-silences/:id", apiv1.DeleteSilence)
+	r.POST("/alert-channels", apiv1.CreateChannel)
+	r.GET("/alert-channels", apiv1.ListChannels)
+	r.DELETE("/alert-channels/:name", apiv1.DeleteChannel)
+	r.POST("/zones", apiv1.CreateZone)
+	r.GET("/zones", apiv1.ListZones)
+	r.GET("/zones/:name", apiv1.GetZone)
+	r.PUT("/zones/:name", apiv1.ReplaceZone)
+	r.DELETE("/zones/:name", apiv1.DeleteZone)
+	r.GET("/agents", apiv1.ListAgents)
+	r.DELETE("/agents/:id", apiv1.DeleteAgent)
+	r.GET("/admin/retention", apiv1.GetRetentionReport)
+	r.POST("/agents/register", apiv1.RegisterAgent)
+	agents := r.Group("/agents", apiv1.AgentAuth)
+	agents.GET("/jobs", apiv1.PollAgentJobs)
+	agents.POST("/heartbeat", apiv1.AgentHeartbeat)
+	agents.POST("/results", apiv1.UploadAgentResult)
+	return r
+}
+
+// SetupProbeRouter serves only health, metrics and the worker list. It is the
+// whole HTTP surface of the worker and scheduler modes, and the base of the
+// full API router. Probes are registered before tracing so they do not flood
+// the traces.
+func SetupProbeRouter() *gin.Engine {
+	r := gin.Default()
+	r.Use(middleware.Logger())
+	r.Use(middleware.Metrics())
+	if h := telemetry.MetricsHandler(); h != nil {
+		r.GET("/metrics", gin.WrapH(h))
+	}
+	r.GET("/healthz", apiv1.Healthz)
+	r.GET("/readyz", apiv1.Readyz)
+	r.GET("/admin/workers", apiv1.ListWorkers)
 	return r
 }
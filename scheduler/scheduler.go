@@ -0,0 +1,172 @@
+// Package scheduler runs periodic maintenance. Any number of scheduler
+// processes may run: they elect a leader through a lock in the job queue and
+// record each task's last run there, so every task runs once per interval
+// overall.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
+)
+
+const (
+	leaderKey  = "scheduler:leader"
+	lastRunKey = "scheduler:last_run:"
+)
+
+var now = time.Now
+
+// pruning is set while a result pruning pass runs in this process.
+var pruning atomic.Bool
+
+// aside counts the tasks running aside from the scheduler loop.
+var aside sync.WaitGroup
+
+// LeaderTTL is how long the lock survives a leader that stopped renewing it.
+var LeaderTTL = 30 * time.Second
+
+// Task is one periodic job.
+type Task struct {
+	Name  string
+	Every time.Duration
+	Run   func(ctx context.Context)
+}
+
+// Tasks returns the maintenance tasks for cfg.
+func Tasks(cfg config.Config) []Task {
+	tasks := []Task{
+		{
+			Name:  "stale-hosts",
+			Every: cfg.Retention.SweepInterval.D(),
+			Run: func(ctx context.Context) {
+				businessv1.MarkStaleHosts(cfg.Retention.HostStaleAfter.D())
+			},
+		},
+		{
+			Name:  "delayed-jobs",
+			Every: 5 * time.Second,
+			Run: func(ctx context.Context) {
+				if _, err := businessv1.PromoteDelayedJobs(ctx); err != nil {
+					log.Printf("Promoting delayed jobs: %v", err)
+				}
+			},
+		},
+		{
+			Name:  "agent-leases",
+			Every: cfg.Agents.LeaseTimeout.D() / 4,
+			Run: func(ctx context.Context) {
+				if _, err := businessv1.RequeueExpiredJobs(ctx); err != nil {
+					log.Printf("Requeueing expired agent jobs: %v", err)
+				}
+			},
+		},
+	}
+	if cfg.Retention.PrunesResults() {
+		tasks = append(tasks, Task{
+			Name:  "prune-results",
+			Every: cfg.Retention.PruneInterval.D(),
+			Run: func(ctx context.Context) {
+				// A pass can take longer than the other tasks' intervals, so it
+				// runs aside; a pass still running skips the next.
+				if !pruning.CompareAndSwap(false, true) {
+					return
+				}
+				aside.Add(1)
+				go func() {
+					defer aside.Done()
+					defer pruning.Store(false)
+					if _, err := businessv1.PruneResults(ctx, false); err != nil {
+						log.Printf("Pruning scan results: %v", err)
+					}
+				}()
+			},
+		})
+	}
+	return tasks
+}
+
+// Start runs tasks in the background until ctx is done, then releases the
+// leader lock if this process held it. The returned channel is closed once
+// the scheduler and the tasks it started aside have stopped.
+func Start(ctx context.Context, tasks []Task) <-chan struct{} {
+	host, _ := os.Hostname()
+	id := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(tickEvery(tasks))
+		defer ticker.Stop()
+		for {
+			if leader, err := holdLock(ctx, id); err != nil {
+				log.Printf("Scheduler lock: %v", err)
+			} else if leader {
+				runDue(ctx, tasks)
+			}
+			select {
+			case <-ctx.Done():
+				releaseLock(context.WithoutCancel(ctx), id)
+				aside.Wait()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// tickEvery is how often the scheduler wakes up: often enough for the most
+// frequent task and to renew the leader lock well before it expires.
+func tickEvery(tasks []Task) time.Duration {
+	every := LeaderTTL / 3
+	for _, t := range tasks {
+		if t.Every > 0 && t.Every < every {
+			every = t.Every
+		}
+	}
+	return every
+}
+
+// holdLock acquires the leader lock or renews it if id already holds it.
+func holdLock(ctx context.Context, id string) (bool, error) {
+	ok, err := businessv1.Queue.Keys.SetNX(ctx, leaderKey, []byte(id), LeaderTTL)
+	if err != nil || ok {
+		return ok, err
+	}
+	return businessv1.Queue.Keys.RenewKey(ctx, leaderKey, []byte(id), LeaderTTL)
+}
+
+func releaseLock(ctx context.Context, id string) {
+	if _, err := businessv1.Queue.Keys.ReleaseKey(ctx, leaderKey, []byte(id)); err != nil {
+		log.Printf("Failed to release scheduler lock: %v", err)
+	}
+}
+
+// runDue runs every task whose interval has passed since its last run by any
+// scheduler.
+func runDue(ctx context.Context, tasks []Task) {
+	now := now()
+	for _, t := range tasks {
+		last, ok, err := businessv1.Queue.Keys.Get(ctx, lastRunKey+t.Name)
+		if err != nil {
+			log.Printf("Scheduler: reading last run of %s: %v", t.Name, err)
+			continue
+		}
+		if ts, _ := strconv.ParseInt(string(last), 10, 64); ok && now.Sub(time.Unix(ts, 0)) < t.Every {
+			continue
+		}
+		if err := businessv1.Queue.Keys.Set(ctx, lastRunKey+t.Name, []byte(strconv.FormatInt(now.Unix(), 10)), 0); err != nil {
+			log.Printf("Scheduler: recording run of %s: %v", t.Name, err)
+			continue
+		}
+		t.Run(ctx)
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withQueue points the scheduler at a fresh in-process queue.
+func withQueue(t *testing.T) *businessv1.MemoryQueue {
+	t.Helper()
+	q, err := businessv1.NewMemoryQueue("")
+	require.NoError(t, err)
+	saved := businessv1.Queue
+	t.Cleanup(func() { businessv1.Queue = saved })
+	businessv1.Queue = q.Stores()
+	return q
+}
+
+func TestHoldLock_AcquireThenRenew(t *testing.T) {
+	withQueue(t)
+	ctx := context.Background()
+
+	leader, err := holdLock(ctx, "me")
+	require.NoError(t, err)
+	assert.True(t, leader, "acquires the free lock")
+
+	leader, err = holdLock(ctx, "me")
+	require.NoError(t, err)
+	assert.True(t, leader, "renews its own lock")
+
+	leader, err = holdLock(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, leader, "another process waits")
+
+	releaseLock(ctx, "other")
+	leader, err = holdLock(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, leader, "only the holder releases the lock")
+
+	releaseLock(ctx, "me")
+	leader, err = holdLock(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, leader)
+}
+
+func TestRunDue_SkipsTasksRunRecentlyElsewhere(t *testing.T) {
+	q := withQueue(t)
+	ctx := context.Background()
+	at := time.Unix(1715238000, 0)
+	now = func() time.Time { return at }
+	defer func() { now = time.Now }()
+
+	var ran []string
+	tasks := []Task{
+		{Name: "fresh", Every: time.Hour, Run: func(context.Context) { ran = append(ran, "fresh") }},
+		{Name: "never", Every: time.Hour, Run: func(context.Context) { ran = append(ran, "never") }},
+		{Name: "overdue", Every: time.Hour, Run: func(context.Context) { ran = append(ran, "overdue") }},
+	}
+	lastRun := func(d time.Duration) []byte { return []byte(strconv.FormatInt(at.Add(d).Unix(), 10)) }
+	require.NoError(t, q.Set(ctx, lastRunKey+"fresh", lastRun(-time.Minute), 0))
+	require.NoError(t, q.Set(ctx, lastRunKey+"overdue", lastRun(-2*time.Hour), 0))
+
+	runDue(ctx, tasks)
+	assert.Equal(t, []string{"never", "overdue"}, ran)
+	for _, name := range []string{"never", "overdue"} {
+		v, ok, err := q.Get(ctx, lastRunKey+name)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, lastRun(0), v, name)
+	}
+}
+
+func TestStart_DoneWaitsForAPruningPass(t *testing.T) {
+	withQueue(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	saved := businessv1.PruneResults
+	t.Cleanup(func() { businessv1.PruneResults = saved })
+	businessv1.PruneResults = func(ctx context.Context, dryRun bool) (models.RetentionReport, error) {
+		close(started)
+		<-release
+		return models.RetentionReport{}, ctx.Err()
+	}
+	cfg := config.Default()
+	cfg.Retention.ResultsFullDetail = config.Duration(time.Hour)
+	var tasks []Task
+	for _, task := range Tasks(cfg) {
+		if task.Name == "prune-results" {
+			tasks = append(tasks, task)
+		}
+	}
+	require.Len(t, tasks, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := Start(ctx, tasks)
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("done before the pruning pass finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+}
+
+func TestTickEvery_FollowsTheMostFrequentTask(t *testing.T) {
+	assert.Equal(t, LeaderTTL/3, tickEvery(nil))
+	assert.Equal(t, LeaderTTL/3, tickEvery([]Task{{Every: time.Hour}, {Every: 0}}))
+	assert.Equal(t, 5*time.Second, tickEvery(Tasks(config.Default())), "delayed jobs are promoted every 5s")
+}
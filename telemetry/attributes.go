@@ -0,0 +1,11 @@
+package telemetry
+
+import "go.opentelemetry.io/otel/attribute"
+
+// Span attribute keys shared by the API, business layer and workers.
+const (
+	AttrScanID  = attribute.Key("scan.id")
+	AttrHost    = attribute.Key("scan.host")
+	AttrStatus  = attribute.Key("scan.status")
+	AttrOutcome = attribute.Key("scan.outcome")
+)
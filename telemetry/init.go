@@ -3,7 +3,8 @@ package telemetry
 import (
 	"context"
 	"log"
-	"os"
+
+	"nmap-rest-api/config"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
@@ -14,13 +15,10 @@ import (
 
 var tracerProvider *sdktrace.TracerProvider
 
-func InitTracer() {
+func InitTracer(cfg config.Telemetry) {
 	ctx := context.Background()
 
-	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "otel-collector:4318" // default fallback
-	}
+	endpoint := cfg.OTLPEndpoint
 
 	exporter, err := otlptracehttp.New(ctx,
 		otlptracehttp.WithEndpoint(endpoint),
@@ -46,10 +44,14 @@ func InitTracer() {
 	)
 
 	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(Propagator)
 	log.Println("OpenTelemetry Tracer initialized with endpoint:", endpoint)
 }
 
 func ShutdownTracer(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
 	if err := tracerProvider.Shutdown(ctx); err != nil {
 		log.Printf("Error shutting down tracer provider: %v", err)
 	}
@@ -3,62 +3,137 @@ package telemetry
 import (
 	"context"
 	"log"
-	"os"
+	"net/http"
+	"strings"
 
+	"nmap-rest-api/config"
+	models "nmap-rest-api/models/v1"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
+// Metric label keys. Span attributes live in attributes.go.
+const (
+	LabelOutcome  = attribute.Key("outcome")
+	LabelProfile  = attribute.Key("profile")
+	LabelStage    = attribute.Key("stage")
+	LabelState    = attribute.Key("state")
+	LabelProtocol = attribute.Key("protocol")
+	LabelMethod   = attribute.Key("method")
+	LabelRoute    = attribute.Key("route")
+	LabelCode     = attribute.Key("status_code")
+)
+
+// Instruments are created from the global meter at startup, so they are
+// usable (as no-ops) before InitMetrics and in tests; InitMetrics installs
+// the real provider and the global meter forwards to it.
 var (
 	Meter         metric.Meter
-	ScanCounter   metric.Int64Counter
-	ScanFailures  metric.Int64Counter
-	ScanHistogram metric.Float64Histogram
+	ScanCounter   metric.Int64Counter     // by outcome and profile
+	ScanFailures  metric.Int64Counter     // by profile
+	ScanHistogram metric.Float64Histogram // by outcome and profile
 
 	// New metrics for worker and scan queue
-	WorkerActive     metric.Int64UpDownCounter
-	WorkerIdle       metric.Int64UpDownCounter
-	ScanQueueLength  metric.Int64ObservableGauge
+	WorkerActive    metric.Int64ObservableGauge // scanning or storing
+	WorkerIdle      metric.Int64ObservableGauge // idle or waiting for a job
+	WorkerStates    metric.Int64ObservableGauge // by state
+	ScanQueueLength metric.Int64ObservableGauge
+	QueueWait       metric.Float64Histogram // enqueue to dequeue
+	ScanRetries     metric.Int64Counter     // by stage: nmap, db_store
+	DBStoreDuration metric.Float64Histogram
+	OpenPortsFound  metric.Int64Counter // by protocol
+
+	HTTPRequests metric.Int64Counter     // by method, route and status code
+	HTTPDuration metric.Float64Histogram // by method and route
+
+	meterProvider  *sdkmetric.MeterProvider
+	metricsHandler http.Handler
 )
 
-func InitMetrics(ctx context.Context, redisQueueLengthFunc func() int64) {
+var secondsBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
 
-	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "otel-collector:4318" // default fallback
-	}
-	exporter, err := otlpmetrichttp.New(ctx,
-		otlpmetrichttp.WithEndpoint(endpoint),
-		otlpmetrichttp.WithInsecure(),
-	)
-	if err != nil {
-		log.Fatalf("Failed to create OTLP metrics exporter: %v", err)
-	}
+func init() {
+	Meter = otel.Meter("nmap-api")
+
+	ScanCounter, _ = Meter.Int64Counter("nmap_scans_total",
+		metric.WithDescription("Scans finished, by outcome and profile"))
+	ScanFailures, _ = Meter.Int64Counter("nmap_scan_failures_total")
+	ScanHistogram, _ = Meter.Float64Histogram("nmap_scan_duration_seconds",
+		metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(secondsBuckets...))
+
+	WorkerActive, _ = Meter.Int64ObservableGauge("worker_active_total")
+	WorkerIdle, _ = Meter.Int64ObservableGauge("worker_idle_total")
+	WorkerStates, _ = Meter.Int64ObservableGauge("workers",
+		metric.WithDescription("Workers by state: idle, waiting, scanning, storing"))
+	ScanQueueLength, _ = Meter.Int64ObservableGauge("scan_queue_length")
+	QueueWait, _ = Meter.Float64Histogram("scan_queue_wait_seconds",
+		metric.WithDescription("Time a job spent in the queue before a worker picked it up"),
+		metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(secondsBuckets...))
+	ScanRetries, _ = Meter.Int64Counter("nmap_scan_retries_total",
+		metric.WithDescription("Retried attempts, by stage"))
+	DBStoreDuration, _ = Meter.Float64Histogram("db_store_duration_seconds",
+		metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(secondsBuckets...))
+	OpenPortsFound, _ = Meter.Int64Counter("nmap_open_ports_discovered_total",
+		metric.WithDescription("Open ports found by stored scans, by protocol"))
+
+	HTTPRequests, _ = Meter.Int64Counter("http_requests_total")
+	HTTPDuration, _ = Meter.Float64Histogram("http_request_duration_seconds",
+		metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(secondsBuckets...))
+}
 
-	provider := sdkmetric.NewMeterProvider(
+// InitMetrics installs the meter provider with the configured exporters:
+// "otlp" pushes to the collector, "prometheus" serves /metrics in-process,
+// "none" disables both. A failing exporter is logged and skipped; it never
+// stops the service.
+func InitMetrics(ctx context.Context, cfg config.Telemetry, redisQueueLengthFunc func() int64) {
+
+	opts := []sdkmetric.Option{
 		sdkmetric.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
 			semconv.ServiceName("nmap-api"),
 		)),
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
-	)
-
-	otel.SetMeterProvider(provider)
-
-	Meter = provider.Meter("nmap-api")
-
-	ScanCounter, _ = Meter.Int64Counter("nmap_scans_total")
-	ScanFailures, _ = Meter.Int64Counter("nmap_scan_failures_total")
-	ScanHistogram, _ = Meter.Float64Histogram("nmap_scan_duration_seconds")
+	}
+	for _, name := range cfg.MetricsExporters {
+		switch strings.TrimSpace(name) {
+		case "otlp":
+			endpoint := cfg.OTLPEndpoint
+			exporter, err := otlpmetrichttp.New(ctx,
+				otlpmetrichttp.WithEndpoint(endpoint),
+				otlpmetrichttp.WithInsecure(),
+			)
+			if err != nil {
+				log.Printf("OTLP metrics exporter disabled: %v", err)
+				continue
+			}
+			opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+			log.Println("OpenTelemetry metrics via OTLP configured with endpoint:", endpoint)
+		case "prometheus":
+			reg := promclient.NewRegistry()
+			exporter, err := otelprom.New(otelprom.WithRegisterer(reg))
+			if err != nil {
+				log.Printf("Prometheus metrics exporter disabled: %v", err)
+				continue
+			}
+			opts = append(opts, sdkmetric.WithReader(exporter))
+			metricsHandler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+			log.Println("Prometheus metrics served on /metrics")
+		case "none", "":
+		default:
+			log.Printf("Unknown metrics exporter %q ignored", name)
+		}
+	}
 
-	// New metrics
-	WorkerActive, _ = Meter.Int64UpDownCounter("worker_active_total")
-	WorkerIdle, _ = Meter.Int64UpDownCounter("worker_idle_total")
-	ScanQueueLength, _ = Meter.Int64ObservableGauge("scan_queue_length")
+	meterProvider = sdkmetric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(meterProvider)
 
 	// Redis queue length gauge
 	Meter.RegisterCallback(
@@ -68,6 +143,45 @@ func InitMetrics(ctx context.Context, redisQueueLengthFunc func() int64) {
 		},
 		ScanQueueLength,
 	)
+}
+
+// ObserveWorkers reports the worker gauges from counts, which returns the
+// number of workers per state. Gauges are read from the live state on every
+// collection instead of being adjusted with paired increments, so they cannot
+// drift.
+func ObserveWorkers(counts func() map[string]int64) {
+	Meter.RegisterCallback(
+		func(ctx context.Context, observer metric.Observer) error {
+			var active, idle int64
+			for state, n := range counts() {
+				observer.ObserveInt64(WorkerStates, n, metric.WithAttributes(LabelState.String(state)))
+				switch state {
+				case models.WorkerScanning, models.WorkerStoring:
+					active += n
+				case models.WorkerIdle, models.WorkerWaiting:
+					idle += n
+				}
+			}
+			observer.ObserveInt64(WorkerActive, active)
+			observer.ObserveInt64(WorkerIdle, idle)
+			return nil
+		},
+		WorkerStates, WorkerActive, WorkerIdle,
+	)
+}
+
+// ShutdownMetrics flushes pending metrics to the exporters.
+func ShutdownMetrics(ctx context.Context) {
+	if meterProvider == nil {
+		return
+	}
+	if err := meterProvider.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down meter provider: %v", err)
+	}
+}
 
-	log.Println("OpenTelemetry metrics via OTLP configured")
+// MetricsHandler serves the Prometheus exposition format, or is nil when the
+// prometheus exporter is not enabled.
+func MetricsHandler() http.Handler {
+	return metricsHandler
 }
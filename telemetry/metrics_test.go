@@ -0,0 +1,35 @@
+package telemetry_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"nmap-rest-api/config"
+	"nmap-rest-api/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestInitMetrics_PrometheusExporter(t *testing.T) {
+	ctx := context.Background()
+	telemetry.InitMetrics(ctx, config.Telemetry{MetricsExporters: []string{"prometheus"}}, func() int64 { return 7 })
+	h := telemetry.MetricsHandler()
+	require.NotNil(t, h)
+
+	// Instruments created before InitMetrics report through the new provider.
+	telemetry.ScanCounter.Add(ctx, 1, metric.WithAttributes(
+		telemetry.LabelOutcome.String("done"), telemetry.LabelProfile.String("tcp-connect")))
+	telemetry.HTTPRequests.Add(ctx, 1, metric.WithAttributes(
+		telemetry.LabelMethod.String("POST"), telemetry.LabelRoute.String("/scan"), telemetry.LabelCode.String("202")))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	assert.Contains(t, string(body), `nmap_scans_total{otel_scope_name="nmap-api",otel_scope_version="",outcome="done",profile="tcp-connect"} 1`)
+	assert.Contains(t, string(body), `route="/scan"`)
+	assert.Contains(t, string(body), `scan_queue_length{otel_scope_name="nmap-api",otel_scope_version=""} 7`)
+}
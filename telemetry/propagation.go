@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Propagator carries W3C traceparent/tracestate and baggage. InitTracer
+// installs it globally so otelgin picks up incoming headers; jobs use it
+// directly so propagation does not depend on tracing being initialised.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Inject returns the trace context and baggage of ctx as a carrier suitable
+// for a job payload, or nil if there is nothing to propagate.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	Propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx with the remote span context and baggage found in
+// carrier, so spans started from it continue the originating trace.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return Propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// Links turns the carriers of several jobs into span links. A span that
+// serves many jobs at once (a batch) cannot have them all as parent, so it
+// links to each originating trace instead.
+func Links(carriers ...map[string]string) []trace.Link {
+	var links []trace.Link
+	for _, c := range carriers {
+		sc := trace.SpanContextFromContext(Extract(context.Background(), c))
+		if sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return links
+}
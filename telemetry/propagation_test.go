@@ -0,0 +1,57 @@
+package telemetry_test
+
+import (
+	"context"
+	"testing"
+
+	"nmap-rest-api/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract_RoundTrip(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	member, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	carrier := telemetry.Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+	assert.Equal(t, "tenant=acme", carrier["baggage"])
+
+	out := telemetry.Extract(context.Background(), carrier)
+	sc := trace.SpanContextFromContext(out)
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), sc.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), sc.SpanID())
+	assert.Equal(t, "acme", baggage.FromContext(out).Member("tenant").Value())
+
+	// A worker span started from the extracted context joins the trace.
+	_, child := tp.Tracer("test").Start(out, "worker.scan")
+	defer child.End()
+	assert.Equal(t, span.SpanContext().TraceID(), child.SpanContext().TraceID())
+}
+
+func TestInject_NothingToPropagate(t *testing.T) {
+	assert.Nil(t, telemetry.Inject(context.Background()))
+	ctx := context.Background()
+	assert.Equal(t, ctx, telemetry.Extract(ctx, nil))
+}
+
+func TestLinks_SkipsInvalidCarriers(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	links := telemetry.Links(telemetry.Inject(ctx), nil, map[string]string{"traceparent": "garbage"})
+	require.Len(t, links, 1)
+	assert.Equal(t, span.SpanContext().TraceID(), links[0].SpanContext.TraceID())
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
-	"regexp"
 	"net"
+	"regexp"
+
+	"time"
+
+	models "nmap-rest-api/models/v1"
 
 	"github.com/google/uuid"
 )
@@ -11,6 +15,7 @@ import (
 var (
 	IsValidHostname = isValidHostname
 	GenerateScanID  = generateScanID
+	Now             = time.Now
 )
 
 func isValidHostname(host string) bool {
@@ -41,3 +46,12 @@ func Diff(a, b []int) []int {
 	}
 	return result
 }
+
+// PortNumbers extracts the port numbers from detailed port observations.
+func PortNumbers(ports []models.PortInfo) []int {
+	var result []int
+	for _, p := range ports {
+		result = append(result, p.Port)
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"nmap-rest-api/models/v1"
+)
+
+// slot tracks what one worker goroutine is doing, for metrics and the admin
+// endpoint. Every transition goes through its methods so the state can never
+// drift the way paired counter increments did.
+type slot struct {
+	mu     sync.Mutex
+	status models.WorkerStatus
+}
+
+var (
+	slotsMu sync.Mutex
+	slots   []*slot
+)
+
+func newSlot() *slot {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	s := &slot{status: models.WorkerStatus{ID: len(slots) + 1, State: models.WorkerIdle, Since: time.Now()}}
+	slots = append(slots, s)
+	return s
+}
+
+func (s *slot) set(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now()
+	}
+}
+
+// begin records the jobs the worker just took and moves it to scanning. The
+// status names the first job and counts the rest.
+func (s *slot) begin(jobs []models.ScanJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.status.State = models.WorkerScanning
+	s.status.Since = now
+	s.status.ScanID = jobs[0].ScanID
+	s.status.Host = jobs[0].Host
+	s.status.BatchSize = 0
+	if len(jobs) > 1 {
+		s.status.BatchSize = len(jobs)
+	}
+	s.status.StartedAt = &now
+	s.status.NmapPID = 0
+}
+
+// nmapStarted records the PID of the running nmap child; 0 clears it.
+func (s *slot) nmapStarted(pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.NmapPID = pid
+}
+
+// finish clears the n jobs and returns the worker to waiting.
+func (s *slot) finish(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = models.WorkerStatus{
+		ID:    s.status.ID,
+		State: models.WorkerWaiting,
+		Since: time.Now(),
+		Jobs:  s.status.Jobs + int64(n),
+	}
+}
+
+// Workers returns a snapshot of every worker started in this process.
+func Workers() []models.WorkerStatus {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	out := make([]models.WorkerStatus, 0, len(slots))
+	for _, s := range slots {
+		s.mu.Lock()
+		out = append(out, s.status)
+		s.mu.Unlock()
+	}
+	return out
+}
+
+// stateCounts returns how many workers are in each state.
+func stateCounts() map[string]int64 {
+	counts := map[string]int64{
+		models.WorkerIdle:     0,
+		models.WorkerWaiting:  0,
+		models.WorkerScanning: 0,
+		models.WorkerStoring:  0,
+		models.WorkerStopped:  0,
+	}
+	for _, w := range Workers() {
+		counts[w.State]++
+	}
+	return counts
+}
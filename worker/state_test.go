@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"testing"
+
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlot_Transitions(t *testing.T) {
+	w := newSlot()
+	before := stateCounts()
+
+	w.set(models.WorkerWaiting)
+	w.begin([]models.ScanJob{{ScanID: "scan-1", Host: "web-1"}, {ScanID: "scan-2", Host: "web-2"}})
+	w.nmapStarted(4242)
+
+	var got models.WorkerStatus
+	for _, s := range Workers() {
+		if s.ID == w.status.ID {
+			got = s
+		}
+	}
+	require.NotZero(t, got.ID)
+	assert.Equal(t, models.WorkerScanning, got.State)
+	assert.Equal(t, "scan-1", got.ScanID)
+	assert.Equal(t, "web-1", got.Host)
+	assert.Equal(t, 2, got.BatchSize)
+	assert.Equal(t, 4242, got.NmapPID)
+	require.NotNil(t, got.StartedAt)
+	assert.Equal(t, before[models.WorkerIdle]-1, stateCounts()[models.WorkerIdle])
+	assert.Equal(t, before[models.WorkerScanning]+1, stateCounts()[models.WorkerScanning])
+
+	w.nmapStarted(0)
+	w.set(models.WorkerStoring)
+	assert.Equal(t, before[models.WorkerStoring]+1, stateCounts()[models.WorkerStoring])
+
+	w.finish(2)
+	s := w.status
+	assert.Equal(t, models.WorkerWaiting, s.State)
+	assert.Empty(t, s.ScanID)
+	assert.Nil(t, s.StartedAt)
+	assert.Zero(t, s.NmapPID)
+	assert.EqualValues(t, 2, s.Jobs)
+	assert.Equal(t, before[models.WorkerScanning], stateCounts()[models.WorkerScanning])
+}
@@ -1,117 +1,450 @@
 package worker
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
 	"nmap-rest-api/models/v1"
+	"nmap-rest-api/nmap"
 	"nmap-rest-api/telemetry"
+	"nmap-rest-api/utils"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	database "nmap-rest-api/database"
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/config"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var tracer = otel.Tracer("nmap-api")
 
-func StartWorkerPool(concurrency int, ctx context.Context) {
+// scanProfile labels scan metrics with the kind of scan runNmap performs.
+const scanProfile = "tcp-connect"
+
+// Nmap configures how runNmap invokes nmap; main sets it from the config
+// before starting the pool.
+var Nmap = config.Default().Nmap
+
+// BatchSize is how many compatible jobs one worker takes per nmap run; main
+// sets it from the config before starting the pool.
+var BatchSize = config.Default().Worker.BatchSize
+
+// popTimeout bounds each BLPOP so a stopping worker notices within a few
+// seconds. The pop itself never uses a cancellable context: a job the server
+// already handed out must not be dropped by an aborted read.
+const popTimeout = 2 * time.Second
+
+// Pool is a running set of workers.
+type Pool struct {
+	stop  context.CancelFunc // stops taking new jobs
+	abort context.CancelFunc // cancels in-flight scans
+	wg    sync.WaitGroup
+}
+
+func StartWorkerPool(concurrency int, ctx context.Context) *Pool {
+	telemetry.ObserveWorkers(stateCounts)
+	intake, stop := context.WithCancel(ctx)
+	jobs, abort := context.WithCancel(context.WithoutCancel(ctx))
+	p := &Pool{stop: stop, abort: abort}
 	for i := 0; i < concurrency; i++ {
+		w := newSlot()
+		p.wg.Add(1)
 		go func() {
-			for {
-				telemetry.WorkerIdle.Add(ctx, -1)
-				telemetry.WorkerActive.Add(ctx, 1)
-				ctxRedis, span := tracer.Start(ctx, "worker.redis.pop")
-				val, err := database.RDB.BLPop(ctxRedis, 0, "scan_jobs").Result()
-				span.End()
-				if err != nil || len(val) < 2 {
-					log.Printf("Failed to pop job: %v", err)
+			defer p.wg.Done()
+			defer w.set(models.WorkerStopped)
+			for intake.Err() == nil {
+				batch, ok := popBatch(intake, w)
+				if !ok {
 					continue
 				}
-
-				var job models.ScanJob
-				if err := json.Unmarshal([]byte(val[1]), &job); err != nil {
-					log.Printf("Invalid job format: %v", err)
+				if intake.Err() != nil {
+					// Popped while stopping: hand it back untouched.
+					for _, job := range batch {
+						requeue(jobs, job)
+					}
+					break
+				}
+				if batch = claim(jobs, batch); len(batch) == 0 {
+					w.set(models.WorkerIdle)
 					continue
 				}
+				processBatch(jobs, w, batch)
+				w.finish(len(batch))
+			}
+		}()
+	}
+	return p
+}
 
-				database.SetScanStatus(job.ScanID, job.Host, "in_progress")
-				start := time.Now()
-
-				var ports []int
-				maxRetries := 3
-				for attempt := 1; attempt <= maxRetries; attempt++ {
-					_, span = tracer.Start(ctx, "nmap.run")
-					ports = runNmap(ctx, job.Host)
-					span.End()
-					if len(ports) > 0 {
-						break
-					}
-					log.Printf("Retrying nmap (%d/%d) for %s", attempt, maxRetries, job.Host)
-					time.Sleep(time.Duration(attempt) * time.Second) // Exponential backoff
-				}
+// Shutdown stops the workers from taking new jobs and waits for in-flight
+// scans until ctx is done. Scans still running then are cancelled and
+// requeued as pending, so another worker or the next start picks them up.
+func (p *Pool) Shutdown(ctx context.Context) {
+	p.stop()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Shutdown grace period over, requeueing in-flight scans")
+		p.abort()
+		<-done
+	}
+}
 
-				res := models.ScanResult{
-					ScanID:    job.ScanID,
-					Host:      job.Host,
-					ScannedAt: time.Now(),
-					OpenPorts: ports,
-				}
+// popBatch waits up to popTimeout for up to BatchSize jobs and keeps those
+// that can share one nmap run with the first; the rest go back to the head
+// of the queue.
+func popBatch(ctx context.Context, w *slot) ([]models.ScanJob, bool) {
+	w.set(models.WorkerWaiting)
+	ctxRedis, span := tracer.Start(context.WithoutCancel(ctx), "worker.redis.pop")
+	defer span.End()
+	size := BatchSize
+	if size < 1 {
+		size = 1
+	}
+	jobs, err := businessv1.WaitJobs(ctxRedis, "", size, popTimeout)
+	if err != nil {
+		log.Printf("Failed to pop job: %v", err)
+		w.set(models.WorkerIdle)
+		sleep(ctx, time.Second)
+		return nil, false
+	}
+	if len(jobs) == 0 {
+		return nil, false
+	}
+
+	var batch []models.ScanJob
+	for _, job := range jobs {
+		if batchKey(job) != batchKey(jobs[0]) {
+			requeue(ctx, job)
+			continue
+		}
+		batch = append(batch, job)
+		// The pop happens before the jobs are known, so it cannot be a
+		// child of their requests; link it to those traces instead.
+		for _, l := range telemetry.Links(job.TraceContext) {
+			span.AddLink(l)
+		}
+	}
+	return batch, true
+}
+
+// batchKey identifies the nmap options a job needs; only jobs with equal keys
+// share a run. Beyond the configured nmap.args that is the port list.
+func batchKey(job models.ScanJob) string {
+	return job.Ports
+}
+
+// claim takes the target slots of each job. Jobs whose target is busy have
+// been deferred; jobs that could not be claimed go back to the queue.
+func claim(ctx context.Context, jobs []models.ScanJob) []models.ScanJob {
+	claimed := jobs[:0]
+	for _, job := range jobs {
+		c, ok, err := businessv1.ClaimTarget(context.WithoutCancel(ctx), job)
+		if err != nil {
+			log.Printf("Failed to claim %s for scan %s: %v", job.Host, job.ScanID, err)
+			requeue(ctx, job)
+			continue
+		}
+		if ok {
+			claimed = append(claimed, c)
+		}
+	}
+	return claimed
+}
 
-				var errDatabase error
-				for attempt := 1; attempt <= maxRetries; attempt++ {
-					_, span = tracer.Start(ctx, "db.store_result")
-					errDatabase = database.StoreResult(res)
-					span.End()
-					if errDatabase == nil {
-						break
+// holdTargets renews the jobs' claims while they run. The returned release
+// frees them; calling it again does nothing.
+func holdTargets(ctx context.Context, jobs []models.ScanJob) (release func()) {
+	if !businessv1.Limits.Enabled() {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(businessv1.Limits.LockTTL.D() / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				for _, job := range jobs {
+					if err := businessv1.RenewTarget(context.WithoutCancel(ctx), job); err != nil {
+						log.Printf("Failed to renew claim on %s: %v", job.Host, err)
 					}
-					log.Printf("Retrying DB store (%d/%d) for %s", attempt, maxRetries, job.Host)
-					time.Sleep(time.Duration(attempt) * time.Second)
 				}
-
-				duration := time.Since(start).Seconds()
-				telemetry.ScanCounter.Add(ctx, 1)
-				telemetry.ScanHistogram.Record(ctx, duration)
-
-				if errDatabase != nil {
-					log.Println("DB error:", errDatabase)
-					telemetry.ScanFailures.Add(ctx, 1)
-					database.SetScanStatus(job.ScanID, job.Host, "failed")
-				} else {
-					log.Println("Scan result stored")
-					database.SetScanStatus(job.ScanID, job.Host, "done")
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			for _, job := range jobs {
+				if err := businessv1.ReleaseTarget(context.WithoutCancel(ctx), job); err != nil {
+					log.Printf("Failed to release claim on %s: %v", job.Host, err)
 				}
-				telemetry.WorkerActive.Add(ctx, -1)
-				telemetry.WorkerIdle.Add(ctx, 1)
 			}
-		}()
+		})
+	}
+}
+
+func requeue(ctx context.Context, job models.ScanJob) {
+	if err := businessv1.RequeueJob(context.WithoutCancel(ctx), job); err != nil {
+		log.Printf("Failed to requeue %s for scan %s: %v", job.Host, job.ScanID, err)
 	}
 }
 
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// processBatch scans the claimed jobs' hosts in one nmap run, stores each
+// host's result and releases the claims. The run has its own trace, linked
+// to the requests that queued the jobs; each job's result is stored in a
+// span continuing its request's trace and linked back to the run.
+func processBatch(ctx context.Context, w *slot, batch []models.ScanJob) {
+	release := holdTargets(ctx, batch)
+	defer release()
+	w.begin(batch)
 
-// runNmap will run the nmap
-func runNmap(_ context.Context, host string) []int {
-	log.Println("nmap function has been called for ", host)
-	scanType := "-sT"
-	cmd := exec.Command("nmap", "-Pn", scanType, "--max-retries", "2", host)
-	output, err := cmd.CombinedOutput()
+	var links []trace.Link
+	for _, job := range batch {
+		links = append(links, telemetry.Links(job.TraceContext)...)
+	}
+	ctx, runSpan := tracer.Start(ctx, "worker.batch",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("scan.batch_size", len(batch))))
+	defer runSpan.End()
+
+	profile := metric.WithAttributes(telemetry.LabelProfile.String(scanProfile))
+	hosts := make([]string, len(batch))
+	maxRate := 0
+	for i, job := range batch {
+		hosts[i] = job.Host
+		// Each job's share of the packet budget adds to the run's.
+		maxRate += job.MaxRate
+		if job.EnqueuedAt > 0 {
+			telemetry.QueueWait.Record(ctx, time.Since(time.Unix(0, job.EnqueuedAt)).Seconds(), profile)
+		}
+		businessv1.UpdateScanStatus(ctx, job.ScanID, job.Host, "in_progress")
+	}
+	start := time.Now()
+
+	results := ScanHosts(ctx, hosts, ScanOptions{Ports: batch[0].Ports, MaxRate: maxRate}, w.nmapStarted)
+	if ctx.Err() != nil {
+		// Shutdown cut the scan short; its results are incomplete.
+		log.Printf("Scan of %s interrupted by shutdown, requeueing", strings.Join(hosts, ", "))
+		release()
+		for _, job := range batch {
+			requeue(ctx, job)
+		}
+		runSpan.SetAttributes(telemetry.AttrOutcome.String("requeued"))
+		return
+	}
+
+	w.set(models.WorkerStoring)
+	for i, job := range batch {
+		storeResult(ctx, job, results[i], start)
+	}
+}
+
+// storeResult persists one job's result from a batch run started at start.
+func storeResult(runCtx context.Context, job models.ScanJob, res models.ScanResult, start time.Time) {
+	ctx, jobSpan := tracer.Start(telemetry.Extract(runCtx, job.TraceContext), "worker.scan",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(start),
+		trace.WithLinks(trace.LinkFromContext(runCtx)),
+		trace.WithAttributes(telemetry.AttrScanID.String(job.ScanID), telemetry.AttrHost.String(job.Host)))
+	defer jobSpan.End()
+
+	res.ScanID = job.ScanID
+	res.ScannedAt = time.Now()
+	errDatabase := businessv1.PersistJobResult(ctx, job, res)
+
+	outcome := "done"
+	if errDatabase != nil {
+		outcome = "failed"
+	}
+	attrs := metric.WithAttributes(telemetry.LabelProfile.String(scanProfile), telemetry.LabelOutcome.String(outcome))
+	telemetry.ScanCounter.Add(ctx, 1, attrs)
+	telemetry.ScanHistogram.Record(ctx, time.Since(start).Seconds(), attrs)
+
+	jobSpan.SetAttributes(attribute.String("scan.host_state", res.HostState), telemetry.AttrOutcome.String(outcome))
+	if errDatabase != nil {
+		log.Println("DB error:", errDatabase)
+		telemetry.ScanFailures.Add(ctx, 1, metric.WithAttributes(telemetry.LabelProfile.String(scanProfile)))
+		jobSpan.RecordError(errDatabase)
+		jobSpan.SetStatus(codes.Error, errDatabase.Error())
+	} else {
+		log.Println("Scan result stored")
+	}
+}
+
+// ScanOptions are what a job adds to the configured nmap.args.
+type ScanOptions struct {
+	// Ports is the -p port list; empty leaves nmap's default ports.
+	Ports string
+	// MaxRate caps the packets per second sent; 0 means no limit.
+	MaxRate int
+}
+
+// ScanHost runs nmap against host, retrying up to Nmap.MaxRetries times while
+// nmap fails. The result is incomplete if ctx was cancelled, and carries an
+// Error if nmap never produced one. Remote agents use it too.
+func ScanHost(ctx context.Context, host string, opts ScanOptions, started func(pid int)) models.ScanResult {
+	return ScanHosts(ctx, []string{host}, opts, started)[0]
+}
+
+// ScanHosts is ScanHost for several hosts sharing one nmap run and its
+// MaxRate. It returns a result per host, in order; hosts whose run failed
+// are retried together. A host without open ports is a result like any other.
+func ScanHosts(ctx context.Context, hosts []string, opts ScanOptions, started func(pid int)) []models.ScanResult {
+	results := make([]models.ScanResult, len(hosts))
+	pending := make([]int, len(hosts))
+	for i := range hosts {
+		pending[i] = i
+	}
+	maxRetries := Nmap.MaxRetries
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		targets := make([]string, len(pending))
+		for j, i := range pending {
+			targets[j] = hosts[i]
+		}
+		attrs := []attribute.KeyValue{attribute.Int("nmap.attempt", attempt), attribute.Int("nmap.hosts", len(targets))}
+		if len(targets) == 1 {
+			attrs = append(attrs, telemetry.AttrHost.String(targets[0]))
+		}
+		_, span := tracer.Start(ctx, "nmap.run", trace.WithAttributes(attrs...))
+		out := runNmap(ctx, targets, opts, started)
+		open := 0
+		var failed []int
+		for j, i := range pending {
+			results[i] = out[j]
+			open += len(out[j].OpenPorts)
+			if out[j].Error != "" {
+				failed = append(failed, i)
+			}
+		}
+		span.SetAttributes(attribute.Int("nmap.open_ports", open))
+		span.End()
+		pending = failed
+		if len(pending) == 0 || attempt == maxRetries || ctx.Err() != nil {
+			break
+		}
+		telemetry.ScanRetries.Add(ctx, 1, metric.WithAttributes(telemetry.LabelStage.String("nmap")))
+		log.Printf("Retrying nmap (%d/%d) for %d host(s)", attempt, maxRetries, len(pending))
+		sleep(ctx, time.Duration(attempt)*time.Second) // Exponential backoff
+	}
+	return results
+}
+
+// runNmap will run the nmap over hosts and split its XML into one result per
+// host. started is told the child's PID once it runs and 0 once it exits;
+// cancelling ctx kills the child.
+func runNmap(ctx context.Context, hosts []string, opts ScanOptions, started func(pid int)) []models.ScanResult {
+	results := make([]models.ScanResult, len(hosts))
+	for i, h := range hosts {
+		results[i].Host = h
+	}
+	targets := strings.Join(hosts, " ")
+	log.Println("nmap function has been called for ", targets)
+	if Nmap.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, Nmap.Timeout.D())
+		defer cancel()
+	}
+	args := append([]string{}, Nmap.Args...)
+	if opts.Ports != "" {
+		args = append(args, "-p", opts.Ports)
+	}
+	if opts.MaxRate > 0 {
+		args = append(args, "--max-rate", strconv.Itoa(opts.MaxRate))
+	}
+	args = append(append(args, "-oX", "-"), hosts...)
+	cmd := exec.CommandContext(ctx, Nmap.Binary, args...)
+	// Warnings go to stderr and would corrupt the XML on stdout.
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	err := cmd.Start()
+	if err == nil {
+		started(cmd.Process.Pid)
+		err = cmd.Wait()
+		started(0)
+	}
 	if err != nil {
-		log.Printf("nmap error for %s: %v\nOutput: %s", host, err, output)
-		return nil
+		log.Printf("nmap error for %s: %v\nStderr: %s", targets, err, stderr.Bytes())
+		reason := "nmap: " + err.Error()
+		if msg := stderrSummary(stderr.Bytes()); msg != "" {
+			reason += ": " + msg
+		}
+		return failed(results, reason)
+	}
+	if stderr.Len() > 0 {
+		log.Printf("nmap warnings for %s: %s", targets, stderr.Bytes())
 	}
-	log.Println("nmap function has been executed successfully ", host)
+	log.Println("nmap function has been executed successfully ", targets)
 
-	var openPorts []int
-	for _, line := range strings.Split(string(output), "\n") {
-		if strings.Contains(line, "/tcp") && strings.Contains(line, "open") {
-			var port int
-			fmt.Sscanf(strings.Fields(line)[0], "%d/tcp", &port)
-			openPorts = append(openPorts, port)
+	run, err := nmap.Parse(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		log.Printf("nmap XML parse error for %s: %v", targets, err)
+		return failed(results, "nmap XML: "+err.Error())
+	}
+
+	for i, found := range run.Split(hosts) {
+		res := &results[i]
+		res.HostState = "down"
+		for _, h := range found {
+			if h.Up() {
+				res.HostState = "up"
+			}
+			if addr := h.Address(); addr != "" {
+				res.Addresses = append(res.Addresses, addr)
+			}
+			res.Ports = append(res.Ports, h.PortInfos()...)
 		}
+		res.OpenPorts = utils.PortNumbers(res.Ports)
+	}
+	return results
+}
+
+// maxStderr bounds how much of nmap's stderr a failed result's error keeps.
+const maxStderr = 512
+
+// stderrSummary trims nmap's stderr to fit a result's error.
+func stderrSummary(b []byte) string {
+	msg := strings.Join(strings.Fields(string(b)), " ")
+	if len(msg) > maxStderr {
+		msg = msg[:maxStderr] + "..."
+	}
+	return msg
+}
+
+// failed marks every result of a run that produced none with reason.
+func failed(results []models.ScanResult, reason string) []models.ScanResult {
+	for i := range results {
+		results[i].Error = reason
 	}
-	return openPorts
+	return results
 }
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	businessv1 "nmap-rest-api/business/v1"
+	"nmap-rest-api/models/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_ShutdownStopsIdleWorkers(t *testing.T) {
+	waitJobs := businessv1.WaitJobs
+	defer func() { businessv1.WaitJobs = waitJobs }()
+	businessv1.WaitJobs = func(ctx context.Context, zone string, max int, timeout time.Duration) ([]models.ScanJob, error) {
+		assert.Equal(t, popTimeout, timeout)
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	}
+
+	before := len(Workers())
+	p := StartWorkerPool(1, context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	p.Shutdown(ctx)
+	assert.Less(t, time.Since(start), time.Second, "idle workers should stop without waiting out the grace period")
+
+	workers := Workers()
+	assert.Len(t, workers, before+1)
+	assert.Equal(t, models.WorkerStopped, workers[len(workers)-1].State)
+}
+
+func TestScanHosts_OneRunSplitPerHost(t *testing.T) {
+	xml, err := filepath.Abs("../nmap/testdata/batch.xml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "nmap")
+	// Logs each invocation's arguments and prints the same batch output.
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+dir+"/calls\ncat "+xml+"\n"), 0o755))
+
+	saved := Nmap
+	defer func() { Nmap = saved }()
+	Nmap.Binary, Nmap.Args, Nmap.MaxRetries = script, []string{"-Pn"}, 2
+
+	res := ScanHosts(context.Background(), []string{"web.example.com", "10.0.1.0/30", "gone.example.com"}, ScanOptions{Ports: "1-1024", MaxRate: 300}, func(int) {})
+
+	require.Len(t, res, 3)
+	assert.Equal(t, []int{443}, res[0].OpenPorts)
+	assert.Equal(t, []int{22, 80}, res[1].OpenPorts)
+	assert.Equal(t, "gone.example.com", res[2].Host)
+	assert.Equal(t, "down", res[2].HostState)
+	assert.Empty(t, res[2].Ports)
+
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	require.NoError(t, err)
+	assert.Equal(t, "-Pn -p 1-1024 --max-rate 300 -oX - web.example.com 10.0.1.0/30 gone.example.com\n", string(calls),
+		"a host without open ports is not retried")
+}
+
+func TestScanHosts_RetriesFailedRuns(t *testing.T) {
+	xml, err := filepath.Abs("../nmap/testdata/batch.xml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "nmap")
+	// Fails the first run, then prints the batch output.
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+dir+"/calls\n"+
+		"[ -e "+dir+"/failed ] || { touch "+dir+"/failed; exit 1; }\ncat "+xml+"\n"), 0o755))
+
+	saved := Nmap
+	defer func() { Nmap = saved }()
+	Nmap.Binary, Nmap.Args, Nmap.MaxRetries = script, []string{"-Pn"}, 3
+
+	res := ScanHost(context.Background(), "web.example.com", ScanOptions{}, func(int) {})
+
+	assert.Empty(t, res.Error)
+	assert.Equal(t, []int{443}, res.OpenPorts)
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	require.NoError(t, err)
+	assert.Equal(t, "-Pn -oX - web.example.com\n-Pn -oX - web.example.com\n", string(calls))
+}
+
+func TestScanHost_KeepsStderrOutOfTheXML(t *testing.T) {
+	xml, err := filepath.Abs("../nmap/testdata/batch.xml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "nmap")
+	// Warns on stderr, then fails once with an error there before succeeding.
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho 'Warning: giving up on port because retransmission cap hit' >&2\n"+
+		"[ -e "+dir+"/failed ] || { touch "+dir+"/failed; echo 'Failed to resolve \"web.example.com\".' >&2; exit 1; }\ncat "+xml+"\n"), 0o755))
+
+	saved := Nmap
+	defer func() { Nmap = saved }()
+	Nmap.Binary, Nmap.Args, Nmap.MaxRetries = script, nil, 1
+
+	res := ScanHost(context.Background(), "web.example.com", ScanOptions{}, func(int) {})
+	assert.Equal(t, `nmap: exit status 1: Warning: giving up on port because retransmission cap hit Failed to resolve "web.example.com".`, res.Error)
+
+	res = ScanHost(context.Background(), "web.example.com", ScanOptions{}, func(int) {})
+	assert.Empty(t, res.Error, "warnings do not break the XML")
+	assert.Equal(t, []int{443}, res.OpenPorts)
+}